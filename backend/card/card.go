@@ -0,0 +1,221 @@
+package card
+
+import (
+	"fmt"
+	"h3-travel/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Brand string
+
+const (
+	Visa       Brand = "visa"
+	Mastercard Brand = "mastercard"
+	Amex       Brand = "amex"
+	CB         Brand = "cb"
+)
+
+// ValidationError indique précisément quel contrôle a échoué
+type ValidationError struct {
+	Field   string `json:"field"`   // "card", "exp_month", "exp_year" ou "cvv"
+	Code    string `json:"code"`    // identifiant stable du contrôle
+	Message string `json:"message"` // message lisible
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func invalid(field, code, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+type brandRule struct {
+	brand    Brand
+	prefixes []string // préfixes IIN
+	ranges   [][2]int // plages IIN sur 4 chiffres
+	lengths  []int
+	cvv      int
+}
+
+// L'ordre compte : les BIN CB (cartes co-badgées) sont testés avant Visa et Mastercard.
+var brandRules = []brandRule{
+	{brand: CB, prefixes: []string{"4970", "4971", "4972", "4973", "4974", "4975", "4976", "4977", "4978", "4979"}, lengths: []int{16}, cvv: 3},
+	{brand: Amex, prefixes: []string{"34", "37"}, lengths: []int{15}, cvv: 4},
+	{brand: Visa, prefixes: []string{"4"}, lengths: []int{13, 16, 19}, cvv: 3},
+	{brand: Mastercard, prefixes: []string{"51", "52", "53", "54", "55"}, ranges: [][2]int{{2221, 2720}}, lengths: []int{16}, cvv: 3},
+}
+
+// Numéros de test publics des bacs à sable (Stripe, CB...) : refusés quand les cartes de test ne sont pas autorisées
+var testCards = map[string]bool{
+	"4242424242424242": true,
+	"4000056655665556": true,
+	"4000000000000002": true,
+	"4000000000009995": true,
+	"4000000000000119": true,
+	"5555555555554444": true,
+	"2223003122003222": true,
+	"5200828282828210": true,
+	"378282246310005":  true,
+	"371449635398431":  true,
+	"4970100000000154": true,
+}
+
+// IsTestCard indique si le numéro normalisé est une carte de test connue.
+func IsTestCard(number string) bool {
+	return testCards[number]
+}
+
+// Details regroupe les informations de carte saisies par le client
+type Details struct {
+	Number   string
+	ExpMonth int
+	ExpYear  int
+	CVV      string
+}
+
+// Card : résultat d'une validation réussie
+type Card struct {
+	Number string // numéro normalisé (chiffres uniquement)
+	Brand  Brand
+	Last4  string
+}
+
+// Normalize retire les espaces et tirets de saisie ; tout autre caractère est refusé.
+func Normalize(number string) (string, error) {
+	var b strings.Builder
+	for _, r := range number {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ' || r == '-':
+		default:
+			return "", invalid("card", "invalid_characters", "Le numéro de carte contient des caractères invalides")
+		}
+	}
+
+	if b.Len() == 0 {
+		return "", invalid("card", "empty", "Numéro de carte manquant")
+	}
+	return b.String(), nil
+}
+
+func findRule(number string) (brandRule, bool) {
+	for _, rule := range brandRules {
+		for _, prefix := range rule.prefixes {
+			if strings.HasPrefix(number, prefix) {
+				return rule, true
+			}
+		}
+		if len(number) >= 4 {
+			iin, _ := strconv.Atoi(number[:4])
+			for _, r := range rule.ranges {
+				if iin >= r[0] && iin <= r[1] {
+					return rule, true
+				}
+			}
+		}
+	}
+	return brandRule{}, false
+}
+
+// DetectBrand identifie le réseau d'une carte à partir de ses premiers chiffres (IIN).
+func DetectBrand(number string) (Brand, error) {
+	rule, ok := findRule(number)
+	if !ok {
+		return "", invalid("card", "unknown_brand", "Réseau de carte non reconnu (Visa, Mastercard, Amex ou CB)")
+	}
+	return rule.brand, nil
+}
+
+// ValidateNumber normalise le numéro puis contrôle le réseau, la longueur et la clé de Luhn.
+func ValidateNumber(number string) (string, Brand, error) {
+	normalized, err := Normalize(number)
+	if err != nil {
+		return "", "", err
+	}
+
+	rule, ok := findRule(normalized)
+	if !ok {
+		return "", "", invalid("card", "unknown_brand", "Réseau de carte non reconnu (Visa, Mastercard, Amex ou CB)")
+	}
+
+	validLength := false
+	for _, l := range rule.lengths {
+		if len(normalized) == l {
+			validLength = true
+		}
+	}
+	if !validLength {
+		return "", "", invalid("card", "invalid_length", "Longueur invalide pour une carte %s : %d chiffres", rule.brand, len(normalized))
+	}
+
+	if !utils.ValidateCardNumber(normalized) {
+		return "", "", invalid("card", "luhn", "Numéro de carte invalide (clé de Luhn)")
+	}
+
+	return normalized, rule.brand, nil
+}
+
+// ValidateExpiry vérifie que la carte est valide jusqu'à la fin du mois indiqué.
+// Les années sur deux chiffres sont interprétées comme 20xx.
+func ValidateExpiry(month, year int, now time.Time) error {
+	if month < 1 || month > 12 {
+		return invalid("exp_month", "invalid_expiry_month", "Mois d'expiration invalide : %d", month)
+	}
+	if year >= 0 && year < 100 {
+		year += 2000
+	}
+	if year < 2000 || year > now.Year()+20 {
+		return invalid("exp_year", "invalid_expiry_year", "Année d'expiration invalide : %d", year)
+	}
+
+	// La carte expire à la fin du mois d'expiration
+	endOfMonth := time.Date(year, time.Month(month)+1, 1, 0, 0, 0, 0, now.Location())
+	if !now.Before(endOfMonth) {
+		return invalid("exp_year", "expired", "Carte expirée (%02d/%d)", month, year)
+	}
+	return nil
+}
+
+// ValidateCVV contrôle le cryptogramme : 4 chiffres pour Amex, 3 pour les autres réseaux.
+func ValidateCVV(cvv string, brand Brand) error {
+	expected := 3
+	for _, rule := range brandRules {
+		if rule.brand == brand {
+			expected = rule.cvv
+		}
+	}
+
+	if len(cvv) != expected {
+		return invalid("cvv", "invalid_cvv_length", "Le cryptogramme d'une carte %s doit comporter %d chiffres", brand, expected)
+	}
+	for _, r := range cvv {
+		if r < '0' || r > '9' {
+			return invalid("cvv", "invalid_cvv", "Le cryptogramme ne doit contenir que des chiffres")
+		}
+	}
+	return nil
+}
+
+// Validate enchaîne tous les contrôles et retourne la carte normalisée.
+// Les cartes de test ne sont acceptées que si allowTestCards est vrai.
+func Validate(details Details, now time.Time, allowTestCards bool) (Card, error) {
+	number, brand, err := ValidateNumber(details.Number)
+	if err != nil {
+		return Card{}, err
+	}
+	if !allowTestCards && IsTestCard(number) {
+		return Card{}, invalid("card", "test_card", "Les cartes de test ne sont pas acceptées")
+	}
+	if err := ValidateExpiry(details.ExpMonth, details.ExpYear, now); err != nil {
+		return Card{}, err
+	}
+	if err := ValidateCVV(details.CVV, brand); err != nil {
+		return Card{}, err
+	}
+
+	return Card{Number: number, Brand: brand, Last4: number[len(number)-4:]}, nil
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// InvoiceIssuer : mentions de l'entreprise imprimées sur les factures et avoirs
+type InvoiceIssuer struct {
+	Name      string
+	Address   string // une ligne par "\n"
+	SIRET     string
+	VATNumber string // numéro de TVA intracommunautaire
+}
+
+// Issuer lit les mentions de l'émetteur depuis COMPANY_NAME, COMPANY_ADDRESS,
+// COMPANY_SIRET et COMPANY_VAT_NUMBER.
+func Issuer() InvoiceIssuer {
+	name := os.Getenv("COMPANY_NAME")
+	if name == "" {
+		name = "H3 Travel"
+	}
+	return InvoiceIssuer{
+		Name:      name,
+		Address:   strings.ReplaceAll(os.Getenv("COMPANY_ADDRESS"), `\n`, "\n"),
+		SIRET:     os.Getenv("COMPANY_SIRET"),
+		VATNumber: os.Getenv("COMPANY_VAT_NUMBER"),
+	}
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"h3-travel/payment"
+	"log"
+	"os"
+)
+
+var Payment payment.Provider
+
+// AllowTestCards : les cartes de test sont acceptées avec le prestataire mock,
+// ou si ALLOW_TEST_CARDS=true
+func AllowTestCards() bool {
+	if value := os.Getenv("ALLOW_TEST_CARDS"); value != "" {
+		return value == "true"
+	}
+	provider := os.Getenv("PAYMENT_PROVIDER")
+	return provider == "" || provider == "mock"
+}
+
+// SetupPayment instancie le prestataire de paiement choisi par PAYMENT_PROVIDER (mock par défaut)
+func SetupPayment() {
+	provider, err := payment.New(os.Getenv("PAYMENT_PROVIDER"))
+	if err != nil {
+		log.Fatal("Failed to set up payment provider: ", err)
+	}
+
+	Payment = provider
+	log.Println("Payment provider:", provider.Name())
+}
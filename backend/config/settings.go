@@ -0,0 +1,52 @@
+package config
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// durationEnv lit une durée Go ("15m", "30s"...) depuis l'environnement
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Valeur invalide pour %s (%q), utilisation de %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// OrderHoldTTL : durée pendant laquelle une commande en attente de paiement réserve ses places
+func OrderHoldTTL() time.Duration {
+	return durationEnv("ORDER_HOLD_TTL", 15*time.Minute)
+}
+
+// HoldSweepInterval : fréquence de passage du job qui expire les réservations
+func HoldSweepInterval() time.Duration {
+	return durationEnv("HOLD_SWEEP_INTERVAL", time.Minute)
+}
+
+// IdempotencyKeyTTL : durée de conservation des réponses associées à un Idempotency-Key
+func IdempotencyKeyTTL() time.Duration {
+	return durationEnv("IDEMPOTENCY_KEY_TTL", 24*time.Hour)
+}
+
+// WaitlistOfferTTL : délai laissé à un inscrit de la liste d'attente pour payer la place proposée
+func WaitlistOfferTTL() time.Duration {
+	return durationEnv("WAITLIST_OFFER_TTL", 30*time.Minute)
+}
+
+// CatalogFacetsTTL : durée pendant laquelle les facettes du catalogue calculées pour une recherche sont réutilisées
+func CatalogFacetsTTL() time.Duration {
+	return durationEnv("CATALOG_FACETS_TTL", 30*time.Second)
+}
+
+// PassengerEditCutoff : délai avant le départ au-delà duquel les voyageurs d'une commande ne sont plus modifiables
+func PassengerEditCutoff() time.Duration {
+	return durationEnv("PASSENGER_EDIT_CUTOFF", 48*time.Hour)
+}
@@ -0,0 +1,20 @@
+package config
+
+import (
+	"h3-travel/vault"
+	"log"
+	"os"
+)
+
+// VaultKey : clé AES-256 du coffre de tokenisation des cartes
+var VaultKey []byte
+
+// SetupVault charge CARD_VAULT_KEY (32 octets encodés en base64, ex. `openssl rand -base64 32`)
+func SetupVault() {
+	key, err := vault.ParseKey(os.Getenv("CARD_VAULT_KEY"))
+	if err != nil {
+		log.Fatal("Invalid CARD_VAULT_KEY: ", err)
+	}
+
+	VaultKey = key
+}
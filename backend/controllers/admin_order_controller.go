@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"h3-travel/config"
+	"h3-travel/models"
+	"h3-travel/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- REFUND ORDER (ADMIN) ---
+// AdminRefundOrder godoc
+// @Summary Rembourse une commande
+// @Description Permet à un admin de rembourser tout ou partie du solde remboursable d'une commande, sur la carte ou en avoir sur le porte-monnaie du client (to_wallet). Un remboursement sur carte refusé par le prestataire reste dû (status failed) et se relance via /admin/orders/{id}/refunds/retry.
+// @Tags Admin Orders
+// @Accept json
+// @Produce json
+// @Param id path int true "ID de la commande"
+// @Param input body models.RefundInput true "Montant (vide : tout le solde), motif et destination"
+// @Success 200 {object} models.Order
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/orders/{id}/refund [post]
+func AdminRefundOrder(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	var input models.RefundInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := services.RefundOrder(c.Request.Context(), config.DB, config.Payment, uint(orderID), input.Amount, input.ToWallet, input.Reason, models.ActorAdmin(c.GetUint("user_id")))
+	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// --- RETRY REFUNDS (ADMIN) ---
+// AdminRetryRefunds godoc
+// @Summary Relance les remboursements refusés
+// @Description Permet à un admin de retransmettre au prestataire les remboursements sur carte d'une commande qu'il avait refusés (status failed)
+// @Tags Admin Orders
+// @Produce json
+// @Param id path int true "ID de la commande"
+// @Success 200 {object} models.Order
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/orders/{id}/refunds/retry [post]
+func AdminRetryRefunds(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	order, err := services.RetryFailedRefunds(c.Request.Context(), config.DB, config.Payment, uint(orderID))
+	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// --- LIST ORDERS (ADMIN) ---
+// AdminListOrders godoc
+// @Summary Liste toutes les commandes
+// @Description Permet à un admin de rechercher les commandes par statut, travel, utilisateur et période de création ; le nombre total de résultats est renvoyé dans l'en-tête X-Total-Count
+// @Tags Admin Orders
+// @Produce json
+// @Param status query string false "Statut de la commande"
+// @Param travel_id query int false "ID d'un travel présent dans la commande"
+// @Param user_id query int false "ID de l'acheteur"
+// @Param from query string false "Créées à partir du (AAAA-MM-JJ)"
+// @Param to query string false "Créées jusqu'au (AAAA-MM-JJ, inclus)"
+// @Param page query int false "Page (1 par défaut)"
+// @Param page_size query int false "Taille de page (50 par défaut, 200 max)"
+// @Success 200 {array} models.Order
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/orders [get]
+func AdminListOrders(c *gin.Context) {
+	var filter models.AdminOrderFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	orders, total, err := services.ListOrders(config.DB, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, orders)
+}
+
+// --- GET ORDER (ADMIN) ---
+// AdminGetOrder godoc
+// @Summary Détail d'une commande
+// @Description Permet à un admin de consulter n'importe quelle commande avec son acheteur, ses travels, son historique, ses paiements et ses notes internes
+// @Tags Admin Orders
+// @Produce json
+// @Param id path int true "ID de la commande"
+// @Success 200 {object} models.AdminOrderDetails
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/orders/{id} [get]
+func AdminGetOrder(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	details, err := services.GetOrderDetails(config.DB, uint(orderID))
+	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, details)
+}
+
+// --- CONFIRM ORDER (ADMIN) ---
+// AdminConfirmOrder godoc
+// @Summary Confirme une commande
+// @Description Permet à un admin de confirmer une commande payée, une fois le voyage validé auprès des prestataires. Les commandes sont ensuite terminées automatiquement après le départ.
+// @Tags Admin Orders
+// @Produce json
+// @Param id path int true "ID de la commande"
+// @Success 200 {object} models.Order
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/orders/{id}/confirm [put]
+func AdminConfirmOrder(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	order, err := services.ConfirmOrder(config.DB, uint(orderID), models.ActorAdmin(c.GetUint("user_id")))
+	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+// --- CANCEL ORDER (ADMIN) ---
+// AdminCancelOrder godoc
+// @Summary Annule une commande
+// @Description Permet à un admin d'annuler la commande de n'importe quel utilisateur ; par défaut tout le solde est remboursé, "policy" applique la politique d'annulation et "none" ne rembourse rien ; to_wallet rembourse en avoir sur le porte-monnaie
+// @Tags Admin Orders
+// @Accept json
+// @Produce json
+// @Param id path int true "ID de la commande"
+// @Param input body models.AdminCancelOrderInput false "Mode de remboursement et motif"
+// @Param Idempotency-Key header string false "Clé rendant la requête rejouable sans doublon"
+// @Success 200 {object} models.CancelOrderResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/orders/{id}/cancel [put]
+func AdminCancelOrder(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	var input models.AdminCancelOrderInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	order, quote, err := services.ForceCancelOrder(c.Request.Context(), config.DB, config.Payment, uint(orderID), input.Refund, input.ToWallet, input.Reason, models.ActorAdmin(c.GetUint("user_id")), config.WaitlistOfferTTL())
+	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.CancelOrderResponse{Order: order, Refund: quote})
+}
+
+// --- ADD ORDER NOTE (ADMIN) ---
+// AdminAddOrderNote godoc
+// @Summary Ajoute une note interne à une commande
+// @Description Les notes ne sont visibles que des admins
+// @Tags Admin Orders
+// @Accept json
+// @Produce json
+// @Param id path int true "ID de la commande"
+// @Param input body models.OrderNoteInput true "Contenu de la note"
+// @Success 200 {object} models.OrderNote
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/orders/{id}/notes [post]
+func AdminAddOrderNote(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	var input models.OrderNoteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	note, err := services.AddOrderNote(config.DB, uint(orderID), c.GetUint("user_id"), input.Body)
+	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, note)
+}
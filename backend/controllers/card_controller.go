@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"errors"
+	"h3-travel/card"
+	"h3-travel/config"
+	"h3-travel/models"
+	"h3-travel/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondCardError renvoie le contrôle de carte qui a échoué
+func respondCardError(c *gin.Context, err error) {
+	var invalid *card.ValidationError
+	if errors.As(err, &invalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalid.Message, "field": invalid.Field, "code": invalid.Code})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// --- TOKENIZE CARD ---
+// TokenizeCard godoc
+// @Summary Tokenise une carte bancaire
+// @Description Valide la carte (réseau, longueur, Luhn, expiration, cryptogramme) et l'échange contre un token opaque utilisable pour payer. Le numéro est stocké chiffré, le cryptogramme n'est pas conservé.
+// @Tags Cards
+// @Accept json
+// @Produce json
+// @Param input body models.TokenizeCardInput true "Carte à tokeniser"
+// @Success 200 {object} models.CardToken
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /cards/tokenize [post]
+func TokenizeCard(c *gin.Context) {
+	var input models.TokenizeCardInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Données de carte invalides"})
+		return
+	}
+
+	validated, err := card.Validate(card.Details{
+		Number:   input.Card,
+		ExpMonth: input.ExpMonth,
+		ExpYear:  input.ExpYear,
+		CVV:      input.CVV,
+	}, time.Now(), config.AllowTestCards())
+	if err != nil {
+		respondCardError(c, err)
+		return
+	}
+
+	token, err := services.TokenizeCard(config.DB, config.VaultKey, c.GetUint("user_id"), validated, input.ExpMonth, input.ExpYear)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Tokenisation impossible"})
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
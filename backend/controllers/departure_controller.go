@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"errors"
+	"h3-travel/config"
+	"h3-travel/models"
+	"h3-travel/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func respondDepartureError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTravelNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Travel non trouvé"})
+	case errors.Is(err, services.ErrDepartureNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Départ non trouvé"})
+	case errors.Is(err, services.ErrDepartureInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDepartureBooked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// departureParams lit les IDs du travel et, si withDeparture, du départ ; renvoie
+// false si la réponse d'erreur a été écrite.
+func departureParams(c *gin.Context, withDeparture bool) (travelID, departureID uint, ok bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return 0, 0, false
+	}
+	if !withDeparture {
+		return uint(id), 0, true
+	}
+	departure, err := strconv.ParseUint(c.Param("departure_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID de départ invalide"})
+		return 0, 0, false
+	}
+	return uint(id), uint(departure), true
+}
+
+// --- LIST DEPARTURES ---
+// ListDepartures godoc
+// @Summary Liste les départs d'un travel
+// @Description Départs à venir, par date, avec leurs places restantes ; un départ passé ou complet n'est pas réservable (bookable à false)
+// @Tags Departures
+// @Produce json
+// @Param id path int true "ID du travel"
+// @Param include_past query bool false "Inclut les départs passés"
+// @Success 200 {array} models.Departure
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /travels/{id}/departures [get]
+func ListDepartures(c *gin.Context) {
+	travelID, _, ok := departureParams(c, false)
+	if !ok {
+		return
+	}
+
+	departures, err := services.ListDepartures(config.DB, travelID, c.Query("include_past") == "true", time.Now())
+	if err != nil {
+		respondDepartureError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, departures)
+}
+
+// --- CREATE DEPARTURE ---
+// CreateDeparture godoc
+// @Summary Ajoute un départ à un travel
+// @Description Permet à un admin d'ouvrir une date : autant de places que la capacité, au prix du travel sauf si price est fourni (dans la devise du travel). Une fois qu'il a des départs, le travel se réserve départ par départ.
+// @Tags Departures
+// @Accept json
+// @Produce json
+// @Param id path int true "ID du travel"
+// @Param input body models.DepartureInput true "Départ"
+// @Success 200 {object} models.Departure
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /travels/{id}/departures [post]
+func CreateDeparture(c *gin.Context) {
+	travelID, _, ok := departureParams(c, false)
+	if !ok {
+		return
+	}
+
+	var input models.DepartureInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	departure, err := services.CreateDeparture(config.DB, travelID, input)
+	if err != nil {
+		respondDepartureError(c, err)
+		return
+	}
+	services.ClearTravelFacets()
+
+	c.JSON(http.StatusOK, departure)
+}
+
+// --- UPDATE DEPARTURE ---
+// UpdateDeparture godoc
+// @Summary Met à jour un départ
+// @Description Remplace les dates, la capacité et le prix du départ. Les places restantes suivent la capacité, qui ne peut pas descendre sous les places déjà réservées.
+// @Tags Departures
+// @Accept json
+// @Produce json
+// @Param id path int true "ID du travel"
+// @Param departure_id path int true "ID du départ"
+// @Param input body models.DepartureInput true "Départ"
+// @Success 200 {object} models.Departure
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /travels/{id}/departures/{departure_id} [put]
+func UpdateDeparture(c *gin.Context) {
+	travelID, departureID, ok := departureParams(c, true)
+	if !ok {
+		return
+	}
+
+	var input models.DepartureInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	departure, err := services.UpdateDeparture(config.DB, travelID, departureID, input)
+	if err != nil {
+		respondDepartureError(c, err)
+		return
+	}
+	services.ClearTravelFacets()
+
+	c.JSON(http.StatusOK, departure)
+}
+
+// --- DELETE DEPARTURE ---
+// DeleteDeparture godoc
+// @Summary Supprime un départ
+// @Description Seul un départ sur lequel aucune place n'est réservée peut être supprimé
+// @Tags Departures
+// @Produce json
+// @Param id path int true "ID du travel"
+// @Param departure_id path int true "ID du départ"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /travels/{id}/departures/{departure_id} [delete]
+func DeleteDeparture(c *gin.Context) {
+	travelID, departureID, ok := departureParams(c, true)
+	if !ok {
+		return
+	}
+
+	if err := services.DeleteDeparture(config.DB, travelID, departureID); err != nil {
+		respondDepartureError(c, err)
+		return
+	}
+	services.ClearTravelFacets()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Départ supprimé"})
+}
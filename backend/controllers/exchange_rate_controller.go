@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"h3-travel/config"
+	"h3-travel/models"
+	"h3-travel/money"
+	"h3-travel/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- LIST EXCHANGE RATES (ADMIN) ---
+// AdminListExchangeRates godoc
+// @Summary Liste les taux de change
+// @Tags Admin Rates
+// @Produce json
+// @Success 200 {array} models.ExchangeRate
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/rates [get]
+func AdminListExchangeRates(c *gin.Context) {
+	rates, err := services.ListExchangeRates(config.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rates)
+}
+
+// --- SET EXCHANGE RATE (ADMIN) ---
+// AdminSetExchangeRate godoc
+// @Summary Définit un taux de change
+// @Description Crée ou remplace le taux base -> quote (1 base = rate quote), utilisé pour afficher les prix dans une autre devise ; le taux inverse est déduit automatiquement
+// @Tags Admin Rates
+// @Accept json
+// @Produce json
+// @Param base path string true "Devise de départ (ISO 4217)"
+// @Param quote path string true "Devise d'arrivée (ISO 4217)"
+// @Param input body models.ExchangeRateInput true "Taux"
+// @Success 200 {object} models.ExchangeRate
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/rates/{base}/{quote} [put]
+func AdminSetExchangeRate(c *gin.Context) {
+	base, err := money.NormalizeCurrency(c.Param("base"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Devise invalide"})
+		return
+	}
+	quote, err := money.NormalizeCurrency(c.Param("quote"))
+	if err != nil || quote == base {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Devise invalide"})
+		return
+	}
+
+	var input models.ExchangeRateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rate, err := services.SetExchangeRate(config.DB, base, quote, input.Rate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
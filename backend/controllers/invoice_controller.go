@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"errors"
+	"h3-travel/config"
+	"h3-travel/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sendInvoicePDF envoie la facture (number vide) ou l'avoir d'une commande de l'utilisateur connecté.
+func sendInvoicePDF(c *gin.Context, number string) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	content, invoice, err := services.InvoicePDF(config.DB, config.Issuer(), c.GetUint("user_id"), uint(orderID), number)
+	if err != nil {
+		if errors.Is(err, services.ErrInvoiceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Facture non trouvée"})
+			return
+		}
+		respondOrderError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", `inline; filename="`+invoice.Number+`.pdf"`)
+	c.Data(http.StatusOK, "application/pdf", content)
+}
+
+// --- INVOICE ---
+// GetInvoice godoc
+// @Summary Télécharge la facture d'une commande
+// @Description Facture PDF émise au paiement de la commande : lignes, TVA par taux, totaux HT, TVA et TTC
+// @Tags Orders
+// @Produce application/pdf
+// @Param id path int true "ID de la commande"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /orders/{id}/invoice [get]
+func GetInvoice(c *gin.Context) {
+	sendInvoicePDF(c, "")
+}
+
+// --- CREDIT NOTE ---
+// GetCreditNote godoc
+// @Summary Télécharge un avoir d'une commande
+// @Description Avoir PDF émis lors d'un remboursement ; les numéros figurent dans le champ invoices de la commande
+// @Tags Orders
+// @Produce application/pdf
+// @Param id path int true "ID de la commande"
+// @Param number path string true "Numéro de l'avoir (AV-2026-000001)"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /orders/{id}/credit-notes/{number} [get]
+func GetCreditNote(c *gin.Context) {
+	sendInvoicePDF(c, c.Param("number"))
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"h3-travel/config"
+	"h3-travel/models"
+	"h3-travel/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- GET LOYALTY ---
+// GetLoyalty godoc
+// @Summary Points de fidélité de l'utilisateur
+// @Description Solde de points et sa valeur, niveau atteint selon les dépenses des 12 derniers mois, et dernières écritures (gains, dépenses, reprises, expirations)
+// @Tags Loyalty
+// @Produce json
+// @Success 200 {object} models.LoyaltySummary
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /me/loyalty [get]
+func GetLoyalty(c *gin.Context) {
+	summary, err := services.GetLoyalty(config.DB, c.GetUint("user_id"), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// --- GET LOYALTY PROGRAM (ADMIN) ---
+// AdminGetLoyaltyProgram godoc
+// @Summary Paramètres du programme de fidélité
+// @Description Taux de gain, valeur d'un point, part maximale payable en points, durée de validité et niveaux
+// @Tags Admin Loyalty
+// @Produce json
+// @Success 200 {object} models.LoyaltyProgram
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/loyalty [get]
+func AdminGetLoyaltyProgram(c *gin.Context) {
+	program, err := services.LoadLoyaltyProgram(config.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, program)
+}
+
+// --- SET LOYALTY PROGRAM (ADMIN) ---
+// AdminSetLoyaltyProgram godoc
+// @Summary Configure le programme de fidélité
+// @Description Remplace les paramètres et les niveaux. Les montants sont en unités mineures de la devise par défaut. Les points déjà acquis gardent leur date d'expiration.
+// @Tags Admin Loyalty
+// @Accept json
+// @Produce json
+// @Param input body models.LoyaltyProgramInput true "Paramètres du programme"
+// @Success 200 {object} models.LoyaltyProgram
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/loyalty [put]
+func AdminSetLoyaltyProgram(c *gin.Context) {
+	var input models.LoyaltyProgramInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	program, err := services.SaveLoyaltyProgram(config.DB, input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, program)
+}
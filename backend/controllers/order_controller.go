@@ -1,25 +1,90 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"h3-travel/card"
 	"h3-travel/config"
 	"h3-travel/models"
-	"h3-travel/utils"
+	"h3-travel/money"
+	"h3-travel/payment"
+	"h3-travel/services"
+	"io"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
+// respondOrderError traduit les erreurs du service commandes en réponses HTTP
+func respondOrderError(c *gin.Context, err error) {
+	var transition *models.TransitionError
+	var invalidCard *card.ValidationError
+	switch {
+	case errors.Is(err, services.ErrTravelNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Travel non trouvé"})
+	case errors.Is(err, services.ErrDepartureNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Départ non trouvé"})
+	case errors.Is(err, services.ErrDepartureRequired), errors.Is(err, services.ErrDepartureInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTravelUnavailable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Travel indisponible"})
+	case errors.Is(err, services.ErrCurrencyMismatch), errors.Is(err, services.ErrPassengerInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, money.ErrCurrencyMismatch):
+		// montants incohérents en base : la commande ne peut pas être traitée en l'état
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrWalletInsufficient):
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrLoyaltyInsufficient), errors.Is(err, services.ErrLoyaltyUnavailable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPassengersLocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPromoNotFound), errors.Is(err, services.ErrPromoInactive),
+		errors.Is(err, services.ErrPromoMinOrder), errors.Is(err, services.ErrPromoNotApplicable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPromoExhausted), errors.Is(err, services.ErrPromoUserLimit):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Commande non trouvée"})
+	case errors.Is(err, services.ErrCardTokenNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token de carte invalide"})
+	case errors.As(err, &invalidCard):
+		respondCardError(c, err)
+	case errors.Is(err, services.ErrInvalidRefundAmount), errors.Is(err, services.ErrRefundExceedsBalance):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrHoldExpired):
+		c.JSON(http.StatusGone, gin.H{"error": "Réservation expirée"})
+	case errors.Is(err, payment.ErrDeclined), errors.Is(err, payment.ErrInsufficientFunds):
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+	case errors.Is(err, payment.ErrTimeout):
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+	case errors.As(err, &transition):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Transition de statut invalide",
+			"from":  transition.From,
+			"to":    transition.To,
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
 // --- CREATE ORDER ---
 // CreateOrder godoc
 // @Summary Crée une commande
-// @Description Permet à un utilisateur de créer une commande pour un travel
+// @Description Réserve une ou plusieurs places sur un ou plusieurs travels (au départ departure_id pour un travel qui a des départs datés), en appliquant le code promo éventuel ; la commande reste en attente de paiement jusqu'à son expiration. Les voyageurs de chaque ligne (un par place) peuvent être saisis dès la commande ou plus tard via /orders/{id}/passengers. wallet_amount est prélevé sur le porte-monnaie ; si celui-ci couvre tout le total, la commande est payée immédiatement. loyalty_points sont dépensés en remise, dans la limite de la part du total fixée par le programme de fidélité.
 // @Tags Orders
 // @Accept json
 // @Produce json
 // @Param input body models.CreateOrderInput true "Informations pour la commande"
+// @Param Idempotency-Key header string false "Clé rendant la requête rejouable sans doublon"
 // @Success 200 {object} models.Order
 // @Failure 400 {object} map[string]string
+// @Failure 402 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /orders [post]
@@ -30,46 +95,62 @@ func CreateOrder(c *gin.Context) {
 		return
 	}
 
-	// Vérifie la validité de la carte
-	if !utils.ValidateCardNumber(input.Card) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Numéro de carte invalide"})
+	// Réserve les places de toutes les lignes et crée la commande de façon atomique
+	order, err := services.CreateOrder(config.DB, config.VaultKey, c.GetUint("user_id"), input, config.OrderHoldTTL())
+	if err != nil {
+		respondOrderError(c, err)
 		return
 	}
 
-	// Vérifie le stock
-	var travel models.Travel
-	if err := config.DB.First(&travel, input.TravelID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Travel non trouvé"})
-		return
-	}
+	c.JSON(http.StatusOK, order)
+}
 
-	if travel.Stock <= 0 || !travel.Active {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Travel indisponible"})
+// --- CONFIRM PAYMENT ---
+// ConfirmPayment godoc
+// @Summary Paie une commande en attente
+// @Description Fait autoriser puis capturer le paiement d'une réservation non expirée par le prestataire configuré, avec une carte tokenisée, et la passe à l'état payé. Seul le reste à payer après la part prélevée sur le porte-monnaie est débité de la carte.
+// @Tags Orders
+// @Accept json
+// @Produce json
+// @Param id path int true "ID de la commande"
+// @Param input body models.ConfirmPaymentInput true "Informations de paiement"
+// @Param Idempotency-Key header string false "Clé rendant la requête rejouable sans doublon"
+// @Success 200 {object} models.Order
+// @Failure 400 {object} map[string]string
+// @Failure 402 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 504 {object} map[string]string
+// @Security BearerAuth
+// @Router /orders/{id}/pay [post]
+func ConfirmPayment(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
 		return
 	}
 
-	// Crée la commande
-	order := models.Order{
-		UserID:   c.GetUint("user_id"),
-		TravelID: input.TravelID,
-		Statut:   "paid",
+	var input models.ConfirmPaymentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	if err := config.DB.Create(&order).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	order, err := services.ConfirmPayment(c.Request.Context(), config.DB, config.Payment, config.VaultKey, c.GetUint("user_id"), uint(orderID), input.CardToken)
+	if err != nil {
+		respondOrderError(c, err)
 		return
 	}
 
-	// Décrémente le stock
-	config.DB.Model(&travel).Update("Stock", travel.Stock-1)
-
 	c.JSON(http.StatusOK, order)
 }
 
 // --- LIST USER ORDERS ---
 // GetUserOrders godoc
 // @Summary Liste des commandes d'un utilisateur
-// @Description Récupère toutes les commandes de l'utilisateur connecté
+// @Description Récupère toutes les commandes de l'utilisateur connecté, avec pour chaque ligne le titre, le prix, la devise et la remise figés à l'achat ainsi qu'un lien vers le travel actuel
 // @Tags Orders
 // @Produce json
 // @Success 200 {object} models.Order
@@ -84,44 +165,87 @@ func GetUserOrders(c *gin.Context) {
 	}
 
 	var orders []models.Order
-	config.DB.Where("user_id = ?", userID.(uint)).Find(&orders)
+	config.DB.Preload("Items").Preload("Refunds").Preload("Invoices").Where("user_id = ?", userID.(uint)).Find(&orders)
+	for i := range orders {
+		for j := range orders[i].Items {
+			orders[i].Items[j].TravelURL = travelURL(orders[i].Items[j].TravelID)
+		}
+	}
 	c.JSON(http.StatusOK, orders)
 }
 
+// travelURL : lien vers la fiche actuelle d'un travel
+func travelURL(travelID uint) string {
+	return fmt.Sprintf("/api/v1/travels/%d", travelID)
+}
+
+// --- CANCELLATION QUOTE ---
+// GetCancellationQuote godoc
+// @Summary Simule l'annulation d'une commande
+// @Description Calcule le remboursement qu'obtiendrait l'utilisateur en annulant maintenant, selon la politique d'annulation de chaque travel
+// @Tags Orders
+// @Produce json
+// @Param id path int true "ID de la commande"
+// @Success 200 {object} models.CancellationQuote
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /orders/{id}/cancellation-quote [get]
+func GetCancellationQuote(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	quote, err := services.QuoteCancellation(config.DB, c.GetUint("user_id"), uint(orderID))
+	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, quote)
+}
+
 // --- CANCEL ORDER ---
 // CancelOrder godoc
 // @Summary Annule une commande
-// @Description Permet à un utilisateur d'annuler une commande si elle est encore payée
+// @Description Permet à un utilisateur d'annuler une commande en attente de paiement ou payée : toutes les lignes sont remises en stock et le remboursement prévu par la politique d'annulation est effectué, sur la carte ou en avoir sur le porte-monnaie (to_wallet). La part prélevée sur le porte-monnaie d'une commande non payée y est rendue.
 // @Tags Orders
+// @Accept json
 // @Produce json
 // @Param id path int true "ID de la commande"
-// @Success 200 {object} models.Order
+// @Param input body models.CancelOrderInput false "Remboursement en avoir sur le porte-monnaie"
+// @Param Idempotency-Key header string false "Clé rendant la requête rejouable sans doublon"
+// @Success 200 {object} models.CancelOrderResponse
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /orders/{id}/cancel [put]
 func CancelOrder(c *gin.Context) {
 	userID := c.GetUint("user_id")
-	orderID := c.Param("id")
-
-	var order models.Order
-	if err := config.DB.First(&order, "id = ? AND user_id = ?", orderID, userID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Commande non trouvée"})
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
 		return
 	}
 
-	if order.Statut != "paid" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Impossible d'annuler"})
+	// Corps facultatif : sans lui, le remboursement revient sur la carte
+	var input models.CancelOrderInput
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	order.Statut = "cancelled"
-	config.DB.Save(&order)
-
-	// Restock le travel
-	var travel models.Travel
-	config.DB.First(&travel, order.TravelID)
-	config.DB.Model(&travel).Update("Stock", travel.Stock+1)
+	order, quote, err := services.CancelOrder(c.Request.Context(), config.DB, config.Payment, userID, uint(orderID), input.ToWallet, config.WaitlistOfferTTL())
+	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
 
-	c.JSON(http.StatusOK, order)
+	c.JSON(http.StatusOK, models.CancelOrderResponse{Order: order, Refund: quote})
 }
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"h3-travel/models"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setPaginationHeaders renseigne X-Total-Count et l'en-tête Link (first, prev,
+// next, last) de la page, en conservant les autres paramètres de la requête.
+func setPaginationHeaders(c *gin.Context, p models.Pagination) {
+	c.Header("X-Total-Count", strconv.FormatInt(p.Total, 10))
+
+	link := func(page int, rel string) string {
+		query := c.Request.URL.Query()
+		query.Set("page", strconv.Itoa(page))
+		query.Set("page_size", strconv.Itoa(p.PageSize))
+		return "<" + c.Request.URL.Path + "?" + query.Encode() + `>; rel="` + rel + `"`
+	}
+
+	last := p.TotalPages
+	if last < 1 {
+		last = 1
+	}
+	links := []string{link(1, "first")}
+	if p.Page > 1 {
+		links = append(links, link(min(p.Page-1, last), "prev"))
+	}
+	if p.Page < last {
+		links = append(links, link(p.Page+1, "next"))
+	}
+	links = append(links, link(last, "last"))
+	c.Header("Link", strings.Join(links, ", "))
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"h3-travel/config"
+	"h3-travel/models"
+	"h3-travel/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- GET PASSENGERS ---
+// GetPassengers godoc
+// @Summary Voyageurs d'une commande
+// @Description Retourne, déchiffrés, les voyageurs saisis sur chaque ligne de la commande de l'utilisateur connecté
+// @Tags Orders
+// @Produce json
+// @Param id path int true "ID de la commande"
+// @Success 200 {array} models.Passenger
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /orders/{id}/passengers [get]
+func GetPassengers(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	passengers, err := services.GetPassengers(config.DB, config.VaultKey, c.GetUint("user_id"), uint(orderID))
+	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, passengers)
+}
+
+// --- UPDATE PASSENGERS ---
+// UpdatePassengers godoc
+// @Summary Saisit ou modifie les voyageurs d'une commande
+// @Description Remplace les voyageurs des lignes citées (un par place réservée). Possible tant que la commande est en cours et jusqu'à PASSENGER_EDIT_CUTOFF (48 h par défaut) avant le départ.
+// @Tags Orders
+// @Accept json
+// @Produce json
+// @Param id path int true "ID de la commande"
+// @Param input body models.UpdatePassengersInput true "Voyageurs par travel"
+// @Success 200 {array} models.Passenger
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /orders/{id}/passengers [put]
+func UpdatePassengers(c *gin.Context) {
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	var input models.UpdatePassengersInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	passengers, err := services.UpdatePassengers(config.DB, config.VaultKey, c.GetUint("user_id"), uint(orderID), input.Items, config.PassengerEditCutoff())
+	if err != nil {
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, passengers)
+}
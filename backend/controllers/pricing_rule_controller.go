@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"errors"
+	"h3-travel/config"
+	"h3-travel/models"
+	"h3-travel/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func respondPricingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPricingRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Règle de tarification non trouvée"})
+	case errors.Is(err, services.ErrTravelNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Travel non trouvé"})
+	case errors.Is(err, services.ErrPricingRuleInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// --- LIST PRICING RULES (ADMIN) ---
+// AdminListPricingRules godoc
+// @Summary Liste les règles de tarification
+// @Tags Admin Pricing
+// @Produce json
+// @Success 200 {array} models.PricingRule
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/pricing-rules [get]
+func AdminListPricingRules(c *gin.Context) {
+	rules, err := services.ListPricingRules(config.DB)
+	if err != nil {
+		respondPricingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// --- GET PRICING RULE (ADMIN) ---
+// AdminGetPricingRule godoc
+// @Summary Récupère une règle de tarification
+// @Tags Admin Pricing
+// @Produce json
+// @Param id path int true "ID de la règle"
+// @Success 200 {object} models.PricingRule
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/pricing-rules/{id} [get]
+func AdminGetPricingRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	rule, err := services.GetPricingRule(config.DB, uint(id))
+	if err != nil {
+		respondPricingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// --- CREATE PRICING RULE (ADMIN) ---
+// AdminCreatePricingRule godoc
+// @Summary Crée une règle de tarification
+// @Description early_bird : ajustement à partir de days jours avant le départ ; last_minute : à days jours ou moins du départ ; occupancy : quand les places restantes passent sous stock_percent % de la capacité. percent négatif pour une remise, positif pour une majoration. Sans travel_id, la règle vaut pour tous les travels.
+// @Tags Admin Pricing
+// @Accept json
+// @Produce json
+// @Param input body models.PricingRuleInput true "Règle de tarification"
+// @Success 200 {object} models.PricingRule
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/pricing-rules [post]
+func AdminCreatePricingRule(c *gin.Context) {
+	var input models.PricingRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := services.SavePricingRule(config.DB, 0, input)
+	if err != nil {
+		respondPricingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// --- UPDATE PRICING RULE (ADMIN) ---
+// AdminUpdatePricingRule godoc
+// @Summary Met à jour une règle de tarification
+// @Tags Admin Pricing
+// @Accept json
+// @Produce json
+// @Param id path int true "ID de la règle"
+// @Param input body models.PricingRuleInput true "Règle de tarification"
+// @Success 200 {object} models.PricingRule
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/pricing-rules/{id} [put]
+func AdminUpdatePricingRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	var input models.PricingRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := services.SavePricingRule(config.DB, uint(id), input)
+	if err != nil {
+		respondPricingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// --- DELETE PRICING RULE (ADMIN) ---
+// AdminDeletePricingRule godoc
+// @Summary Supprime une règle de tarification
+// @Tags Admin Pricing
+// @Produce json
+// @Param id path int true "ID de la règle"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/pricing-rules/{id} [delete]
+func AdminDeletePricingRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	if err := services.DeletePricingRule(config.DB, uint(id)); err != nil {
+		respondPricingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Règle de tarification supprimée"})
+}
+
+// --- DRY RUN (ADMIN) ---
+// AdminPricingDryRun godoc
+// @Summary Simule le prix d'un travel
+// @Description Calcule le prix d'un travel avec les règles actives, sans rien enregistrer, éventuellement à une autre date (at) ou avec un autre stock restant (stock). Renvoie le prix de base, le prix calculé et les règles déclenchées.
+// @Tags Admin Pricing
+// @Accept json
+// @Produce json
+// @Param input body models.PricingDryRunInput true "Simulation"
+// @Success 200 {object} models.PriceQuote
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/pricing-rules/dry-run [post]
+func AdminPricingDryRun(c *gin.Context) {
+	var input models.PricingDryRunInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	quote, err := services.DryRunPricing(config.DB, input)
+	if err != nil {
+		respondPricingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, quote)
+}
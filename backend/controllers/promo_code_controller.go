@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"errors"
+	"h3-travel/config"
+	"h3-travel/models"
+	"h3-travel/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func respondPromoError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrPromoNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Code promo non trouvé"})
+	case errors.Is(err, services.ErrPromoInvalid), errors.Is(err, services.ErrTravelNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPromoCodeTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "Ce code promo existe déjà"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// --- LIST PROMO CODES (ADMIN) ---
+// AdminListPromoCodes godoc
+// @Summary Liste les codes promo
+// @Tags Admin Promo Codes
+// @Produce json
+// @Success 200 {array} models.PromoCode
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/promo-codes [get]
+func AdminListPromoCodes(c *gin.Context) {
+	promos, err := services.ListPromoCodes(config.DB)
+	if err != nil {
+		respondPromoError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, promos)
+}
+
+// --- GET PROMO CODE (ADMIN) ---
+// AdminGetPromoCode godoc
+// @Summary Récupère un code promo
+// @Tags Admin Promo Codes
+// @Produce json
+// @Param id path int true "ID du code promo"
+// @Success 200 {object} models.PromoCode
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/promo-codes/{id} [get]
+func AdminGetPromoCode(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	promo, err := services.GetPromoCode(config.DB, uint(id))
+	if err != nil {
+		respondPromoError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, promo)
+}
+
+// --- CREATE PROMO CODE (ADMIN) ---
+// AdminCreatePromoCode godoc
+// @Summary Crée un code promo
+// @Description Pourcentage ou montant fixe, avec période de validité, limites d'utilisation globale et par utilisateur, montant minimum de commande et restriction éventuelle à certains travels
+// @Tags Admin Promo Codes
+// @Accept json
+// @Produce json
+// @Param input body models.PromoCodeInput true "Code promo"
+// @Success 200 {object} models.PromoCode
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/promo-codes [post]
+func AdminCreatePromoCode(c *gin.Context) {
+	var input models.PromoCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promo, err := services.SavePromoCode(config.DB, 0, input)
+	if err != nil {
+		respondPromoError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, promo)
+}
+
+// --- UPDATE PROMO CODE (ADMIN) ---
+// AdminUpdatePromoCode godoc
+// @Summary Met à jour un code promo
+// @Description Remplace toutes les caractéristiques du code ; le nombre d'utilisations déjà comptées est conservé
+// @Tags Admin Promo Codes
+// @Accept json
+// @Produce json
+// @Param id path int true "ID du code promo"
+// @Param input body models.PromoCodeInput true "Code promo"
+// @Success 200 {object} models.PromoCode
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/promo-codes/{id} [put]
+func AdminUpdatePromoCode(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	var input models.PromoCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	promo, err := services.SavePromoCode(config.DB, uint(id), input)
+	if err != nil {
+		respondPromoError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, promo)
+}
+
+// --- DELETE PROMO CODE (ADMIN) ---
+// AdminDeletePromoCode godoc
+// @Summary Supprime un code promo
+// @Tags Admin Promo Codes
+// @Produce json
+// @Param id path int true "ID du code promo"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/promo-codes/{id} [delete]
+func AdminDeletePromoCode(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	if err := services.DeletePromoCode(config.DB, uint(id)); err != nil {
+		respondPromoError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Code promo supprimé"})
+}
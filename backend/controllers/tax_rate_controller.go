@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"errors"
+	"h3-travel/config"
+	"h3-travel/models"
+	"h3-travel/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- LIST TAX RATES (ADMIN) ---
+// AdminListTaxRates godoc
+// @Summary Liste les taux de TVA
+// @Description Taux de chaque catégorie de TVA, en points de base (2000 = 20 %)
+// @Tags Admin Taxes
+// @Produce json
+// @Success 200 {array} models.TaxRate
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/tax-rates [get]
+func AdminListTaxRates(c *gin.Context) {
+	rates, err := services.ListTaxRates(config.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rates)
+}
+
+// --- SET TAX RATE (ADMIN) ---
+// AdminSetTaxRate godoc
+// @Summary Définit le taux de TVA d'une catégorie
+// @Description Catégories : standard, intermediate, reduced, super_reduced, exempt. Le nouveau taux s'applique aux commandes suivantes ; les commandes existantes gardent celui de l'achat.
+// @Tags Admin Taxes
+// @Accept json
+// @Produce json
+// @Param category path string true "Catégorie de TVA"
+// @Param input body models.TaxRateInput true "Taux en points de base"
+// @Success 200 {object} models.TaxRate
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/tax-rates/{category} [put]
+func AdminSetTaxRate(c *gin.Context) {
+	var input models.TaxRateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rate, err := services.SetTaxRate(config.DB, c.Param("category"), *input.Rate)
+	if err != nil {
+		if errors.Is(err, services.ErrTaxCategoryUnknown) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Catégorie de TVA inconnue"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rate)
+}
@@ -1,18 +1,105 @@
 package controllers
 
 import (
+	"errors"
 	"h3-travel/config"
 	"h3-travel/models"
+	"h3-travel/money"
+	"h3-travel/services"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// normalizePrice valide la devise du prix saisi ; sans devise, celle par défaut est appliquée si requireCurrency.
+func normalizePrice(price *money.Money, requireCurrency bool) error {
+	if price.Currency == "" {
+		if requireCurrency {
+			price.Currency = money.DefaultCurrency
+		}
+		return nil
+	}
+
+	currency, err := money.NormalizeCurrency(price.Currency)
+	price.Currency = currency
+	return err
+}
+
+// applyPricing remplace le prix des travels par celui des règles de tarification ;
+// renvoie false si la réponse d'erreur a été écrite.
+func applyPricing(c *gin.Context, travels []models.Travel) bool {
+	if err := services.ApplyDynamicPricing(config.DB, travels, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// convertPrices applique le paramètre ?currency= ; renvoie false si la réponse d'erreur a été écrite.
+func convertPrices(c *gin.Context, travels []models.Travel) bool {
+	if c.Query("currency") == "" {
+		return true
+	}
+
+	currency, err := money.NormalizeCurrency(c.Query("currency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Devise invalide"})
+		return false
+	}
+
+	if err := services.ConvertTravelPrices(config.DB, travels, currency); err != nil {
+		if errors.Is(err, services.ErrRateUnavailable) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Taux de change indisponible vers " + currency})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+func respondTravelError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTravelInvalid), errors.Is(err, services.ErrTravelTypeImmutable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrStockOverCapacity):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Le stock ne peut pas dépasser la capacité"})
+	case errors.Is(err, services.ErrTravelNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Travel non trouvé"})
+	case errors.Is(err, services.ErrStockChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": "Le stock a changé pendant la mise à jour, réessayez"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// bindTravelFilter lit les critères de recherche du catalogue : un admin voit
+// par défaut tous les travels, les autres seulement les actifs. Renvoie false si
+// la réponse d'erreur a été écrite.
+func bindTravelFilter(c *gin.Context) (models.TravelFilter, bool) {
+	var filter models.TravelFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return filter, false
+	}
+	if c.GetString("role") == "admin" {
+		if filter.Active == "" {
+			filter.Active = "all"
+		}
+	} else if filter.Active != "" && filter.Active != "true" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Accès réservé aux admins"})
+		return filter, false
+	}
+	return filter, true
+}
+
 // --- CREATE ---
 // CreateTravel godoc
 // @Summary Crée un travel
-// @Description Permet à un admin de créer un nouveau travel
+// @Description Permet à un admin de créer un nouveau travel. Type : flight (attributs Flight), hotel (attributs Hotel) ou package (Components : au moins un vol et un hôtel du catalogue, par travel_id).
 // @Tags Travels
 // @Accept json
 // @Produce json
@@ -29,38 +116,118 @@ func CreateTravel(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := normalizePrice(&travel.Price, true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Devise invalide"})
+		return
+	}
+	if travel.Capacity == 0 {
+		travel.Capacity = travel.Stock
+	}
+	if !services.ValidTaxCategory(travel.TaxCategory) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Catégorie de TVA inconnue"})
+		return
+	}
+	if travel.TaxCategory == "" {
+		travel.TaxCategory = models.TaxStandard
+	}
 
-	if err := config.DB.Create(&travel).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := services.CreateTravel(config.DB, &travel); err != nil {
+		respondTravelError(c, err)
 		return
 	}
+	services.ClearTravelFacets()
 
 	c.JSON(http.StatusOK, travel)
 }
 
 // --- READ ALL ---
 // GetTravels godoc
-// @Summary Récupère tous les travels
-// @Description Permet à un admin de voir la liste de tous les travels
+// @Summary Liste le catalogue des travels
+// @Description Liste paginée des travels, filtrable et triable. q recherche dans le titre et la description (français ou anglais, sans tenir compte des accents) : les résultats sont alors triés par pertinence et Highlight et Snippet, échappés pour HTML, montrent les termes trouvés entre <mark>. Seuls les travels actifs sont visibles, sauf pour un admin qui les voit tous par défaut et peut filtrer avec active. Le filtre de prix porte sur le prix de catalogue ; Price tient compte des règles de tarification en vigueur (BasePrice et PricingRules détaillent l'ajustement). Chaque travel porte les attributs de son type (Flight, Hotel ou Components). Le total est aussi renvoyé dans l'en-tête X-Total-Count et les pages voisines dans l'en-tête Link.
 // @Tags Travels
 // @Produce json
-// @Success 200 {array} models.Travel
-// @Failure 401 {object} map[string]string
+// @Param q query string false "Recherche plein texte (plage, ski, Rome...)"
+// @Param type query string false "flight, hotel ou package"
+// @Param country query string false "Pays de destination (ISO 3166-1) : celui de l'hôtel, ou d'un hôtel du séjour"
+// @Param min_price query int false "Prix de catalogue minimum, en unités mineures"
+// @Param max_price query int false "Prix de catalogue maximum, en unités mineures"
+// @Param price_currency query string false "Devise de min_price et max_price (EUR par défaut)"
+// @Param active query string false "true, false ou all (false et all réservés aux admins)"
+// @Param in_stock query bool false "Seulement les travels ayant encore des places"
+// @Param created_from query string false "Créés à partir du (AAAA-MM-JJ)"
+// @Param created_to query string false "Créés jusqu'au (AAAA-MM-JJ, inclus)"
+// @Param sort query string false "relevance (par défaut avec q), price, created_at (par défaut sans q), title, departure_date ou stock ; préfixe - pour l'ordre décroissant"
+// @Param page query int false "Page (1 par défaut)"
+// @Param page_size query int false "Taille de page (20 par défaut, 100 max)"
+// @Param currency query string false "Devise d'affichage (ISO 4217) : ajoute DisplayPrice et DisplayRate"
+// @Success 200 {object} models.TravelPage
+// @Failure 400 {object} map[string]string
 // @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
 // @Router /travels [get]
 func GetTravels(c *gin.Context) {
-	var travels []models.Travel
-	config.DB.Find(&travels)
-	c.JSON(http.StatusOK, travels)
+	filter, ok := bindTravelFilter(c)
+	if !ok {
+		return
+	}
+
+	travels, page, err := services.ListTravels(config.DB, filter)
+	if err != nil {
+		respondTravelError(c, err)
+		return
+	}
+	if !applyPricing(c, travels) || !convertPrices(c, travels) {
+		return
+	}
+
+	setPaginationHeaders(c, page)
+	c.JSON(http.StatusOK, models.TravelPage{Data: travels, Pagination: page})
+}
+
+// --- FACETS ---
+// GetTravelFacets godoc
+// @Summary Facettes du catalogue
+// @Description Nombre de travels par type, tranche de prix de catalogue (dans la devise price_currency), pays de destination et disponibilité, pour les mêmes critères que la liste. Chaque facette ignore son propre critère pour montrer les autres choix possibles ; les comptes sont mis en cache quelques secondes.
+// @Tags Travels
+// @Produce json
+// @Param q query string false "Recherche plein texte (plage, ski, Rome...)"
+// @Param type query string false "flight, hotel ou package"
+// @Param country query string false "Pays de destination (ISO 3166-1)"
+// @Param min_price query int false "Prix de catalogue minimum, en unités mineures"
+// @Param max_price query int false "Prix de catalogue maximum, en unités mineures"
+// @Param price_currency query string false "Devise de min_price, max_price et des tranches de prix (EUR par défaut)"
+// @Param active query string false "true, false ou all (false et all réservés aux admins)"
+// @Param in_stock query bool false "Seulement les travels ayant encore des places"
+// @Param created_from query string false "Créés à partir du (AAAA-MM-JJ)"
+// @Param created_to query string false "Créés jusqu'au (AAAA-MM-JJ, inclus)"
+// @Success 200 {object} models.TravelFacets
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /travels/facets [get]
+func GetTravelFacets(c *gin.Context) {
+	filter, ok := bindTravelFilter(c)
+	if !ok {
+		return
+	}
+
+	facets, err := services.GetTravelFacets(config.DB, filter, config.CatalogFacetsTTL(), time.Now())
+	if err != nil {
+		respondTravelError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, facets)
 }
 
 // --- READ ONE ---
 // GetTravel godoc
 // @Summary Récupère un travel
-// @Description Permet à un admin de récupérer un travel par son ID
+// @Description Permet à un admin de récupérer un travel par son ID. Price tient compte des règles de tarification en vigueur (BasePrice et PricingRules détaillent l'ajustement). Chaque travel porte les attributs de son type (Flight, Hotel ou Components).
 // @Tags Travels
 // @Produce json
 // @Param id path int true "ID du travel"
+// @Param currency query string false "Devise d'affichage (ISO 4217) : ajoute DisplayPrice et DisplayRate"
 // @Success 200 {object} models.Travel
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
@@ -76,18 +243,22 @@ func GetTravel(c *gin.Context) {
 	}
 
 	var travel models.Travel
-	if err := config.DB.First(&travel, uint(id)).Error; err != nil {
+	if err := services.WithTravelDetails(config.DB).Preload("CancellationTiers").First(&travel, uint(id)).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Travel non trouvé"})
 		return
 	}
 
-	c.JSON(http.StatusOK, travel)
+	travels := []models.Travel{travel}
+	if !applyPricing(c, travels) || !convertPrices(c, travels) {
+		return
+	}
+	c.JSON(http.StatusOK, travels[0])
 }
 
 // --- UPDATE ---
 // UpdateTravel godoc
 // @Summary Met à jour un travel
-// @Description Permet à un admin de mettre à jour un travel existant
+// @Description Permet à un admin de mettre à jour un travel existant. Le type ne change pas ; Flight, Hotel ou Components, s'ils sont fournis, remplacent les attributs du type. Capacity, si elle est fournie, doit être au moins égale au stock ; sinon elle suit la variation du stock. Le stock s'applique en variation, sans écraser les réservations passées entretemps.
 // @Tags Travels
 // @Accept json
 // @Produce json
@@ -98,6 +269,7 @@ func GetTravel(c *gin.Context) {
 // @Failure 401 {object} map[string]string
 // @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /travels/{id} [put]
 // @Security BearerAuth
 func UpdateTravel(c *gin.Context) {
@@ -108,20 +280,79 @@ func UpdateTravel(c *gin.Context) {
 		return
 	}
 
-	var travel models.Travel
-	if err := config.DB.First(&travel, uint(id)).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Travel non trouvé"})
+	// Bind JSON pour les updates
+	var input models.Travel
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := normalizePrice(&input.Price, false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Devise invalide"})
+		return
+	}
+	if !services.ValidTaxCategory(input.TaxCategory) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Catégorie de TVA inconnue"})
 		return
 	}
 
-	// Bind JSON pour les updates
-	var input models.Travel
+	travel, previousStock, err := services.UpdateTravel(config.DB, uint(id), input)
+	if err != nil {
+		respondTravelError(c, err)
+		return
+	}
+	services.ClearTravelFacets()
+
+	// Places ajoutées : proposées en priorité à la liste d'attente
+	if travel.Stock > previousStock {
+		if err := services.PromoteWaitlist(c.Request.Context(), config.DB, travel.ID, config.WaitlistOfferTTL()); err != nil {
+			log.Printf("Promotion de la liste d'attente du travel %d impossible: %v", travel.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, travel)
+}
+
+// --- CANCELLATION POLICY ---
+// SetCancellationPolicy godoc
+// @Summary Définit la politique d'annulation d'un travel
+// @Description Permet à un admin de remplacer les paliers de remboursement (ex. 100 % à 30 jours, 50 % à 7 jours, rien ensuite). Une liste vide rétablit le remboursement intégral.
+// @Tags Travels
+// @Accept json
+// @Produce json
+// @Param id path int true "ID du travel"
+// @Param policy body models.CancellationPolicyInput true "Paliers de remboursement"
+// @Success 200 {object} models.Travel
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /travels/{id}/cancellation-policy [put]
+// @Security BearerAuth
+func SetCancellationPolicy(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := strconv.ParseUint(idParam, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	var input models.CancellationPolicyInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	config.DB.Model(&travel).Updates(input)
+	travel, err := services.SetCancellationPolicy(config.DB, uint(id), input.Tiers)
+	if err != nil {
+		if errors.Is(err, services.ErrTravelNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Travel non trouvé"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, travel)
 }
 
@@ -150,5 +381,6 @@ func DeleteTravel(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	services.ClearTravelFacets()
 	c.JSON(http.StatusOK, gin.H{"message": "Travel supprimé"})
 }
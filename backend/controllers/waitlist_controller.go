@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"errors"
+	"h3-travel/config"
+	"h3-travel/models"
+	"h3-travel/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- JOIN WAITLIST ---
+// JoinWaitlist godoc
+// @Summary Rejoint la liste d'attente d'un travel complet
+// @Description Inscrit l'utilisateur ; quand des places se libèrent, une réservation limitée dans le temps lui est proposée par ordre d'inscription
+// @Tags Waitlist
+// @Accept json
+// @Produce json
+// @Param id path int true "ID du travel"
+// @Param input body models.JoinWaitlistInput false "Nombre de places souhaitées"
+// @Success 200 {object} models.WaitlistEntry
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /travels/{id}/waitlist [post]
+func JoinWaitlist(c *gin.Context) {
+	travelID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	var input models.JoinWaitlistInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	entry, err := services.JoinWaitlist(config.DB, c.GetUint("user_id"), uint(travelID), input.Quantity)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTravelNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Travel non trouvé"})
+		case errors.Is(err, services.ErrTravelUnavailable):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Travel indisponible"})
+		case errors.Is(err, services.ErrWaitlistDated):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ce travel se réserve par départ : pas de liste d'attente"})
+		case errors.Is(err, services.ErrSeatsAvailable):
+			c.JSON(http.StatusConflict, gin.H{"error": "Des places sont disponibles, commandez directement"})
+		case errors.Is(err, services.ErrAlreadyWaitlist):
+			c.JSON(http.StatusConflict, gin.H{"error": "Déjà inscrit sur la liste d'attente"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// --- LEAVE WAITLIST ---
+// LeaveWaitlist godoc
+// @Summary Quitte la liste d'attente d'un travel
+// @Tags Waitlist
+// @Produce json
+// @Param id path int true "ID du travel"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /travels/{id}/waitlist [delete]
+func LeaveWaitlist(c *gin.Context) {
+	travelID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID invalide"})
+		return
+	}
+
+	if err := services.LeaveWaitlist(config.DB, c.GetUint("user_id"), uint(travelID)); err != nil {
+		if errors.Is(err, services.ErrNotOnWaitlist) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pas inscrit sur la liste d'attente"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Désinscrit de la liste d'attente"})
+}
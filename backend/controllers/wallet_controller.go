@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"errors"
+	"h3-travel/config"
+	"h3-travel/models"
+	"h3-travel/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func respondWalletError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrGiftCardNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Carte cadeau non trouvée"})
+	case errors.Is(err, services.ErrGiftCardInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrGiftCardRedeemed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrGiftCardExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// --- GET WALLET ---
+// GetWallet godoc
+// @Summary Porte-monnaie de l'utilisateur
+// @Description Soldes par devise et dernières écritures du registre (cartes cadeaux, remboursements en avoir, paiements)
+// @Tags Wallet
+// @Produce json
+// @Success 200 {object} models.WalletSummary
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /wallet [get]
+func GetWallet(c *gin.Context) {
+	summary, err := services.GetWallet(config.DB, c.GetUint("user_id"))
+	if err != nil {
+		respondWalletError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// --- REDEEM GIFT CARD ---
+// RedeemGiftCard godoc
+// @Summary Utilise une carte cadeau
+// @Description Crédite la valeur de la carte sur le porte-monnaie ; une carte ne peut être utilisée qu'une fois
+// @Tags Wallet
+// @Accept json
+// @Produce json
+// @Param input body models.RedeemGiftCardInput true "Code de la carte"
+// @Success 200 {object} models.WalletTransaction
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /wallet/redeem [post]
+func RedeemGiftCard(c *gin.Context) {
+	var input models.RedeemGiftCardInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry, err := services.RedeemGiftCard(config.DB, c.GetUint("user_id"), input.Code)
+	if err != nil {
+		respondWalletError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// --- LIST GIFT CARDS (ADMIN) ---
+// AdminListGiftCards godoc
+// @Summary Liste les cartes cadeaux émises
+// @Description Les codes ne sont pas conservés : seuls leurs 4 derniers caractères sont affichés
+// @Tags Admin Gift Cards
+// @Produce json
+// @Success 200 {array} models.GiftCard
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/gift-cards [get]
+func AdminListGiftCards(c *gin.Context) {
+	cards, err := services.ListGiftCards(config.DB)
+	if err != nil {
+		respondWalletError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cards)
+}
+
+// --- ISSUE GIFT CARD (ADMIN) ---
+// AdminIssueGiftCard godoc
+// @Summary Émet une carte cadeau
+// @Description Génère une carte d'un montant donné (en unités mineures), avec une date d'expiration facultative. Le code n'est renvoyé que dans cette réponse.
+// @Tags Admin Gift Cards
+// @Accept json
+// @Produce json
+// @Param input body models.GiftCardInput true "Carte cadeau"
+// @Success 200 {object} models.GiftCard
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/gift-cards [post]
+func AdminIssueGiftCard(c *gin.Context) {
+	var input models.GiftCardInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	card, err := services.IssueGiftCard(config.DB, input, models.ActorAdmin(c.GetUint("user_id")))
+	if err != nil {
+		respondWalletError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, card)
+}
@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"h3-travel/config"
 	"h3-travel/models"
 	"h3-travel/routes"
+	"h3-travel/services"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	_ "h3-travel/docs"
 
@@ -24,8 +32,50 @@ func main() {
 	// Connexion DB
 	config.ConnectDatabase()
 
+	// Prestataire de paiement et coffre de tokenisation des cartes
+	config.SetupPayment()
+	config.SetupVault()
+
 	// Migration des modèles
-	config.DB.AutoMigrate(&models.User{}, &models.Travel{}, &models.Order{})
+	snapshotsMissing := services.OrderSnapshotsMissing(config.DB)
+	config.DB.AutoMigrate(models.All()...)
+	if err := services.MigrateLegacyAmounts(config.DB); err != nil {
+		log.Fatalf("Conversion des montants impossible: %v", err)
+	}
+	if snapshotsMissing {
+		if err := services.BackfillOrderSnapshots(config.DB); err != nil {
+			log.Printf("Reprise des lignes de commande impossible: %v", err)
+		}
+	}
+	if err := services.BackfillTravelCapacity(config.DB); err != nil {
+		log.Printf("Reprise de la capacité des travels impossible: %v", err)
+	}
+	if err := services.SetupTravelSearch(config.DB); err != nil {
+		log.Printf("Index de recherche des travels indisponible: %v", err)
+	}
+
+	// Arrêt propre sur SIGINT / SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Jobs périodiques
+	scheduler := services.NewScheduler()
+	scheduler.Every("expiration des réservations", config.HoldSweepInterval(), func(ctx context.Context) error {
+		_, err := services.ExpireHolds(ctx, config.DB, time.Now(), config.WaitlistOfferTTL())
+		return err
+	})
+	scheduler.Every("purge des clés d'idempotence", time.Hour, func(ctx context.Context) error {
+		return services.PurgeIdempotencyKeys(ctx, config.DB, time.Now().Add(-config.IdempotencyKeyTTL()))
+	})
+	scheduler.Every("clôture des commandes parties", time.Hour, func(ctx context.Context) error {
+		_, err := services.CompleteOrders(ctx, config.DB, time.Now())
+		return err
+	})
+	scheduler.Every("expiration des points de fidélité", time.Hour, func(ctx context.Context) error {
+		_, err := services.ExpireLoyaltyPoints(ctx, config.DB, time.Now())
+		return err
+	})
+	scheduler.Start(ctx)
 
 	// Routes
 	r := routes.SetupRouter()
@@ -33,6 +83,21 @@ func main() {
 	// Swagger
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	log.Println("🚀 Server running on port 8080")
-	r.Run(":8080")
+	srv := &http.Server{Addr: ":8080", Handler: r}
+	go func() {
+		log.Println("🚀 Server running on port 8080")
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Server error: ", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Arrêt du serveur...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("Arrêt forcé: ", err)
+	}
+	scheduler.Wait()
 }
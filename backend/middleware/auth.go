@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"errors"
 	"net/http"
 	"os"
 	"strings"
@@ -11,6 +12,25 @@ import (
 
 var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
 
+var errInvalidToken = errors.New("token invalide")
+
+// parseToken vérifie le token de l'en-tête Authorization ("Bearer <token>") et
+// retourne ses claims.
+func parseToken(authHeader string) (jwt.MapClaims, error) {
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errInvalidToken
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errInvalidToken
+	}
+	return claims, nil
+}
+
 func AdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -20,23 +40,22 @@ func AdminMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
-		if err != nil || !token.Valid {
+		claims, err := parseToken(authHeader)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token invalide"})
 			c.Abort()
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok || claims["role"] != "admin" {
+		if claims["role"] != "admin" {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Accès réservé aux admins"})
 			c.Abort()
 			return
 		}
 
+		if userID, ok := claims["user_id"].(float64); ok {
+			c.Set("user_id", uint(userID))
+		}
 		c.Next()
 	}
 }
@@ -50,25 +69,38 @@ func JWTMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
-		if err != nil || !token.Valid {
+		claims, err := parseToken(authHeader)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token invalide"})
 			c.Abort()
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token invalide"})
-			c.Abort()
+		userID := uint(claims["user_id"].(float64))
+		c.Set("user_id", userID)
+		c.Next()
+	}
+}
+
+// OptionalAuthMiddleware identifie l'utilisateur quand un token valide est fourni
+// (user_id et role), sans rien exiger : une route publique reste accessible
+// anonymement, même avec un token expiré.
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.Next()
 			return
 		}
 
-		userID := uint(claims["user_id"].(float64))
-		c.Set("user_id", userID)
+		if claims, err := parseToken(authHeader); err == nil {
+			if userID, ok := claims["user_id"].(float64); ok {
+				c.Set("user_id", uint(userID))
+			}
+			if role, ok := claims["role"].(string); ok {
+				c.Set("role", role)
+			}
+		}
 		c.Next()
 	}
 }
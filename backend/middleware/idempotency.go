@@ -0,0 +1,126 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"h3-travel/config"
+	"h3-travel/models"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+const IdempotencyHeader = "Idempotency-Key"
+
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency rejoue la réponse d'origine quand une requête est renvoyée avec le
+// même en-tête Idempotency-Key. La clé est propre à chaque utilisateur : la
+// route doit donc être placée derrière JWTMiddleware. Sans en-tête, la requête
+// est traitée normalement.
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key trop longue"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Corps de requête illisible"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n" + string(body)))
+		record := models.IdempotencyKey{
+			UserID:      c.GetUint("user_id"),
+			Key:         key,
+			Fingerprint: hex.EncodeToString(sum[:]),
+		}
+
+		// La contrainte unique (user_id, key) garantit qu'une seule requête s'exécute
+		res := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if res.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": res.Error.Error()})
+			c.Abort()
+			return
+		}
+
+		if res.RowsAffected == 0 {
+			replay(c, record)
+			return
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		// Panique du handler : la clé est libérée, sinon chaque nouvel essai
+		// recevrait 409 jusqu'à la purge
+		defer func() {
+			if r := recover(); r != nil {
+				config.DB.Delete(&models.IdempotencyKey{}, record.ID)
+				panic(r)
+			}
+		}()
+		c.Next()
+
+		status := c.Writer.Status()
+		if status >= http.StatusInternalServerError {
+			// Erreur serveur : la clé est libérée pour permettre un nouvel essai
+			config.DB.Delete(&models.IdempotencyKey{}, record.ID)
+			return
+		}
+		if err := config.DB.Model(&models.IdempotencyKey{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+			"status_code":   status,
+			"response_body": recorder.body.Bytes(),
+		}).Error; err != nil {
+			// Réponse non enregistrée : la clé est libérée plutôt que de rester « en cours »
+			log.Printf("Enregistrement de la réponse de la clé d'idempotence %d impossible: %v", record.ID, err)
+			config.DB.Delete(&models.IdempotencyKey{}, record.ID)
+		}
+	}
+}
+
+func replay(c *gin.Context, record models.IdempotencyKey) {
+	var existing models.IdempotencyKey
+	if err := config.DB.Where("user_id = ? AND idempotency_key = ?", record.UserID, record.Key).First(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	switch {
+	case existing.Fingerprint != record.Fingerprint:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key déjà utilisée pour une autre requête"})
+	case existing.StatusCode == 0:
+		c.JSON(http.StatusConflict, gin.H{"error": "Requête déjà en cours de traitement"})
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(existing.StatusCode, "application/json; charset=utf-8", existing.ResponseBody)
+	}
+	c.Abort()
+}
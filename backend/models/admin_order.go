@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// OrderNote : note interne laissée par un admin sur une commande, jamais exposée au client
+type OrderNote struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	OrderID   uint      `json:"order_id" gorm:"index;not null"`
+	AuthorID  uint      `json:"author_id" gorm:"not null"`
+	Body      string    `json:"body" gorm:"type:text;not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OrderNoteInput struct {
+	Body string `json:"body" binding:"required,max=2000"`
+}
+
+// AdminOrderFilter : critères de recherche des commandes côté admin (query string)
+type AdminOrderFilter struct {
+	Status   OrderStatus `form:"status"`
+	TravelID uint        `form:"travel_id"`
+	UserID   uint        `form:"user_id"`
+	From     time.Time   `form:"from" time_format:"2006-01-02"` // créées à partir de ce jour inclus
+	To       time.Time   `form:"to" time_format:"2006-01-02"`   // créées jusqu'à ce jour inclus
+	Page     int         `form:"page" binding:"omitempty,min=1"`
+	PageSize int         `form:"page_size" binding:"omitempty,min=1,max=200"`
+}
+
+// RefundMode : remboursement appliqué lors d'une annulation forcée par un admin
+type RefundMode string
+
+const (
+	RefundFull     RefundMode = "full"   // tout le solde remboursable (par défaut)
+	RefundByPolicy RefundMode = "policy" // politique d'annulation des travels, comme pour le client
+	RefundNone     RefundMode = "none"
+)
+
+type AdminCancelOrderInput struct {
+	Refund   RefundMode `json:"refund" binding:"omitempty,oneof=full policy none"`
+	Reason   string     `json:"reason"`
+	ToWallet bool       `json:"to_wallet"` // remboursement en avoir sur le porte-monnaie du client
+}
+
+// OrderCustomer : l'acheteur d'une commande, sans ses données sensibles
+type OrderCustomer struct {
+	ID    uint   `json:"id"`
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// AdminOrderDetails : une commande avec son acheteur et les travels commandés
+type AdminOrderDetails struct {
+	Order
+	Customer *OrderCustomer `json:"user"`
+	Travels  []Travel       `json:"travels"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"h3-travel/money"
+	"sort"
+)
+
+// CancellationTier : palier de la politique d'annulation d'un travel.
+// Une annulation au moins MinDaysBefore jours avant le départ est remboursée à RefundPercent %.
+type CancellationTier struct {
+	ID            uint `json:"-" gorm:"primarykey"`
+	TravelID      uint `json:"-" gorm:"index;not null"`
+	MinDaysBefore int  `json:"min_days_before" gorm:"not null"`
+	RefundPercent int  `json:"refund_percent" gorm:"not null"`
+}
+
+type CancellationTierInput struct {
+	MinDaysBefore int `json:"min_days_before" binding:"min=0"`
+	RefundPercent int `json:"refund_percent" binding:"min=0,max=100"`
+}
+
+type CancellationPolicyInput struct {
+	Tiers []CancellationTierInput `json:"tiers" binding:"dive"` // liste vide : remboursement intégral
+}
+
+// RefundPercentFor retourne le pourcentage remboursé à daysBefore jours du départ.
+// Sans palier, le remboursement est intégral ; sous le dernier palier, il est nul.
+func RefundPercentFor(tiers []CancellationTier, daysBefore int) int {
+	if len(tiers) == 0 {
+		return 100
+	}
+
+	sorted := append([]CancellationTier(nil), tiers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].MinDaysBefore > sorted[j].MinDaysBefore })
+	for _, tier := range sorted {
+		if daysBefore >= tier.MinDaysBefore {
+			return tier.RefundPercent
+		}
+	}
+	return 0
+}
+
+type CancellationQuoteLine struct {
+	TravelID            uint        `json:"travel_id"`
+	DaysBeforeDeparture *int        `json:"days_before_departure"`
+	RefundPercent       int         `json:"refund_percent"`
+	Amount              money.Money `json:"amount"`
+	Refund              money.Money `json:"refund"`
+}
+
+// CancellationQuote : montant remboursé si la commande est annulée maintenant
+type CancellationQuote struct {
+	OrderID      uint                    `json:"order_id"`
+	PaidAmount   money.Money             `json:"paid_amount"`
+	RefundAmount money.Money             `json:"refund_amount"`
+	Lines        []CancellationQuoteLine `json:"lines"`
+}
+
+// CancelOrderResponse : la commande annulée accompagnée du détail du remboursement
+type CancelOrderResponse struct {
+	Order
+	Refund CancellationQuote `json:"refund"`
+}
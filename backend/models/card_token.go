@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// CardToken : entrée du coffre de tokenisation. Le numéro de carte n'est
+// conservé que chiffré ; le cryptogramme n'est jamais stocké.
+type CardToken struct {
+	ID           uint      `json:"-" gorm:"primarykey"`
+	Token        string    `json:"token" gorm:"type:varchar(64);uniqueIndex;not null"`
+	UserID       uint      `json:"-" gorm:"index;not null"`
+	EncryptedPAN []byte    `json:"-" gorm:"not null"`
+	Brand        string    `json:"brand" gorm:"type:varchar(20);not null"`
+	Last4        string    `json:"last4" gorm:"type:char(4);not null"`
+	ExpMonth     int       `json:"exp_month" gorm:"not null"`
+	ExpYear      int       `json:"exp_year" gorm:"not null"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (CardToken) TableName() string {
+	return "card_vault"
+}
+
+type TokenizeCardInput struct {
+	Card     string `json:"card" binding:"required"`
+	ExpMonth int    `json:"exp_month" binding:"required"`
+	ExpYear  int    `json:"exp_year" binding:"required"`
+	CVV      string `json:"cvv" binding:"required"`
+}
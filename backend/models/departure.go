@@ -0,0 +1,29 @@
+package models
+
+import (
+	"h3-travel/money"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Departure : date à laquelle un travel est proposé, avec son propre stock de
+// places. Un travel qui a des départs se réserve départ par départ ; son stock
+// global ne sert plus. Un départ passé n'est plus réservable.
+type Departure struct {
+	gorm.Model
+	TravelID  uint        `json:"travel_id" gorm:"index;not null"`
+	StartDate time.Time   `json:"start_date" gorm:"not null;index"`
+	EndDate   time.Time   `json:"end_date" gorm:"not null"`
+	Capacity  int         `json:"capacity" gorm:"not null"`
+	Stock     int         `json:"stock" gorm:"not null"`                       // places restantes
+	Price     money.Money `json:"price" gorm:"embedded;embeddedPrefix:price_"` // prix propre au départ ; montant nul : prix du travel
+	Bookable  bool        `json:"bookable" gorm:"-"`                           // à venir et pas complet
+}
+
+type DepartureInput struct {
+	StartDate time.Time    `json:"start_date" binding:"required"`
+	EndDate   time.Time    `json:"end_date" binding:"required"`
+	Capacity  int          `json:"capacity" binding:"required,min=1"`
+	Price     *money.Money `json:"price"` // absent : prix du travel
+}
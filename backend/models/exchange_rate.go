@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// ExchangeRate : taux de change mis à jour par les admins ; 1 Base vaut Rate Quote.
+// Il sert uniquement à l'affichage des prix, les commandes restent dans la devise du travel.
+type ExchangeRate struct {
+	ID        uint      `json:"-" gorm:"primarykey"`
+	Base      string    `json:"base" gorm:"type:char(3);not null;uniqueIndex:idx_exchange_rate_pair"`
+	Quote     string    `json:"quote" gorm:"type:char(3);not null;uniqueIndex:idx_exchange_rate_pair"`
+	Rate      float64   `json:"rate" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ExchangeRateInput struct {
+	Rate float64 `json:"rate" binding:"required,gt=0"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// IdempotencyKey conserve la réponse d'une requête mutante rejouable avec le même en-tête Idempotency-Key.
+// StatusCode vaut 0 tant que la première requête est en cours de traitement.
+type IdempotencyKey struct {
+	ID           uint   `gorm:"primarykey"`
+	UserID       uint   `gorm:"uniqueIndex:idx_idempotency_user_key;not null"`
+	Key          string `gorm:"column:idempotency_key;type:varchar(255);uniqueIndex:idx_idempotency_user_key;not null"`
+	Fingerprint  string `gorm:"type:char(64);not null"` // sha256 méthode + chemin + corps
+	StatusCode   int    `gorm:"not null;default:0"`
+	ResponseBody []byte
+	CreatedAt    time.Time `gorm:"index"`
+}
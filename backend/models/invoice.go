@@ -0,0 +1,43 @@
+package models
+
+import (
+	"h3-travel/money"
+	"time"
+)
+
+type InvoiceKind string
+
+const (
+	KindInvoice    InvoiceKind = "invoice"     // facture, émise au paiement
+	KindCreditNote InvoiceKind = "credit_note" // avoir, émis à chaque remboursement
+)
+
+// Invoice : facture ou avoir d'une commande. Les numéros se suivent sans trou
+// dans chaque série (une par type et par année) : ils sont attribués dans la
+// transaction qui enregistre le paiement ou le remboursement.
+type Invoice struct {
+	ID                uint        `json:"id" gorm:"primarykey"`
+	Number            string      `json:"number" gorm:"type:varchar(20);uniqueIndex;not null"` // FA-2026-000001, AV-2026-000001
+	Kind              InvoiceKind `json:"kind" gorm:"type:varchar(20);not null"`
+	OrderID           uint        `json:"order_id" gorm:"index;not null"`
+	CreditedInvoiceID *uint       `json:"credited_invoice_id,omitempty"` // facture corrigée par l'avoir
+	Reason            string      `json:"reason,omitempty" gorm:"type:varchar(255)"`
+	Net               money.Money `json:"net" gorm:"embedded;embeddedPrefix:net_"`
+	Tax               money.Money `json:"tax" gorm:"embedded;embeddedPrefix:tax_"`
+	Gross             money.Money `json:"gross" gorm:"embedded;embeddedPrefix:gross_"`
+	IssuedAt          time.Time   `json:"issued_at"`
+}
+
+// InvoiceSequence : dernier numéro attribué dans une série
+type InvoiceSequence struct {
+	Prefix  string `gorm:"primaryKey;type:varchar(10)"` // FA-2026
+	Counter int64  `gorm:"not null;default:0"`
+}
+
+// TaxBreakdown : ventilation d'un montant TTC pour un taux de TVA
+type TaxBreakdown struct {
+	Rate  int         `json:"rate"` // points de base
+	Net   money.Money `json:"net"`
+	Tax   money.Money `json:"tax"`
+	Gross money.Money `json:"gross"`
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"h3-travel/money"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoyaltyProgram : paramètres du programme de fidélité, une seule ligne (ID 1).
+// Les montants sont exprimés dans la devise par défaut.
+type LoyaltyProgram struct {
+	ID             uint          `json:"-" gorm:"primarykey"`
+	EarnRate       int           `json:"earn_rate" gorm:"not null"`                               // points gagnés par unité dépensée (1 € = EarnRate points)
+	PointValue     money.Money   `json:"point_value" gorm:"embedded;embeddedPrefix:point_value_"` // remise accordée par point dépensé
+	MaxBurnPercent int           `json:"max_burn_percent" gorm:"not null"`                        // part maximale d'une commande payable en points
+	ExpiryMonths   int           `json:"expiry_months" gorm:"not null"`                           // durée de validité des points ; 0 : sans expiration
+	Tiers          []LoyaltyTier `json:"tiers" gorm:"-"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// LoyaltyTier : niveau atteint selon les dépenses des 12 derniers mois ; il
+// majore les points gagnés de BonusPercent %.
+type LoyaltyTier struct {
+	ID             uint        `json:"-" gorm:"primarykey"`
+	Name           string      `json:"name" gorm:"type:varchar(50);not null"`
+	MinYearlySpend money.Money `json:"min_yearly_spend" gorm:"embedded;embeddedPrefix:min_yearly_spend_"`
+	BonusPercent   int         `json:"bonus_percent" gorm:"not null;default:0"`
+}
+
+// DefaultLoyaltyProgram s'applique tant qu'aucun admin n'a configuré le programme
+var DefaultLoyaltyProgram = LoyaltyProgram{
+	EarnRate:       1,
+	PointValue:     money.New(1, money.DefaultCurrency),
+	MaxBurnPercent: 50,
+	ExpiryMonths:   24,
+	Tiers: []LoyaltyTier{
+		{Name: "Bronze", MinYearlySpend: money.Zero(money.DefaultCurrency)},
+		{Name: "Argent", MinYearlySpend: money.New(100000, money.DefaultCurrency), BonusPercent: 25},
+		{Name: "Or", MinYearlySpend: money.New(300000, money.DefaultCurrency), BonusPercent: 50},
+	},
+}
+
+type LoyaltyTierInput struct {
+	Name           string `json:"name" binding:"required,max=50"`
+	MinYearlySpend int64  `json:"min_yearly_spend" binding:"min=0"` // en unités mineures de la devise par défaut
+	BonusPercent   int    `json:"bonus_percent" binding:"min=0,max=500"`
+}
+
+type LoyaltyProgramInput struct {
+	EarnRate       int                `json:"earn_rate" binding:"min=0"`
+	PointValue     int64              `json:"point_value" binding:"required,min=1"` // en unités mineures de la devise par défaut
+	MaxBurnPercent int                `json:"max_burn_percent" binding:"min=0,max=100"`
+	ExpiryMonths   int                `json:"expiry_months" binding:"min=0"`
+	Tiers          []LoyaltyTierInput `json:"tiers" binding:"dive"`
+}
+
+// LoyaltyAccount : solde de points d'un utilisateur, toujours modifié avec une
+// écriture du registre. Il peut devenir négatif si des points déjà dépensés
+// sont repris après l'annulation de la commande qui les avait rapportés.
+type LoyaltyAccount struct {
+	UserID    uint      `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	Points    int       `json:"points" gorm:"not null;default:0"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type LoyaltyTransactionKind string
+
+const (
+	LoyaltyEarn    LoyaltyTransactionKind = "earn"    // commande payée
+	LoyaltyReverse LoyaltyTransactionKind = "reverse" // points de la commande repris à son annulation
+	LoyaltyBurn    LoyaltyTransactionKind = "burn"    // points dépensés en remise
+	LoyaltyRestore LoyaltyTransactionKind = "restore" // points dépensés rendus à l'annulation ou l'expiration de la commande
+	LoyaltyExpire  LoyaltyTransactionKind = "expire"
+)
+
+// LoyaltyTransaction : écriture du registre des points, en ajout seul
+type LoyaltyTransaction struct {
+	ID        uint                   `json:"id" gorm:"primarykey"`
+	UserID    uint                   `json:"user_id" gorm:"index;not null"`
+	Kind      LoyaltyTransactionKind `json:"kind" gorm:"type:varchar(20);not null"`
+	Points    int                    `json:"points" gorm:"not null"`                      // négatif pour un débit
+	Spend     money.Money            `json:"spend" gorm:"embedded;embeddedPrefix:spend_"` // dépense comptée pour le niveau (gain et reprise)
+	OrderID   *uint                  `json:"order_id,omitempty" gorm:"index"`
+	ExpiresAt *time.Time             `json:"expires_at,omitempty" gorm:"index"` // points crédités, et échéance des points repris
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+func (LoyaltyTransaction) BeforeUpdate(*gorm.DB) error {
+	return ErrLedgerAppendOnly
+}
+
+func (LoyaltyTransaction) BeforeDelete(*gorm.DB) error {
+	return ErrLedgerAppendOnly
+}
+
+// LoyaltySummary : solde, niveau et historique de points d'un utilisateur
+type LoyaltySummary struct {
+	Points       int                  `json:"points"`
+	Value        money.Money          `json:"value"` // remise que représente le solde
+	Tier         string               `json:"tier,omitempty"`
+	YearlySpend  money.Money          `json:"yearly_spend"`
+	NextTier     string               `json:"next_tier,omitempty"`
+	NextTierGap  *money.Money         `json:"next_tier_gap,omitempty"` // dépense restant à faire pour l'atteindre
+	Transactions []LoyaltyTransaction `json:"transactions"`
+}
@@ -0,0 +1,40 @@
+package models
+
+// All liste les modèles à migrer
+func All() []interface{} {
+	return []interface{}{
+		&User{},
+		&Travel{},
+		&FlightDetails{},
+		&HotelDetails{},
+		&HotelRoomType{},
+		&PackageComponent{},
+		&Departure{},
+		&Order{},
+		&OrderItem{},
+		&OrderHistory{},
+		&IdempotencyKey{},
+		&CardToken{},
+		&Payment{},
+		&Refund{},
+		&CancellationTier{},
+		&WaitlistEntry{},
+		&OrderNote{},
+		&ExchangeRate{},
+		&PromoCode{},
+		&PromoCodeTravel{},
+		&PromoRedemption{},
+		&PricingRule{},
+		&TaxRate{},
+		&Invoice{},
+		&InvoiceSequence{},
+		&Passenger{},
+		&Wallet{},
+		&WalletTransaction{},
+		&GiftCard{},
+		&LoyaltyProgram{},
+		&LoyaltyTier{},
+		&LoyaltyAccount{},
+		&LoyaltyTransaction{},
+	}
+}
@@ -1,15 +1,90 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"h3-travel/money"
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Order struct {
 	gorm.Model
-	UserID   uint   `json:"user_id"`
-	TravelID uint   `json:"travel_id"`
-	Statut   string `json:"statut"`
+	UserID               uint           `json:"user_id"`
+	Statut               OrderStatus    `json:"statut" gorm:"type:varchar(20)"`
+	Total                money.Money    `json:"total" gorm:"embedded;embeddedPrefix:total_"` // remise déduite
+	PromoCode            string         `json:"promo_code,omitempty" gorm:"type:varchar(50)"`
+	Discount             money.Money    `json:"discount" gorm:"embedded;embeddedPrefix:discount_"` // remise totale du code promo (hors points de fidélité)
+	Tax                  money.Money    `json:"tax" gorm:"embedded;embeddedPrefix:tax_"`           // TVA comprise dans Total
+	Net                  money.Money    `json:"net" gorm:"-"`                                      // Total hors taxes
+	ExpiresAt            *time.Time     `json:"expires_at,omitempty" gorm:"index"`                 // fin de la réservation tant que la commande n'est pas payée
+	PaymentProvider      string         `json:"payment_provider,omitempty" gorm:"type:varchar(30)"`
+	PaymentTransactionID string         `json:"payment_transaction_id,omitempty" gorm:"type:varchar(100);index"`
+	CardBrand            string         `json:"card_brand,omitempty" gorm:"type:varchar(20)"`
+	CardLast4            string         `json:"card_last4,omitempty" gorm:"type:char(4)"`
+	PaidAmount           money.Money    `json:"paid_amount" gorm:"embedded;embeddedPrefix:paid_"`
+	RefundedAmount       money.Money    `json:"refunded_amount" gorm:"embedded;embeddedPrefix:refunded_"`
+	WalletAmount         money.Money    `json:"wallet_amount" gorm:"embedded;embeddedPrefix:wallet_"`              // part de Total réglée avec le porte-monnaie
+	LoyaltyPoints        int            `json:"loyalty_points" gorm:"not null;default:0"`                          // points de fidélité dépensés
+	LoyaltyDiscount      money.Money    `json:"loyalty_discount" gorm:"embedded;embeddedPrefix:loyalty_discount_"` // remise obtenue avec ces points
+	RefundableAmount     money.Money    `json:"refundable_amount" gorm:"-"`
+	Items                []OrderItem    `json:"items"`
+	History              []OrderHistory `json:"history,omitempty"`
+	Payments             []Payment      `json:"payments,omitempty"`
+	Refunds              []Refund       `json:"refunds,omitempty"`
+	Notes                []OrderNote    `json:"notes,omitempty"` // notes internes, chargées uniquement côté admin
+	Invoices             []Invoice      `json:"invoices,omitempty"`
+	Passengers           []Passenger    `json:"passengers,omitempty" gorm:"-"` // déchiffrés, renvoyés à la création
+}
+
+// AfterFind calcule le montant hors taxes et le solde encore remboursable
+func (o *Order) AfterFind(tx *gorm.DB) error {
+	o.Net = money.New(o.Total.Amount-o.Tax.Amount, o.Total.Currency)
+	o.RefundableAmount = money.New(o.PaidAmount.Amount-o.RefundedAmount.Amount, o.PaidAmount.Currency)
+	return nil
+}
+
+// OrderItem : une ligne de commande. Le titre, le prix unitaire (et sa devise), la
+// remise et la TVA sont figés au moment de l'achat : modifier ou supprimer le
+// travel ensuite ne change pas la commande.
+type OrderItem struct {
+	gorm.Model
+	OrderID     uint        `json:"order_id" gorm:"index;not null"`
+	TravelID    uint        `json:"travel_id" gorm:"not null"`
+	DepartureID *uint       `json:"departure_id,omitempty" gorm:"index"` // départ réservé, pour un travel qui en a
+	Title       string      `json:"title" gorm:"not null;default:''"`
+	Quantity    int         `json:"quantity" gorm:"not null"`
+	UnitPrice   money.Money `json:"unit_price" gorm:"embedded;embeddedPrefix:unit_price_"`
+	Discount    money.Money `json:"discount" gorm:"embedded;embeddedPrefix:discount_"` // remise sur l'ensemble de la ligne
+	TaxCategory string      `json:"tax_category" gorm:"type:varchar(20);not null;default:''"`
+	TaxRate     int         `json:"tax_rate" gorm:"not null;default:0"`      // points de base (2000 = 20 %)
+	Tax         money.Money `json:"tax" gorm:"embedded;embeddedPrefix:tax_"` // TVA comprise dans LineTotal
+	TravelURL   string      `json:"travel_url,omitempty" gorm:"-"`           // lien vers le travel tel qu'il est aujourd'hui
+}
+
+// LineTotal : montant payé pour la ligne, remise déduite
+func (i OrderItem) LineTotal() money.Money {
+	return i.UnitPrice.Mul(i.Quantity).Sub(i.Discount)
+}
+
+type OrderItemInput struct {
+	TravelID    uint               `json:"travel_id" binding:"required"`
+	DepartureID *uint              `json:"departure_id"` // requis pour un travel qui a des départs
+	Quantity    int                `json:"quantity" binding:"required,min=1"`
+	Passengers  []PassengerDetails `json:"passengers" binding:"omitempty,dive"` // facultatif ; sinon un par place
 }
 
 type CreateOrderInput struct {
-	TravelID uint   `json:"travel_id" binding:"required"`
-	Card     string `json:"card" binding:"required"`
+	Items         []OrderItemInput `json:"items" binding:"required,min=1,dive"`
+	PromoCode     string           `json:"promo_code" binding:"max=50"`
+	WalletAmount  int64            `json:"wallet_amount" binding:"min=0"`  // en unités mineures, à prélever sur le porte-monnaie (plafonné au total)
+	LoyaltyPoints int              `json:"loyalty_points" binding:"min=0"` // points de fidélité à dépenser en remise (plafonnés selon le programme)
+}
+
+// CancelOrderInput : corps facultatif de l'annulation par le client
+type CancelOrderInput struct {
+	ToWallet bool `json:"to_wallet"` // rembourser en avoir sur le porte-monnaie plutôt que sur la carte
+}
+
+type ConfirmPaymentInput struct {
+	CardToken string `json:"card_token" binding:"required"` // obtenu via POST /cards/tokenize
 }
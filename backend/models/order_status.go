@@ -0,0 +1,71 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type OrderStatus string
+
+const (
+	StatusPendingPayment OrderStatus = "pending_payment"
+	StatusPaid           OrderStatus = "paid"
+	StatusConfirmed      OrderStatus = "confirmed"
+	StatusCancelled      OrderStatus = "cancelled"
+	StatusRefunded       OrderStatus = "refunded"
+	StatusExpired        OrderStatus = "expired"
+	StatusCompleted      OrderStatus = "completed"
+)
+
+// orderTransitions : seul endroit où sont définies les transitions autorisées
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	StatusPendingPayment: {StatusPaid, StatusCancelled, StatusExpired},
+	StatusPaid:           {StatusConfirmed, StatusCompleted, StatusCancelled, StatusRefunded},
+	StatusConfirmed:      {StatusCompleted, StatusCancelled, StatusRefunded},
+}
+
+var ErrInvalidTransition = errors.New("transition de statut invalide")
+
+// TransitionError précise la transition refusée ; errors.Is(err, ErrInvalidTransition) est vrai.
+type TransitionError struct {
+	From OrderStatus
+	To   OrderStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s: %s -> %s", ErrInvalidTransition, e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+func (s OrderStatus) CanTransitionTo(to OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderHistory trace chaque changement de statut d'une commande
+type OrderHistory struct {
+	ID         uint        `json:"id" gorm:"primarykey"`
+	OrderID    uint        `json:"order_id" gorm:"index;not null"`
+	FromStatus OrderStatus `json:"from_status" gorm:"type:varchar(20)"`
+	ToStatus   OrderStatus `json:"to_status" gorm:"type:varchar(20);not null"`
+	Actor      string      `json:"actor" gorm:"type:varchar(50);not null"` // "user:12", "admin:1" ou "system"
+	ChangedAt  time.Time   `json:"changed_at" gorm:"not null"`
+}
+
+const ActorSystem = "system"
+
+func ActorUser(id uint) string {
+	return fmt.Sprintf("user:%d", id)
+}
+
+func ActorAdmin(id uint) string {
+	return fmt.Sprintf("admin:%d", id)
+}
@@ -0,0 +1,14 @@
+package models
+
+// Pagination : position d'une page dans les résultats d'une liste paginée
+type Pagination struct {
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	Total      int64 `json:"total"`       // nombre total de résultats, toutes pages confondues
+	TotalPages int   `json:"total_pages"` // 0 si aucun résultat
+}
+
+func NewPagination(page, pageSize int, total int64) Pagination {
+	pages := int((total + int64(pageSize) - 1) / int64(pageSize))
+	return Pagination{Page: page, PageSize: pageSize, Total: total, TotalPages: pages}
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// PassengerDetails : identité d'un voyageur, telle que saisie à la commande.
+// Elle n'est jamais stockée en clair (voir Passenger.Sealed).
+type PassengerDetails struct {
+	FirstName      string `json:"first_name" binding:"required,max=100"`
+	LastName       string `json:"last_name" binding:"required,max=100"`
+	DateOfBirth    string `json:"date_of_birth" binding:"required,datetime=2006-01-02"`
+	Nationality    string `json:"nationality" binding:"required,len=2,alpha"` // ISO 3166-1 alpha-2
+	DocumentNumber string `json:"document_number" binding:"required,max=50"`
+	DocumentExpiry string `json:"document_expiry" binding:"required,datetime=2006-01-02"`
+}
+
+// Passenger : voyageur d'une ligne de commande ; ses données sont chiffrées avec la clé du coffre.
+type Passenger struct {
+	ID               uint   `json:"id" gorm:"primarykey"`
+	OrderID          uint   `json:"order_id" gorm:"index;not null"`
+	TravelID         uint   `json:"travel_id" gorm:"not null"`
+	Sealed           []byte `json:"-" gorm:"not null"` // PassengerDetails chiffré
+	PassengerDetails `gorm:"-"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type PassengerListInput struct {
+	TravelID   uint               `json:"travel_id" binding:"required"`
+	Passengers []PassengerDetails `json:"passengers" binding:"required,min=1,dive"` // autant que de places réservées
+}
+
+// UpdatePassengersInput remplace les voyageurs des travels cités
+type UpdatePassengersInput struct {
+	Items []PassengerListInput `json:"items" binding:"required,min=1,dive"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"h3-travel/money"
+
+	"gorm.io/gorm"
+)
+
+// Payment : encaissement capturé auprès du prestataire pour une commande
+type Payment struct {
+	gorm.Model
+	OrderID       uint        `json:"order_id" gorm:"index;not null"`
+	Provider      string      `json:"provider" gorm:"type:varchar(30);not null"`
+	TransactionID string      `json:"transaction_id" gorm:"type:varchar(100);not null"`
+	Amount        money.Money `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+}
+
+// RefundStatus : avancement d'un remboursement auprès du prestataire
+type RefundStatus string
+
+const (
+	RefundPending   RefundStatus = "pending"   // enregistré, pas encore transmis au prestataire
+	RefundSucceeded RefundStatus = "succeeded" // exécuté (toujours le cas d'un avoir sur le porte-monnaie)
+	RefundFailed    RefundStatus = "failed"    // refusé par le prestataire : toujours dû au client
+)
+
+// Refund : remboursement (total ou partiel) d'un paiement
+type Refund struct {
+	gorm.Model
+	OrderID          uint         `json:"order_id" gorm:"index;not null"`
+	PaymentID        uint         `json:"payment_id" gorm:"index;not null"`
+	Amount           money.Money  `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	Reason           string       `json:"reason" gorm:"type:varchar(255)"`
+	ProviderRefundID string       `json:"provider_refund_id" gorm:"type:varchar(100)"`
+	Actor            string       `json:"actor" gorm:"type:varchar(50);not null"`
+	ToWallet         bool         `json:"to_wallet" gorm:"not null;default:false"` // crédité sur le porte-monnaie
+	Status           RefundStatus `json:"status" gorm:"type:varchar(20);not null;default:'succeeded';index"`
+}
+
+type RefundInput struct {
+	Amount   int64  `json:"amount" binding:"omitempty,gt=0"` // en unités mineures, dans la devise de la commande ; vide : tout le solde remboursable
+	Reason   string `json:"reason" binding:"max=255"`
+	ToWallet bool   `json:"to_wallet"` // en avoir sur le porte-monnaie du client plutôt que sur la carte
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"h3-travel/money"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PricingRuleKind string
+
+const (
+	PricingEarlyBird  PricingRuleKind = "early_bird"  // au moins Days jours avant le départ
+	PricingLastMinute PricingRuleKind = "last_minute" // au plus Days jours avant le départ
+	PricingOccupancy  PricingRuleKind = "occupancy"   // places restantes sous StockPercent % de la capacité
+)
+
+// PricingRule : ajustement du prix de base d'un travel selon la date ou le remplissage.
+// Pour un même type, seule la règle au seuil le plus exigeant atteint s'applique ;
+// les règles de types différents se cumulent.
+type PricingRule struct {
+	gorm.Model
+	Name         string          `json:"name" gorm:"not null"`
+	Kind         PricingRuleKind `json:"kind" gorm:"type:varchar(20);not null"`
+	TravelID     *uint           `json:"travel_id,omitempty" gorm:"index"` // vide : tous les travels
+	Days         int             `json:"days,omitempty"`
+	StockPercent int             `json:"stock_percent,omitempty"`
+	Percent      int             `json:"percent" gorm:"not null"` // négatif : remise, positif : majoration
+	Active       bool            `json:"active" gorm:"default:true"`
+}
+
+type PricingRuleInput struct {
+	Name         string          `json:"name" binding:"required,max=100"`
+	Kind         PricingRuleKind `json:"kind" binding:"required,oneof=early_bird last_minute occupancy"`
+	TravelID     *uint           `json:"travel_id"`
+	Days         int             `json:"days" binding:"min=0"`
+	StockPercent int             `json:"stock_percent" binding:"min=0,max=100"`
+	Percent      int             `json:"percent" binding:"required,min=-100,max=500"`
+	Active       *bool           `json:"active"` // vide : active
+}
+
+// AppliedPricingRule : règle déclenchée dans le calcul d'un prix
+type AppliedPricingRule struct {
+	RuleID     uint            `json:"rule_id"`
+	Name       string          `json:"name"`
+	Kind       PricingRuleKind `json:"kind"`
+	Percent    int             `json:"percent"`
+	Adjustment money.Money     `json:"adjustment"`
+}
+
+// PriceQuote : détail du prix d'un travel à un instant donné
+type PriceQuote struct {
+	TravelID            uint                 `json:"travel_id"`
+	At                  time.Time            `json:"at"`
+	BasePrice           money.Money          `json:"base_price"`
+	Price               money.Money          `json:"price"`
+	DaysBeforeDeparture *int                 `json:"days_before_departure,omitempty"`
+	Stock               int                  `json:"stock"`
+	Capacity            int                  `json:"capacity"`
+	Rules               []AppliedPricingRule `json:"rules"`
+}
+
+// PricingDryRunInput : simulation du prix d'un travel, à une autre date ou avec un autre stock
+type PricingDryRunInput struct {
+	TravelID uint       `json:"travel_id" binding:"required"`
+	At       *time.Time `json:"at"`                              // vide : maintenant
+	Stock    *int       `json:"stock" binding:"omitempty,min=0"` // vide : stock actuel
+}
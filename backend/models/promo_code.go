@@ -0,0 +1,63 @@
+package models
+
+import (
+	"h3-travel/money"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PromoKind string
+
+const (
+	PromoPercent PromoKind = "percent" // Percent % des lignes concernées
+	PromoFixed   PromoKind = "fixed"   // montant fixe, réparti sur les lignes concernées
+)
+
+// PromoCode : code de réduction saisi à la commande
+type PromoCode struct {
+	gorm.Model
+	Code           string            `json:"code" gorm:"type:varchar(50);uniqueIndex;not null"` // toujours en majuscules
+	Kind           PromoKind         `json:"kind" gorm:"type:varchar(10);not null"`
+	Percent        int               `json:"percent,omitempty"`
+	Amount         money.Money       `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`       // remise fixe
+	MinOrder       money.Money       `json:"min_order" gorm:"embedded;embeddedPrefix:min_order_"` // montant 0 : pas de minimum
+	ValidFrom      *time.Time        `json:"valid_from,omitempty"`
+	ValidUntil     *time.Time        `json:"valid_until,omitempty"`
+	MaxUses        int               `json:"max_uses" gorm:"not null;default:0"`          // 0 : illimité
+	MaxUsesPerUser int               `json:"max_uses_per_user" gorm:"not null;default:0"` // 0 : illimité
+	UsedCount      int               `json:"used_count" gorm:"not null;default:0"`
+	Active         bool              `json:"active" gorm:"default:true"`
+	Travels        []PromoCodeTravel `json:"-"`
+	TravelIDs      []uint            `json:"travel_ids" gorm:"-"` // vide : valable sur tous les travels
+}
+
+// PromoCodeTravel restreint un code promo à un travel
+type PromoCodeTravel struct {
+	PromoCodeID uint `gorm:"primaryKey"`
+	TravelID    uint `gorm:"primaryKey"`
+}
+
+// PromoRedemption : utilisation d'un code promo par une commande
+type PromoRedemption struct {
+	ID          uint        `json:"id" gorm:"primarykey"`
+	PromoCodeID uint        `json:"promo_code_id" gorm:"index;not null"`
+	UserID      uint        `json:"user_id" gorm:"index;not null"`
+	OrderID     uint        `json:"order_id" gorm:"uniqueIndex;not null"`
+	Discount    money.Money `json:"discount" gorm:"embedded;embeddedPrefix:discount_"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+type PromoCodeInput struct {
+	Code           string       `json:"code" binding:"required,max=50"`
+	Kind           PromoKind    `json:"kind" binding:"required,oneof=percent fixed"`
+	Percent        int          `json:"percent" binding:"omitempty,min=1,max=100"`
+	Amount         *money.Money `json:"amount"`
+	MinOrder       *money.Money `json:"min_order"`
+	ValidFrom      *time.Time   `json:"valid_from"`
+	ValidUntil     *time.Time   `json:"valid_until"`
+	MaxUses        int          `json:"max_uses" binding:"min=0"`
+	MaxUsesPerUser int          `json:"max_uses_per_user" binding:"min=0"`
+	Active         *bool        `json:"active"` // vide : actif
+	TravelIDs      []uint       `json:"travel_ids"`
+}
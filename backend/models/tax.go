@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Catégories de TVA françaises ; le taux de chaque catégorie est réglable par les admins
+const (
+	TaxStandard     = "standard"      // 20 %
+	TaxIntermediate = "intermediate"  // 10 % (transport de voyageurs, hébergement...)
+	TaxReduced      = "reduced"       // 5,5 %
+	TaxSuperReduced = "super_reduced" // 2,1 %
+	TaxExempt       = "exempt"        // hors champ ou exonéré
+)
+
+// DefaultTaxRates : taux en points de base (2000 = 20 %) appliqués tant qu'un
+// admin n'a pas enregistré de taux pour la catégorie.
+var DefaultTaxRates = map[string]int{
+	TaxStandard:     2000,
+	TaxIntermediate: 1000,
+	TaxReduced:      550,
+	TaxSuperReduced: 210,
+	TaxExempt:       0,
+}
+
+// TaxRate : taux de TVA d'une catégorie, en points de base
+type TaxRate struct {
+	Category  string    `json:"category" gorm:"primaryKey;type:varchar(20)"`
+	Rate      int       `json:"rate" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type TaxRateInput struct {
+	Rate *int `json:"rate" binding:"required,min=0,max=10000"` // points de base : 550 pour 5,5 %
+}
@@ -1,12 +1,140 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"h3-travel/money"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TravelType : nature du produit vendu ; chaque type a ses propres attributs
+type TravelType string
+
+const (
+	TravelFlight  TravelType = "flight"  // vol sec
+	TravelHotel   TravelType = "hotel"   // séjour à l'hôtel
+	TravelPackage TravelType = "package" // séjour composé d'un ou plusieurs vols et hôtels
+)
+
+func (t TravelType) Valid() bool {
+	switch t {
+	case TravelFlight, TravelHotel, TravelPackage:
+		return true
+	}
+	return false
+}
 
 type Travel struct {
 	gorm.Model
-	Title       string  `gorm:"not null"`
-	Description string  `gorm:"type:text"`
-	Price       float64 `gorm:"not null"`
-	Stock       int     `gorm:"not null"`
-	Active      bool    `gorm:"default:true"`
+	Type              TravelType           `gorm:"type:varchar(20);not null;default:'package';index"`
+	Title             string               `gorm:"not null"`
+	Description       string               `gorm:"type:text"`
+	Price             money.Money          `gorm:"embedded;embeddedPrefix:price_"`
+	Stock             int                  `gorm:"not null"`
+	Capacity          int                  `gorm:"not null;default:0"`                           // places au total, sert aux règles de remplissage
+	TaxCategory       string               `gorm:"type:varchar(20);not null;default:'standard'"` // catégorie de TVA (standard, intermediate...)
+	Active            bool                 `gorm:"default:true"`
+	DepartureDate     *time.Time           // sert au calcul de la politique d'annulation
+	CancellationTiers []CancellationTier   `json:"CancellationPolicy,omitempty"`
+	Flight            *FlightDetails       `json:",omitempty"`                             // type flight
+	Hotel             *HotelDetails        `json:",omitempty"`                             // type hotel
+	Components        []PackageComponent   `json:",omitempty" gorm:"foreignKey:PackageID"` // type package
+	BasePrice         *money.Money         `json:",omitempty" gorm:"-"`                    // prix avant règles de tarification, si Price en diffère
+	PricingRules      []AppliedPricingRule `json:",omitempty" gorm:"-"`                    // règles de tarification appliquées à Price
+	DisplayPrice      *money.Money         `json:",omitempty" gorm:"-"`                    // prix converti dans la devise demandée (?currency=)
+	DisplayRate       *ExchangeRate        `json:",omitempty" gorm:"-"`                    // taux appliqué pour DisplayPrice
+	Highlight         string               `json:",omitempty" gorm:"->;-:migration"`       // titre échappé pour HTML, termes recherchés (?q=) entre <mark>
+	Snippet           string               `json:",omitempty" gorm:"->;-:migration"`       // extraits de la description échappés pour HTML, termes recherchés entre <mark>
+}
+
+// FlightDetails : attributs d'un travel de type vol
+type FlightDetails struct {
+	TravelID     uint      `json:"-" gorm:"primaryKey;autoIncrement:false"`
+	Origin       string    `json:"origin" gorm:"type:char(3);not null"`      // code IATA de l'aéroport de départ
+	Destination  string    `json:"destination" gorm:"type:char(3);not null"` // code IATA de l'aéroport d'arrivée
+	Carrier      string    `json:"carrier" gorm:"type:varchar(100);not null"`
+	FlightNumber string    `json:"flight_number,omitempty" gorm:"type:varchar(10)"`
+	DepartureAt  time.Time `json:"departure_at" gorm:"not null"`
+	ArrivalAt    time.Time `json:"arrival_at" gorm:"not null"`
+}
+
+// HotelDetails : attributs d'un travel de type hôtel
+type HotelDetails struct {
+	TravelID  uint            `json:"-" gorm:"primaryKey;autoIncrement:false"`
+	Address   string          `json:"address" gorm:"type:varchar(255);not null"`
+	City      string          `json:"city" gorm:"type:varchar(100);not null"`
+	Country   string          `json:"country" gorm:"type:char(2);not null"` // code ISO 3166-1
+	Stars     int             `json:"stars" gorm:"not null"`                // classement, de 1 à 5
+	Nights    int             `json:"nights" gorm:"not null"`
+	RoomTypes []HotelRoomType `json:"room_types" gorm:"foreignKey:TravelID;references:TravelID"`
+}
+
+// HotelRoomType : type de chambre proposé par un hôtel
+type HotelRoomType struct {
+	ID          uint   `json:"-" gorm:"primarykey"`
+	TravelID    uint   `json:"-" gorm:"index;not null"`
+	Name        string `json:"name" gorm:"type:varchar(100);not null"`
+	Capacity    int    `json:"capacity" gorm:"not null"` // personnes
+	Description string `json:"description,omitempty" gorm:"type:varchar(255)"`
+}
+
+// PackageComponent : vol ou hôtel du catalogue inclus dans un séjour packagé
+type PackageComponent struct {
+	ID        uint    `json:"-" gorm:"primarykey"`
+	PackageID uint    `json:"-" gorm:"index;not null"`
+	TravelID  uint    `json:"travel_id" gorm:"index;not null"`
+	Position  int     `json:"-" gorm:"not null;default:0"`
+	Travel    *Travel `json:"travel,omitempty" gorm:"foreignKey:TravelID"`
+}
+
+// TravelFilter : critères de recherche du catalogue (query string)
+type TravelFilter struct {
+	Q             string     `form:"q" binding:"max=200"`                 // recherche plein texte sur le titre et la description
+	MinPrice      *int64     `form:"min_price" binding:"omitempty,min=0"` // en unités mineures, prix de catalogue
+	MaxPrice      *int64     `form:"max_price" binding:"omitempty,min=0"`
+	PriceCurrency string     `form:"price_currency"` // devise de min_price et max_price (EUR par défaut)
+	Type          TravelType `form:"type" binding:"omitempty,oneof=flight hotel package"`
+	Country       string     `form:"country" binding:"omitempty,len=2"`               // pays de destination (ISO 3166-1) : celui de l'hôtel, ou d'un hôtel du séjour
+	Active        string     `form:"active" binding:"omitempty,oneof=true false all"` // false et all réservés aux admins
+	InStock       bool       `form:"in_stock"`                                        // seulement les travels ayant encore des places
+	CreatedFrom   time.Time  `form:"created_from" time_format:"2006-01-02"`           // créés à partir de ce jour inclus
+	CreatedTo     time.Time  `form:"created_to" time_format:"2006-01-02"`             // créés jusqu'à ce jour inclus
+	Sort          string     `form:"sort" binding:"omitempty,oneof=relevance price -price created_at -created_at title -title departure_date -departure_date stock -stock"`
+	Page          int        `form:"page" binding:"omitempty,min=1"`
+	PageSize      int        `form:"page_size" binding:"omitempty,min=1,max=100"`
+}
+
+// TravelPage : une page du catalogue et sa position dans les résultats
+type TravelPage struct {
+	Data       []Travel   `json:"data"`
+	Pagination Pagination `json:"pagination"`
+}
+
+// Valeurs de la facette de disponibilité
+const (
+	FacetAvailable = "available" // au moins une place
+	FacetSoldOut   = "sold_out"
+)
+
+// FacetCount : nombre de travels ayant une valeur donnée
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// PriceFacet : nombre de travels dont le prix de catalogue est dans [Min, Max[
+type PriceFacet struct {
+	Min   money.Money  `json:"min"`
+	Max   *money.Money `json:"max,omitempty"` // absent pour la dernière tranche
+	Count int64        `json:"count"`
+}
+
+// TravelFacets : répartition des travels d'une recherche, pour l'affinement.
+// Chaque facette ignore son propre critère, pour montrer les autres choix possibles.
+type TravelFacets struct {
+	Total        int64        `json:"total"` // travels correspondant à tous les critères
+	Types        []FacetCount `json:"types"`
+	Prices       []PriceFacet `json:"prices"` // dans la devise price_currency
+	Countries    []FacetCount `json:"countries"`
+	Availability []FacetCount `json:"availability"` // available, sold_out
 }
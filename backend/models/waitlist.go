@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+type WaitlistStatus string
+
+const (
+	WaitlistWaiting   WaitlistStatus = "waiting"
+	WaitlistOffered   WaitlistStatus = "offered"   // une réservation limitée dans le temps a été proposée
+	WaitlistClaimed   WaitlistStatus = "claimed"   // la réservation proposée a été payée
+	WaitlistExpired   WaitlistStatus = "expired"   // la réservation proposée n'a pas été payée à temps
+	WaitlistDeclined  WaitlistStatus = "declined"  // la réservation proposée a été annulée
+	WaitlistCancelled WaitlistStatus = "cancelled" // l'utilisateur a quitté la liste
+)
+
+// WaitlistEntry : inscription d'un utilisateur sur la liste d'attente d'un travel complet
+type WaitlistEntry struct {
+	ID             uint           `json:"id" gorm:"primarykey"`
+	TravelID       uint           `json:"travel_id" gorm:"index:idx_waitlist_travel_status;not null"`
+	UserID         uint           `json:"user_id" gorm:"index;not null"`
+	Quantity       int            `json:"quantity" gorm:"not null"`
+	Status         WaitlistStatus `json:"status" gorm:"type:varchar(20);index:idx_waitlist_travel_status;not null"`
+	OrderID        *uint          `json:"order_id,omitempty" gorm:"index"` // réservation proposée
+	OfferExpiresAt *time.Time     `json:"offer_expires_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	Position       int            `json:"position,omitempty" gorm:"-"`
+}
+
+type JoinWaitlistInput struct {
+	Quantity int `json:"quantity" binding:"omitempty,min=1"` // 1 par défaut
+}
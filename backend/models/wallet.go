@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"h3-travel/money"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WalletProvider : prestataire des paiements réglés avec le porte-monnaie
+const WalletProvider = "wallet"
+
+// Wallet : solde du porte-monnaie d'un utilisateur dans une devise. Il n'est
+// jamais modifié sans une écriture du registre dans la même transaction.
+type Wallet struct {
+	UserID    uint      `json:"user_id" gorm:"primaryKey;autoIncrement:false"`
+	Currency  string    `json:"currency" gorm:"primaryKey;type:char(3)"`
+	Balance   int64     `json:"balance" gorm:"not null;default:0"` // en unités mineures
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WalletTransactionKind string
+
+const (
+	WalletGiftCard WalletTransactionKind = "gift_card" // crédit : carte cadeau utilisée
+	WalletRefund   WalletTransactionKind = "refund"    // crédit : remboursement en avoir
+	WalletPayment  WalletTransactionKind = "payment"   // débit : paiement (partiel) d'une commande
+	WalletRelease  WalletTransactionKind = "release"   // crédit : commande expirée ou annulée avant paiement
+)
+
+var ErrLedgerAppendOnly = errors.New("une écriture de registre ne peut être ni modifiée ni supprimée")
+
+// WalletTransaction : écriture du registre du porte-monnaie. Le registre est en
+// ajout seul : une erreur se corrige par une nouvelle écriture.
+type WalletTransaction struct {
+	ID           uint                  `json:"id" gorm:"primarykey"`
+	UserID       uint                  `json:"user_id" gorm:"index;not null"`
+	Kind         WalletTransactionKind `json:"kind" gorm:"type:varchar(20);not null"`
+	Amount       money.Money           `json:"amount" gorm:"embedded;embeddedPrefix:amount_"` // négatif pour un débit
+	BalanceAfter money.Money           `json:"balance_after" gorm:"embedded;embeddedPrefix:balance_after_"`
+	OrderID      *uint                 `json:"order_id,omitempty" gorm:"index"`
+	GiftCardID   *uint                 `json:"gift_card_id,omitempty"`
+	Reason       string                `json:"reason,omitempty" gorm:"type:varchar(255)"`
+	CreatedAt    time.Time             `json:"created_at"`
+}
+
+func (WalletTransaction) BeforeUpdate(*gorm.DB) error {
+	return ErrLedgerAppendOnly
+}
+
+func (WalletTransaction) BeforeDelete(*gorm.DB) error {
+	return ErrLedgerAppendOnly
+}
+
+// WalletSummary : soldes par devise et dernières écritures du porte-monnaie
+type WalletSummary struct {
+	Balances     []money.Money       `json:"balances"`
+	Transactions []WalletTransaction `json:"transactions"`
+}
+
+// GiftCard : carte cadeau, créditée sur le porte-monnaie de qui l'utilise. Seule
+// l'empreinte du code est conservée ; le code n'est communiqué qu'à l'émission.
+type GiftCard struct {
+	gorm.Model
+	CodeHash   string      `json:"-" gorm:"type:char(64);uniqueIndex;not null"`
+	Code       string      `json:"code,omitempty" gorm:"-"`
+	Last4      string      `json:"last4" gorm:"type:char(4);not null"`
+	Amount     money.Money `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	ExpiresAt  *time.Time  `json:"expires_at,omitempty"`
+	Note       string      `json:"note,omitempty" gorm:"type:varchar(255)"` // destinataire, référence de la vente...
+	IssuedBy   string      `json:"issued_by" gorm:"type:varchar(50);not null"`
+	RedeemedBy *uint       `json:"redeemed_by,omitempty" gorm:"index"`
+	RedeemedAt *time.Time  `json:"redeemed_at,omitempty"`
+}
+
+type GiftCardInput struct {
+	Amount    money.Money `json:"amount"`
+	ExpiresAt *time.Time  `json:"expires_at"`
+	Note      string      `json:"note" binding:"max=255"`
+}
+
+type RedeemGiftCardInput struct {
+	Code string `json:"code" binding:"required,max=50"`
+}
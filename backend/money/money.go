@@ -0,0 +1,197 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DefaultCurrency : devise utilisée quand aucune n'est précisée
+const DefaultCurrency = "EUR"
+
+var (
+	ErrInvalidCurrency  = errors.New("devise invalide")
+	ErrCurrencyMismatch = errors.New("montants dans des devises différentes")
+)
+
+// Money : montant exact, en unités mineures (centimes pour l'euro), dans une
+// devise ISO 4217. Embarqué dans un modèle avec un préfixe, il occupe deux
+// colonnes : <préfixe>minor et <préfixe>currency.
+type Money struct {
+	Amount   int64  `json:"amount" gorm:"column:minor;not null;default:0"`
+	Currency string `json:"currency" gorm:"column:currency;type:char(3);not null;default:'EUR'"`
+}
+
+// exponents : nombre de décimales des devises qui n'en ont pas deux
+var exponents = map[string]int{
+	"JPY": 0, "KRW": 0, "CLP": 0, "ISK": 0, "XOF": 0, "XAF": 0, "XPF": 0,
+	"BHD": 3, "KWD": 3, "OMR": 3, "TND": 3, "JOD": 3,
+}
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+func Zero(currency string) Money {
+	return Money{Currency: currency}
+}
+
+// Exponent : nombre de décimales de la devise (2 par défaut)
+func Exponent(currency string) int {
+	if exp, ok := exponents[currency]; ok {
+		return exp
+	}
+	return 2
+}
+
+// NormalizeCurrency met le code en majuscules et vérifie qu'il a la forme ISO 4217 (trois lettres).
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return code, ErrInvalidCurrency
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return code, ErrInvalidCurrency
+		}
+	}
+	return code, nil
+}
+
+// compatible : même devise, ou l'un des deux est un montant nul sans devise
+func (m Money) compatible(o Money) bool {
+	return m.Currency == o.Currency || (o.Currency == "" && o.Amount == 0) || (m.Currency == "" && m.Amount == 0)
+}
+
+func (m Money) currencyWith(o Money) string {
+	if m.Currency != "" {
+		return m.Currency
+	}
+	return o.Currency
+}
+
+// Add additionne deux montants de même devise. Des devises différentes sont
+// une erreur de programmation : Add panique plutôt que de produire un total faux.
+func (m Money) Add(o Money) Money {
+	sum, err := m.CheckedAdd(o)
+	if err != nil {
+		panic(fmt.Sprintf("money: addition de devises différentes (%s, %s)", m.Currency, o.Currency))
+	}
+	return sum
+}
+
+// Sub soustrait deux montants de même devise ; panique sinon, comme Add.
+func (m Money) Sub(o Money) Money {
+	diff, err := m.CheckedSub(o)
+	if err != nil {
+		panic(fmt.Sprintf("money: soustraction de devises différentes (%s, %s)", m.Currency, o.Currency))
+	}
+	return diff
+}
+
+// CheckedAdd : comme Add, mais retourne ErrCurrencyMismatch au lieu de paniquer.
+// À utiliser sur des montants lus en base, qui peuvent être incohérents.
+func (m Money) CheckedAdd(o Money) (Money, error) {
+	if !m.compatible(o) {
+		return Money{}, fmt.Errorf("%w (%s, %s)", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.currencyWith(o)}, nil
+}
+
+// CheckedSub : comme Sub, mais retourne ErrCurrencyMismatch au lieu de paniquer.
+func (m Money) CheckedSub(o Money) (Money, error) {
+	if !m.compatible(o) {
+		return Money{}, fmt.Errorf("%w (%s, %s)", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return Money{Amount: m.Amount - o.Amount, Currency: m.currencyWith(o)}, nil
+}
+
+func (m Money) Mul(n int) Money {
+	return Money{Amount: m.Amount * int64(n), Currency: m.Currency}
+}
+
+// Percent retourne percent % du montant, arrondi à l'unité mineure la plus proche.
+func (m Money) Percent(percent int) Money {
+	return Money{Amount: divRound(m.Amount*int64(percent), 100), Currency: m.Currency}
+}
+
+// Prorate retourne la part part/whole du montant, arrondie à l'unité mineure la plus proche.
+func (m Money) Prorate(part, whole int64) Money {
+	if whole == 0 {
+		return Zero(m.Currency)
+	}
+	return Money{Amount: divRound(m.Amount*part, whole), Currency: m.Currency}
+}
+
+// IncludedTax retourne la taxe comprise dans un montant TTC, pour un taux en
+// points de base (2000 = 20 %) : m - m / (1 + taux), arrondi au plus proche.
+func (m Money) IncludedTax(basisPoints int) Money {
+	net := divRound(m.Amount*10000, 10000+int64(basisPoints))
+	return Money{Amount: m.Amount - net, Currency: m.Currency}
+}
+
+// Min retourne le plus petit des deux montants (de même devise).
+func (m Money) Min(o Money) Money {
+	if m.Sub(o).IsNegative() {
+		return m
+	}
+	return o
+}
+
+// CheckedMin : comme Min, mais retourne ErrCurrencyMismatch au lieu de paniquer.
+func (m Money) CheckedMin(o Money) (Money, error) {
+	diff, err := m.CheckedSub(o)
+	if err != nil {
+		return Money{}, err
+	}
+	if diff.IsNegative() {
+		return m, nil
+	}
+	return o, nil
+}
+
+func (m Money) IsZero() bool     { return m.Amount == 0 }
+func (m Money) IsPositive() bool { return m.Amount > 0 }
+func (m Money) IsNegative() bool { return m.Amount < 0 }
+
+// Convert convertit le montant dans la devise to au taux rate (1 unité de
+// m.Currency = rate unités de to), arrondi à l'unité mineure la plus proche.
+func (m Money) Convert(to string, rate float64) Money {
+	shift := math.Pow10(Exponent(to) - Exponent(m.Currency))
+	return Money{Amount: int64(math.Round(float64(m.Amount) * rate * shift)), Currency: to}
+}
+
+// String : "299.99 EUR"
+func (m Money) String() string {
+	exp := Exponent(m.Currency)
+	if exp == 0 {
+		return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+	}
+
+	sign, amount := "", m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	unit := int64(math.Pow10(exp))
+	return fmt.Sprintf("%s%d.%0*d %s", sign, amount/unit, exp, amount%unit, m.Currency)
+}
+
+// divRound divise en arrondissant au plus proche (les demis s'éloignent de zéro)
+func divRound(a, b int64) int64 {
+	q, r := a/b, a%b
+	if 2*abs(r) >= abs(b) {
+		if (a < 0) != (b < 0) {
+			return q - 1
+		}
+		return q + 1
+	}
+	return q
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
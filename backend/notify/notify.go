@@ -0,0 +1,29 @@
+package notify
+
+import (
+	"context"
+	"log"
+)
+
+// Notifier envoie un message à un utilisateur (email, push...).
+type Notifier interface {
+	Notify(ctx context.Context, userID uint, subject, message string) error
+}
+
+// LogNotifier se contente de journaliser les messages ; à remplacer par un vrai canal d'envoi.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, userID uint, subject, message string) error {
+	log.Printf("Notification utilisateur %d : %s - %s", userID, subject, message)
+	return nil
+}
+
+// Default est le canal utilisé par les services
+var Default Notifier = LogNotifier{}
+
+// Send envoie via Default ; un échec d'envoi est journalisé sans interrompre le traitement.
+func Send(ctx context.Context, userID uint, subject, message string) {
+	if err := Default.Notify(ctx, userID, subject, message); err != nil {
+		log.Printf("Échec de la notification à l'utilisateur %d : %v", userID, err)
+	}
+}
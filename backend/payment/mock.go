@@ -0,0 +1,74 @@
+package payment
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// Numéros de carte « magiques » reconnus par le prestataire mock, sur le modèle des bacs à sable réels.
+const (
+	CardDeclined          = "4000000000000002"
+	CardInsufficientFunds = "4000000000009995"
+	CardTimeout           = "4000000000000119"
+)
+
+const mockAuthPrefix = "mock_auth_"
+
+func init() {
+	Register("mock", func() (Provider, error) { return NewMockProvider(), nil })
+}
+
+// MockProvider est un prestataire sans état : le résultat d'une autorisation ne
+// dépend que du numéro de carte, toute carte étant acceptée sauf les numéros
+// magiques ci-dessus. Les montants sont contrôlés par notre propre registre.
+type MockProvider struct{}
+
+func NewMockProvider() *MockProvider {
+	return &MockProvider{}
+}
+
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+func newMockID(prefix string) string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
+
+func checkMockTransaction(transactionID string) error {
+	if !strings.HasPrefix(transactionID, mockAuthPrefix) {
+		return ErrUnknownTransaction
+	}
+	return nil
+}
+
+func (p *MockProvider) Authorize(ctx context.Context, req Request) (string, error) {
+	switch req.Card {
+	case CardDeclined:
+		return "", ErrDeclined
+	case CardInsufficientFunds:
+		return "", ErrInsufficientFunds
+	case CardTimeout:
+		return "", ErrTimeout
+	}
+	return newMockID(mockAuthPrefix), nil
+}
+
+func (p *MockProvider) Capture(ctx context.Context, transactionID string, amount int64) error {
+	return checkMockTransaction(transactionID)
+}
+
+func (p *MockProvider) Void(ctx context.Context, transactionID string) error {
+	return checkMockTransaction(transactionID)
+}
+
+func (p *MockProvider) Refund(ctx context.Context, transactionID string, amount int64) (string, error) {
+	if err := checkMockTransaction(transactionID); err != nil {
+		return "", err
+	}
+	return newMockID("mock_refund_"), nil
+}
@@ -0,0 +1,59 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrDeclined           = errors.New("paiement refusé")
+	ErrInsufficientFunds  = errors.New("fonds insuffisants")
+	ErrTimeout            = errors.New("délai dépassé auprès du prestataire de paiement")
+	ErrUnknownTransaction = errors.New("transaction inconnue")
+)
+
+// Request décrit un paiement ; Amount est exprimé en unités mineures (centimes).
+type Request struct {
+	Amount   int64
+	Currency string
+	Card     string
+}
+
+// Provider est l'interface commune aux prestataires de paiement.
+type Provider interface {
+	Name() string
+	// Authorize bloque le montant et retourne l'identifiant de la transaction
+	Authorize(ctx context.Context, req Request) (string, error)
+	Capture(ctx context.Context, transactionID string, amount int64) error
+	Void(ctx context.Context, transactionID string) error
+	// Refund rembourse tout ou partie d'une transaction capturée et retourne l'identifiant du remboursement
+	Refund(ctx context.Context, transactionID string, amount int64) (string, error)
+}
+
+var factories = map[string]func() (Provider, error){}
+
+// Register rend un prestataire disponible via la variable PAYMENT_PROVIDER.
+func Register(name string, factory func() (Provider, error)) {
+	factories[name] = factory
+}
+
+// New instancie le prestataire configuré ; "mock" par défaut.
+func New(name string) (Provider, error) {
+	if name == "" {
+		name = "mock"
+	}
+
+	factory, ok := factories[name]
+	if !ok {
+		names := make([]string, 0, len(factories))
+		for n := range factories {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("prestataire de paiement inconnu %q (disponibles : %s)", name, strings.Join(names, ", "))
+	}
+	return factory()
+}
@@ -16,21 +16,85 @@ func SetupRouter() *gin.Engine {
 		api.POST("/login", controllers.Login)
 
 		travel := api.Group("/travels")
-		travel.GET("", controllers.GetTravels)
+		travel.GET("", middlewares.OptionalAuthMiddleware(), controllers.GetTravels)
+		travel.GET("/facets", middlewares.OptionalAuthMiddleware(), controllers.GetTravelFacets)
 		travel.GET("/:id", controllers.GetTravel)
+		travel.GET("/:id/departures", controllers.ListDepartures)
+		travel.POST("/:id/waitlist", middlewares.JWTMiddleware(), controllers.JoinWaitlist)
+		travel.DELETE("/:id/waitlist", middlewares.JWTMiddleware(), controllers.LeaveWaitlist)
 		travel.Use(middlewares.AdminMiddleware())
 		{
 			travel.POST("", controllers.CreateTravel)
 			travel.PUT("/:id", controllers.UpdateTravel)
 			travel.DELETE("/:id", controllers.DeleteTravel)
+			travel.PUT("/:id/cancellation-policy", controllers.SetCancellationPolicy)
+			travel.POST("/:id/departures", controllers.CreateDeparture)
+			travel.PUT("/:id/departures/:departure_id", controllers.UpdateDeparture)
+			travel.DELETE("/:id/departures/:departure_id", controllers.DeleteDeparture)
+		}
+
+		cards := api.Group("/cards")
+		cards.Use(middlewares.JWTMiddleware())
+		{
+			cards.POST("/tokenize", controllers.TokenizeCard)
+		}
+
+		wallet := api.Group("/wallet")
+		wallet.Use(middlewares.JWTMiddleware())
+		{
+			wallet.GET("", controllers.GetWallet)
+			wallet.POST("/redeem", middlewares.Idempotency(), controllers.RedeemGiftCard)
+		}
+
+		me := api.Group("/me")
+		me.Use(middlewares.JWTMiddleware())
+		{
+			me.GET("/loyalty", controllers.GetLoyalty)
 		}
 
 		orders := api.Group("/orders")
 		orders.Use(middlewares.JWTMiddleware())
 		{
-			orders.POST("", controllers.CreateOrder)
+			orders.POST("", middlewares.Idempotency(), controllers.CreateOrder)
+			orders.POST("/:id/pay", middlewares.Idempotency(), controllers.ConfirmPayment)
 			orders.GET("/user", controllers.GetUserOrders)
-			orders.PUT("/:id/cancel", controllers.CancelOrder)
+			orders.GET("/:id/cancellation-quote", controllers.GetCancellationQuote)
+			orders.PUT("/:id/cancel", middlewares.Idempotency(), controllers.CancelOrder)
+			orders.GET("/:id/invoice", controllers.GetInvoice)
+			orders.GET("/:id/credit-notes/:number", controllers.GetCreditNote)
+			orders.GET("/:id/passengers", controllers.GetPassengers)
+			orders.PUT("/:id/passengers", controllers.UpdatePassengers)
+		}
+
+		admin := api.Group("/admin")
+		admin.Use(middlewares.AdminMiddleware())
+		{
+			admin.GET("/orders", controllers.AdminListOrders)
+			admin.GET("/orders/:id", controllers.AdminGetOrder)
+			admin.PUT("/orders/:id/confirm", controllers.AdminConfirmOrder)
+			admin.PUT("/orders/:id/cancel", middlewares.Idempotency(), controllers.AdminCancelOrder)
+			admin.POST("/orders/:id/refund", middlewares.Idempotency(), controllers.AdminRefundOrder)
+			admin.POST("/orders/:id/refunds/retry", controllers.AdminRetryRefunds)
+			admin.POST("/orders/:id/notes", controllers.AdminAddOrderNote)
+			admin.GET("/rates", controllers.AdminListExchangeRates)
+			admin.PUT("/rates/:base/:quote", controllers.AdminSetExchangeRate)
+			admin.GET("/promo-codes", controllers.AdminListPromoCodes)
+			admin.POST("/promo-codes", controllers.AdminCreatePromoCode)
+			admin.GET("/promo-codes/:id", controllers.AdminGetPromoCode)
+			admin.PUT("/promo-codes/:id", controllers.AdminUpdatePromoCode)
+			admin.DELETE("/promo-codes/:id", controllers.AdminDeletePromoCode)
+			admin.GET("/pricing-rules", controllers.AdminListPricingRules)
+			admin.POST("/pricing-rules", controllers.AdminCreatePricingRule)
+			admin.POST("/pricing-rules/dry-run", controllers.AdminPricingDryRun)
+			admin.GET("/pricing-rules/:id", controllers.AdminGetPricingRule)
+			admin.PUT("/pricing-rules/:id", controllers.AdminUpdatePricingRule)
+			admin.DELETE("/pricing-rules/:id", controllers.AdminDeletePricingRule)
+			admin.GET("/tax-rates", controllers.AdminListTaxRates)
+			admin.PUT("/tax-rates/:category", controllers.AdminSetTaxRate)
+			admin.GET("/gift-cards", controllers.AdminListGiftCards)
+			admin.POST("/gift-cards", controllers.AdminIssueGiftCard)
+			admin.GET("/loyalty", controllers.AdminGetLoyaltyProgram)
+			admin.PUT("/loyalty", controllers.AdminSetLoyaltyProgram)
 		}
 	}
 
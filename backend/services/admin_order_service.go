@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"h3-travel/models"
+	"h3-travel/payment"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultAdminPageSize = 50
+
+// ListOrders retourne une page de commandes correspondant au filtre, les plus
+// récentes d'abord, ainsi que le nombre total de commandes correspondantes.
+func ListOrders(db *gorm.DB, filter models.AdminOrderFilter) ([]models.Order, int64, error) {
+	query := db.Model(&models.Order{})
+	if filter.Status != "" {
+		query = query.Where("statut = ?", filter.Status)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.TravelID != 0 {
+		query = query.Where("id IN (?)", db.Model(&models.OrderItem{}).Select("order_id").Where("travel_id = ?", filter.TravelID))
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	page, pageSize := filter.Page, filter.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultAdminPageSize
+	}
+
+	var orders []models.Order
+	err := query.Preload("Items").
+		Order("created_at DESC").Order("id DESC").
+		Limit(pageSize).Offset((page - 1) * pageSize).
+		Find(&orders).Error
+	return orders, total, err
+}
+
+// GetOrderDetails charge une commande quelconque avec son historique, ses
+// paiements, ses notes internes, son acheteur et les travels commandés.
+func GetOrderDetails(db *gorm.DB, orderID uint) (models.AdminOrderDetails, error) {
+	var details models.AdminOrderDetails
+
+	err := db.Preload("Items").
+		Preload("History", func(tx *gorm.DB) *gorm.DB { return tx.Order("changed_at").Order("id") }).
+		Preload("Payments").Preload("Refunds").Preload("Invoices").
+		Preload("Notes", func(tx *gorm.DB) *gorm.DB { return tx.Order("created_at").Order("id") }).
+		First(&details.Order, orderID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return details, ErrOrderNotFound
+		}
+		return details, err
+	}
+
+	// Unscoped : un compte ou un travel supprimé depuis reste consultable
+	var user models.User
+	if err := db.Unscoped().First(&user, details.UserID).Error; err == nil {
+		details.Customer = &models.OrderCustomer{ID: user.ID, Email: user.Email, Role: user.Role}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return details, err
+	}
+
+	travelIDs := make([]uint, 0, len(details.Items))
+	for _, item := range details.Items {
+		travelIDs = append(travelIDs, item.TravelID)
+	}
+	if len(travelIDs) > 0 {
+		if err := db.Unscoped().Where("id IN ?", travelIDs).Order("id").Find(&details.Travels).Error; err != nil {
+			return details, err
+		}
+	}
+
+	return details, nil
+}
+
+// ForceCancelOrder annule n'importe quelle commande pour le compte d'un admin.
+// Par défaut tout le solde est remboursé, sans appliquer la politique d'annulation.
+func ForceCancelOrder(ctx context.Context, db *gorm.DB, provider payment.Provider, orderID uint, mode models.RefundMode, toWallet bool, reason, actor string, offerTTL time.Duration) (models.Order, models.CancellationQuote, error) {
+	if mode == "" {
+		mode = models.RefundFull
+	}
+	if reason == "" {
+		reason = "Annulation par un administrateur"
+	}
+	return cancelOrder(ctx, db, provider, orderID, 0, mode, toWallet, reason, actor, offerTTL)
+}
+
+// AddOrderNote ajoute une note interne à une commande.
+func AddOrderNote(db *gorm.DB, orderID, authorID uint, body string) (models.OrderNote, error) {
+	note := models.OrderNote{OrderID: orderID, AuthorID: authorID, Body: body}
+
+	var count int64
+	if err := db.Model(&models.Order{}).Where("id = ?", orderID).Count(&count).Error; err != nil {
+		return note, err
+	}
+	if count == 0 {
+		return note, ErrOrderNotFound
+	}
+
+	err := db.Create(&note).Error
+	return note, err
+}
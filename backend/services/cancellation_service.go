@@ -0,0 +1,122 @@
+package services
+
+import (
+	"errors"
+	"h3-travel/models"
+	"h3-travel/money"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// daysBefore : nombre de jours entiers restant avant le départ (négatif une fois parti)
+func daysBefore(departure, now time.Time) int {
+	return int(math.Floor(departure.Sub(now).Hours() / 24))
+}
+
+// quoteCancellation applique à chaque ligne la politique d'annulation de son
+// travel selon le temps restant avant le départ. Le total est plafonné au
+// solde encore remboursable de la commande.
+func quoteCancellation(db *gorm.DB, order models.Order, now time.Time) (models.CancellationQuote, error) {
+	currency := order.PaidAmount.Currency
+	quote := models.CancellationQuote{OrderID: order.ID, PaidAmount: order.PaidAmount, RefundAmount: money.Zero(currency)}
+
+	travelIDs := make([]uint, 0, len(order.Items))
+	for _, item := range order.Items {
+		travelIDs = append(travelIDs, item.TravelID)
+	}
+
+	// Unscoped : un travel supprimé depuis garde sa politique
+	var travels []models.Travel
+	if len(travelIDs) > 0 {
+		if err := db.Unscoped().Preload("CancellationTiers").Where("id IN ?", travelIDs).Find(&travels).Error; err != nil {
+			return quote, err
+		}
+	}
+	byID := make(map[uint]models.Travel, len(travels))
+	for _, travel := range travels {
+		byID[travel.ID] = travel
+	}
+	departures, err := departureDates(db, order.Items)
+	if err != nil {
+		return quote, err
+	}
+
+	for _, item := range order.Items {
+		travel := byID[item.TravelID]
+		line := models.CancellationQuoteLine{
+			TravelID:      item.TravelID,
+			RefundPercent: 100,
+			Amount:        item.LineTotal(),
+		}
+		if departure := departures[item.TravelID]; departure != nil {
+			days := daysBefore(*departure, now)
+			line.DaysBeforeDeparture = &days
+			line.RefundPercent = models.RefundPercentFor(travel.CancellationTiers, days)
+		}
+		line.Refund = line.Amount.Percent(line.RefundPercent)
+
+		quote.Lines = append(quote.Lines, line)
+		if quote.RefundAmount, err = quote.RefundAmount.CheckedAdd(line.Refund); err != nil {
+			return quote, err
+		}
+	}
+
+	refundable := order.RefundableAmount
+	if refundable.IsNegative() {
+		refundable = money.Zero(currency)
+	}
+	quote.RefundAmount, err = quote.RefundAmount.CheckedMin(refundable)
+	return quote, err
+}
+
+// QuoteCancellation calcule, sans rien modifier, le remboursement qu'obtiendrait l'utilisateur en annulant maintenant.
+func QuoteCancellation(db *gorm.DB, userID, orderID uint) (models.CancellationQuote, error) {
+	var order models.Order
+	if err := db.Preload("Items").First(&order, "id = ? AND user_id = ?", orderID, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.CancellationQuote{}, ErrOrderNotFound
+		}
+		return models.CancellationQuote{}, err
+	}
+
+	if !order.Statut.CanTransitionTo(models.StatusCancelled) {
+		return models.CancellationQuote{}, &models.TransitionError{From: order.Statut, To: models.StatusCancelled}
+	}
+
+	return quoteCancellation(db, order, time.Now())
+}
+
+// SetCancellationPolicy remplace les paliers de la politique d'annulation d'un travel.
+func SetCancellationPolicy(db *gorm.DB, travelID uint, tiers []models.CancellationTierInput) (models.Travel, error) {
+	var travel models.Travel
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&travel, travelID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTravelNotFound
+			}
+			return err
+		}
+
+		if err := tx.Where("travel_id = ?", travelID).Delete(&models.CancellationTier{}).Error; err != nil {
+			return err
+		}
+
+		travel.CancellationTiers = nil
+		for _, tier := range tiers {
+			travel.CancellationTiers = append(travel.CancellationTiers, models.CancellationTier{
+				TravelID:      travelID,
+				MinDaysBefore: tier.MinDaysBefore,
+				RefundPercent: tier.RefundPercent,
+			})
+		}
+		if len(travel.CancellationTiers) == 0 {
+			return nil
+		}
+		return tx.Create(&travel.CancellationTiers).Error
+	})
+
+	return travel, err
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"errors"
+	"h3-travel/card"
+	"h3-travel/models"
+	"h3-travel/vault"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrCardTokenNotFound = errors.New("token de carte inconnu")
+
+// TokenizeCard chiffre le numéro d'une carte déjà validée et retourne le token opaque associé.
+func TokenizeCard(db *gorm.DB, key []byte, userID uint, validated card.Card, expMonth, expYear int) (models.CardToken, error) {
+	encrypted, err := vault.Seal(key, []byte(validated.Number), nil)
+	if err != nil {
+		return models.CardToken{}, err
+	}
+
+	token, err := vault.NewToken("tok_")
+	if err != nil {
+		return models.CardToken{}, err
+	}
+
+	if expYear < 100 {
+		expYear += 2000
+	}
+	entry := models.CardToken{
+		Token:        token,
+		UserID:       userID,
+		EncryptedPAN: encrypted,
+		Brand:        string(validated.Brand),
+		Last4:        validated.Last4,
+		ExpMonth:     expMonth,
+		ExpYear:      expYear,
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		return models.CardToken{}, err
+	}
+	return entry, nil
+}
+
+// resolveCardToken retrouve la carte d'un utilisateur et déchiffre son numéro,
+// qui n'est transmis qu'au prestataire de paiement.
+func resolveCardToken(db *gorm.DB, key []byte, userID uint, token string) (models.CardToken, string, error) {
+	var entry models.CardToken
+	if err := db.Where("token = ? AND user_id = ?", token, userID).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return entry, "", ErrCardTokenNotFound
+		}
+		return entry, "", err
+	}
+
+	if err := card.ValidateExpiry(entry.ExpMonth, entry.ExpYear, time.Now()); err != nil {
+		return entry, "", err
+	}
+
+	pan, err := vault.Open(key, entry.EncryptedPAN, nil)
+	if err != nil {
+		return entry, "", err
+	}
+	return entry, string(pan), nil
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"h3-travel/models"
+	"h3-travel/money"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDepartureNotFound = errors.New("départ non trouvé")
+	ErrDepartureInvalid  = errors.New("départ invalide")
+	ErrDepartureRequired = errors.New("ce travel se réserve par départ : departure_id requis")
+	ErrDepartureBooked   = errors.New("des places sont déjà réservées sur ce départ")
+)
+
+// markBookable renseigne Bookable : un départ passé ou complet ne se réserve plus.
+func markBookable(departures []models.Departure, now time.Time) {
+	for i := range departures {
+		departures[i].Bookable = departures[i].StartDate.After(now) && departures[i].Stock > 0
+	}
+}
+
+// ListDepartures retourne les départs du travel par date, seulement ceux à venir
+// sauf si includePast.
+func ListDepartures(db *gorm.DB, travelID uint, includePast bool, now time.Time) ([]models.Departure, error) {
+	var travel models.Travel
+	if err := db.Select("id").First(&travel, travelID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTravelNotFound
+		}
+		return nil, err
+	}
+
+	departures := []models.Departure{}
+	query := db.Where("travel_id = ?", travelID)
+	if !includePast {
+		query = query.Where("start_date > ?", now)
+	}
+	if err := query.Order("start_date").Order("id").Find(&departures).Error; err != nil {
+		return nil, err
+	}
+	markBookable(departures, now)
+	return departures, nil
+}
+
+// departurePrice valide les dates et le prix saisis. Le prix d'un départ est
+// dans la devise du travel pour que ses départs puissent figurer dans une même commande.
+func departurePrice(travel models.Travel, input models.DepartureInput, now time.Time) (money.Money, error) {
+	if !input.StartDate.After(now) {
+		return money.Money{}, fmt.Errorf("%w : la date de départ doit être à venir", ErrDepartureInvalid)
+	}
+	if input.EndDate.Before(input.StartDate) {
+		return money.Money{}, fmt.Errorf("%w : la date de retour précède le départ", ErrDepartureInvalid)
+	}
+
+	price := money.Zero(travel.Price.Currency)
+	if input.Price != nil {
+		price = *input.Price
+		if price.Currency == "" {
+			price.Currency = travel.Price.Currency
+		}
+		if price.Currency != travel.Price.Currency || !price.IsPositive() {
+			return money.Money{}, fmt.Errorf("%w : prix positif en %s requis", ErrDepartureInvalid, travel.Price.Currency)
+		}
+	}
+	return price, nil
+}
+
+// CreateDeparture ajoute un départ au travel, avec autant de places que sa capacité.
+func CreateDeparture(db *gorm.DB, travelID uint, input models.DepartureInput) (models.Departure, error) {
+	var travel models.Travel
+	if err := db.First(&travel, travelID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Departure{}, ErrTravelNotFound
+		}
+		return models.Departure{}, err
+	}
+
+	now := time.Now()
+	price, err := departurePrice(travel, input, now)
+	if err != nil {
+		return models.Departure{}, err
+	}
+
+	departure := models.Departure{
+		TravelID:  travelID,
+		StartDate: input.StartDate,
+		EndDate:   input.EndDate,
+		Capacity:  input.Capacity,
+		Stock:     input.Capacity,
+		Price:     price,
+	}
+	if err := db.Create(&departure).Error; err != nil {
+		return departure, err
+	}
+	departure.Bookable = true
+	return departure, nil
+}
+
+func findDeparture(db *gorm.DB, travelID, departureID uint) (models.Departure, error) {
+	var departure models.Departure
+	if err := db.First(&departure, "id = ? AND travel_id = ?", departureID, travelID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return departure, ErrDepartureNotFound
+		}
+		return departure, err
+	}
+	return departure, nil
+}
+
+// UpdateDeparture remplace les dates, la capacité et le prix du départ. Les
+// places restantes suivent la capacité ; la mise à jour est conditionnelle pour
+// ne jamais descendre sous les places réservées entre-temps.
+func UpdateDeparture(db *gorm.DB, travelID, departureID uint, input models.DepartureInput) (models.Departure, error) {
+	var travel models.Travel
+	if err := db.First(&travel, travelID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Departure{}, ErrTravelNotFound
+		}
+		return models.Departure{}, err
+	}
+	departure, err := findDeparture(db, travelID, departureID)
+	if err != nil {
+		return departure, err
+	}
+
+	now := time.Now()
+	price, err := departurePrice(travel, input, now)
+	if err != nil {
+		return departure, err
+	}
+
+	delta := input.Capacity - departure.Capacity
+	res := db.Model(&models.Departure{}).
+		Where("id = ? AND capacity = ? AND stock + ? >= 0", departure.ID, departure.Capacity, delta).
+		Updates(map[string]interface{}{
+			"start_date":     input.StartDate,
+			"end_date":       input.EndDate,
+			"capacity":       input.Capacity,
+			"stock":          gorm.Expr("stock + ?", delta),
+			"price_minor":    price.Amount,
+			"price_currency": price.Currency,
+		})
+	if res.Error != nil {
+		return departure, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return departure, fmt.Errorf("%w : capacité inférieure aux places réservées", ErrDepartureBooked)
+	}
+
+	if departure, err = findDeparture(db, travelID, departureID); err != nil {
+		return departure, err
+	}
+	departure.Bookable = departure.StartDate.After(now) && departure.Stock > 0
+	return departure, nil
+}
+
+// DeleteDeparture supprime un départ sur lequel aucune place n'est réservée.
+func DeleteDeparture(db *gorm.DB, travelID, departureID uint) error {
+	res := db.Where("id = ? AND travel_id = ? AND stock = capacity", departureID, travelID).Delete(&models.Departure{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		if _, err := findDeparture(db, travelID, departureID); err != nil {
+			return err
+		}
+		return ErrDepartureBooked
+	}
+	return nil
+}
+
+// checkDepartures vérifie qu'une commande ne cite qu'un départ par travel : les
+// lignes d'un même travel sont regroupées, voyageurs compris.
+func checkDepartures(items []models.OrderItemInput) error {
+	departures := make(map[uint]*uint, len(items))
+	for _, item := range items {
+		previous, ok := departures[item.TravelID]
+		if ok && !sameDeparture(previous, item.DepartureID) {
+			return fmt.Errorf("%w : un seul départ par travel dans une commande (travel %d)", ErrDepartureInvalid, item.TravelID)
+		}
+		departures[item.TravelID] = item.DepartureID
+	}
+	return nil
+}
+
+func sameDeparture(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// reserveDeparture décrémente le stock du départ de façon conditionnelle (départ
+// à venir, places suffisantes) et retourne le départ relu.
+func reserveDeparture(tx *gorm.DB, item models.OrderItemInput, now time.Time) (models.Departure, error) {
+	res := tx.Model(&models.Departure{}).
+		Where("id = ? AND travel_id = ? AND stock >= ? AND start_date > ?", *item.DepartureID, item.TravelID, item.Quantity, now).
+		UpdateColumn("stock", gorm.Expr("stock - ?", item.Quantity))
+	if res.Error != nil {
+		return models.Departure{}, res.Error
+	}
+
+	departure, err := findDeparture(tx, item.TravelID, *item.DepartureID)
+	if err != nil {
+		return departure, fmt.Errorf("%w (travel %d, départ %d)", err, item.TravelID, *item.DepartureID)
+	}
+	if res.RowsAffected == 0 {
+		return departure, fmt.Errorf("%w (travel %d, départ %d)", ErrTravelUnavailable, item.TravelID, departure.ID)
+	}
+	return departure, nil
+}
+
+// departureDates retourne la date de départ (éventuellement inconnue) du travel
+// de chaque ligne : celle du départ réservé, à défaut celle du travel.
+func departureDates(tx *gorm.DB, items []models.OrderItem) (map[uint]*time.Time, error) {
+	travelIDs := make([]uint, 0, len(items))
+	var departureIDs []uint
+	for _, item := range items {
+		travelIDs = append(travelIDs, item.TravelID)
+		if item.DepartureID != nil {
+			departureIDs = append(departureIDs, *item.DepartureID)
+		}
+	}
+
+	dates := make(map[uint]*time.Time, len(items))
+	if len(travelIDs) == 0 {
+		return dates, nil
+	}
+	var travels []models.Travel
+	if err := tx.Unscoped().Select("id", "departure_date").Where("id IN ?", travelIDs).Find(&travels).Error; err != nil {
+		return nil, err
+	}
+	for _, travel := range travels {
+		dates[travel.ID] = travel.DepartureDate
+	}
+
+	if len(departureIDs) == 0 {
+		return dates, nil
+	}
+	var departures []models.Departure
+	if err := tx.Unscoped().Select("id", "travel_id", "start_date").Where("id IN ?", departureIDs).Find(&departures).Error; err != nil {
+		return nil, err
+	}
+	for i := range departures {
+		dates[departures[i].TravelID] = &departures[i].StartDate
+	}
+	return dates, nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"errors"
+	"h3-travel/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrRateUnavailable = errors.New("taux de change indisponible")
+
+// SetExchangeRate crée ou remplace le taux base -> quote.
+func SetExchangeRate(db *gorm.DB, base, quote string, rate float64) (models.ExchangeRate, error) {
+	exchangeRate := models.ExchangeRate{Base: base, Quote: quote, Rate: rate, UpdatedAt: time.Now()}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "base"}, {Name: "quote"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"}),
+	}).Create(&exchangeRate).Error
+	return exchangeRate, err
+}
+
+func ListExchangeRates(db *gorm.DB) ([]models.ExchangeRate, error) {
+	var rates []models.ExchangeRate
+	err := db.Order("base").Order("quote").Find(&rates).Error
+	return rates, err
+}
+
+// FindExchangeRate retourne le taux base -> quote, saisi tel quel ou déduit du taux inverse.
+func FindExchangeRate(db *gorm.DB, base, quote string) (models.ExchangeRate, error) {
+	if base == quote {
+		return models.ExchangeRate{Base: base, Quote: quote, Rate: 1}, nil
+	}
+
+	var rates []models.ExchangeRate
+	if err := db.Where("(base = ? AND quote = ?) OR (base = ? AND quote = ?)", base, quote, quote, base).
+		Find(&rates).Error; err != nil {
+		return models.ExchangeRate{}, err
+	}
+
+	for _, rate := range rates {
+		if rate.Base == base {
+			return rate, nil
+		}
+	}
+	for _, rate := range rates {
+		if rate.Rate > 0 {
+			return models.ExchangeRate{Base: base, Quote: quote, Rate: 1 / rate.Rate, UpdatedAt: rate.UpdatedAt}, nil
+		}
+	}
+	return models.ExchangeRate{}, ErrRateUnavailable
+}
+
+// ConvertTravelPrices renseigne le prix d'affichage de chaque travel dans la devise demandée.
+func ConvertTravelPrices(db *gorm.DB, travels []models.Travel, currency string) error {
+	rates := make(map[string]models.ExchangeRate)
+	for i := range travels {
+		base := travels[i].Price.Currency
+		rate, ok := rates[base]
+		if !ok {
+			var err error
+			if rate, err = FindExchangeRate(db, base, currency); err != nil {
+				return err
+			}
+			rates[base] = rate
+		}
+
+		price := travels[i].Price.Convert(currency, rate.Rate)
+		travels[i].DisplayPrice = &price
+		travels[i].DisplayRate = &rate
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"h3-travel/models"
+	"h3-travel/money"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// priceBucketBounds : bornes des tranches de prix, en unités de la devise
+var priceBucketBounds = []int64{500, 1000, 2000, 5000}
+
+// destinations : pays de destination de chaque travel, celui de l'hôtel pour un
+// hôtel, ceux de ses hôtels pour un séjour packagé (les vols n'en ont pas).
+func destinations(db *gorm.DB) *gorm.DB {
+	return db.Table("(?) AS destinations", gorm.Expr(
+		"SELECT travel_id, country FROM hotel_details "+
+			"UNION ALL SELECT pc.package_id, h.country FROM package_components pc JOIN hotel_details h ON h.travel_id = pc.travel_id"))
+}
+
+// priceBucketExpr : indice de la tranche de prix d'un travel, NULL s'il est dans une autre devise
+func priceBucketExpr(currency string) (string, []interface{}) {
+	unit := int64(math.Pow10(money.Exponent(currency)))
+	sql := "CASE WHEN price_currency <> ? THEN NULL"
+	vars := []interface{}{currency}
+	for i, bound := range priceBucketBounds {
+		sql += fmt.Sprintf(" WHEN price_minor < ? THEN %d", i)
+		vars = append(vars, bound*unit)
+	}
+	return sql + fmt.Sprintf(" ELSE %d END", len(priceBucketBounds)), vars
+}
+
+// facetRow : nombre de travels par type, tranche de prix et disponibilité ;
+// PriceOK indique s'ils passent le filtre de prix.
+type facetRow struct {
+	Type        models.TravelType
+	PriceBucket *int
+	Available   int
+	PriceOK     int
+	Count       int64
+}
+
+// computeTravelFacets compte les travels du filtre par facette, en deux
+// requêtes : une agrégation par type, tranche de prix et disponibilité, puis
+// les pays de destination. Les comptes d'une facette ignorent son propre critère.
+func computeTravelFacets(db *gorm.DB, filter models.TravelFilter, now time.Time) (models.TravelFacets, error) {
+	var facets models.TravelFacets
+	currency, err := priceCurrency(filter)
+	if err != nil {
+		return facets, err
+	}
+
+	bucket, vars := priceBucketExpr(currency)
+	vars = append(vars, now) // inStockExpr
+	priceOK := "1"
+	if filter.MinPrice != nil || filter.MaxPrice != nil {
+		priceOK = "CASE WHEN price_currency = ?"
+		vars = append(vars, currency)
+		if filter.MinPrice != nil {
+			priceOK += " AND price_minor >= ?"
+			vars = append(vars, *filter.MinPrice)
+		}
+		if filter.MaxPrice != nil {
+			priceOK += " AND price_minor <= ?"
+			vars = append(vars, *filter.MaxPrice)
+		}
+		priceOK += " THEN 1 ELSE 0 END"
+	}
+
+	// Le pays n'est pas une colonne de l'agrégation : son critère s'applique ici
+	only := models.TravelFilter{Country: filter.Country}
+	var rows []facetRow
+	err = facetFilters(db, catalogQuery(db, filter), only, currency, "", now).
+		Select("type, "+bucket+" AS price_bucket, CASE WHEN "+inStockExpr+" THEN 1 ELSE 0 END AS available, "+priceOK+" AS price_ok, COUNT(*) AS count", vars...).
+		Group("type, price_bucket, available, price_ok").
+		Scan(&rows).Error
+	if err != nil {
+		return facets, err
+	}
+
+	types := map[models.TravelType]int64{}
+	buckets := make([]int64, len(priceBucketBounds)+1)
+	availability := map[bool]int64{}
+	for _, row := range rows {
+		typeOK := filter.Type == "" || row.Type == filter.Type
+		stockOK := !filter.InStock || row.Available == 1
+		inPrice := row.PriceOK == 1
+		if inPrice && stockOK {
+			types[row.Type] += row.Count
+		}
+		if typeOK && stockOK && row.PriceBucket != nil {
+			buckets[*row.PriceBucket] += row.Count
+		}
+		if typeOK && inPrice {
+			availability[row.Available == 1] += row.Count
+		}
+		if typeOK && inPrice && stockOK {
+			facets.Total += row.Count
+		}
+	}
+
+	for _, t := range []models.TravelType{models.TravelFlight, models.TravelHotel, models.TravelPackage} {
+		facets.Types = append(facets.Types, models.FacetCount{Value: string(t), Count: types[t]})
+	}
+	unit := int64(math.Pow10(money.Exponent(currency)))
+	for i, count := range buckets {
+		facet := models.PriceFacet{Count: count, Min: money.Zero(currency)}
+		if i > 0 {
+			facet.Min = money.New(priceBucketBounds[i-1]*unit, currency)
+		}
+		if i < len(priceBucketBounds) {
+			max := money.New(priceBucketBounds[i]*unit, currency)
+			facet.Max = &max
+		}
+		facets.Prices = append(facets.Prices, facet)
+	}
+	facets.Availability = []models.FacetCount{
+		{Value: models.FacetAvailable, Count: availability[true]},
+		{Value: models.FacetSoldOut, Count: availability[false]},
+	}
+
+	travels := facetFilters(db, catalogQuery(db, filter), filter, currency, facetCountry, now).Select("travels.id")
+	facets.Countries = []models.FacetCount{}
+	err = destinations(db).
+		Select("country AS value, COUNT(DISTINCT travel_id) AS count").
+		Where("travel_id IN (?)", travels).
+		Group("country").
+		Order("COUNT(DISTINCT travel_id) DESC").Order("country").
+		Scan(&facets.Countries).Error
+	return facets, err
+}
+
+type cachedFacets struct {
+	facets  models.TravelFacets
+	expires time.Time
+}
+
+// travelFacetsCacheSize : nombre maximal de filtres dont les facettes sont gardées
+const travelFacetsCacheSize = 256
+
+// travelFacetsCache garde brièvement les facettes calculées, par filtre.
+var travelFacetsCache = struct {
+	sync.Mutex
+	entries map[string]cachedFacets
+}{entries: map[string]cachedFacets{}}
+
+// facetsCacheKey : les critères du filtre, sans tri ni pagination
+func facetsCacheKey(filter models.TravelFilter) string {
+	filter.Q = strings.TrimSpace(filter.Q)
+	filter.Country = strings.ToUpper(filter.Country)
+	filter.Sort, filter.Page, filter.PageSize = "", 0, 0
+	key, _ := json.Marshal(filter)
+	return string(key)
+}
+
+// GetTravelFacets retourne les facettes du catalogue pour le filtre, calculées
+// au plus ttl auparavant. Une recherche en texte libre n'est pas mise en cache :
+// chaque saisie ferait une nouvelle entrée. Le cache plein, l'entrée la plus
+// proche de l'expiration laisse sa place.
+func GetTravelFacets(db *gorm.DB, filter models.TravelFilter, ttl time.Duration, now time.Time) (models.TravelFacets, error) {
+	if strings.TrimSpace(filter.Q) != "" {
+		return computeTravelFacets(db, filter, now)
+	}
+
+	key := facetsCacheKey(filter)
+	travelFacetsCache.Lock()
+	cached, ok := travelFacetsCache.entries[key]
+	travelFacetsCache.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.facets, nil
+	}
+
+	facets, err := computeTravelFacets(db, filter, now)
+	if err != nil {
+		return facets, err
+	}
+
+	travelFacetsCache.Lock()
+	defer travelFacetsCache.Unlock()
+	for k, entry := range travelFacetsCache.entries {
+		if !now.Before(entry.expires) {
+			delete(travelFacetsCache.entries, k)
+		}
+	}
+	if _, ok := travelFacetsCache.entries[key]; !ok && len(travelFacetsCache.entries) >= travelFacetsCacheSize {
+		evictSoonestFacets()
+	}
+	travelFacetsCache.entries[key] = cachedFacets{facets: facets, expires: now.Add(ttl)}
+	return facets, nil
+}
+
+// evictSoonestFacets retire l'entrée du cache qui expire la première ;
+// l'appelant détient le verrou.
+func evictSoonestFacets() {
+	var soonest string
+	var expires time.Time
+	for k, entry := range travelFacetsCache.entries {
+		if expires.IsZero() || entry.expires.Before(expires) {
+			soonest, expires = k, entry.expires
+		}
+	}
+	delete(travelFacetsCache.entries, soonest)
+}
+
+// ClearTravelFacets vide le cache des facettes, après une modification du
+// catalogue ou de ses places disponibles (commande, annulation, expiration).
+func ClearTravelFacets() {
+	travelFacetsCache.Lock()
+	defer travelFacetsCache.Unlock()
+	travelFacetsCache.entries = map[string]cachedFacets{}
+}
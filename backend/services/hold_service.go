@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"h3-travel/models"
+	"h3-travel/money"
+	"h3-travel/payment"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const holdSweepBatch = 100
+
+var ErrHoldExpired = errors.New("réservation expirée")
+
+// ConfirmPayment fait autoriser puis capturer le montant de la réservation (hors
+// part déjà prélevée sur le porte-monnaie) par le prestataire de paiement avec
+// la carte du coffre désignée par cardToken, puis passe la commande à l'état
+// payé et émet sa facture dans une transaction. La capture a lieu hors de la
+// transaction : si celle-ci échoue, le montant capturé est remboursé.
+func ConfirmPayment(ctx context.Context, db *gorm.DB, provider payment.Provider, vaultKey []byte, userID, orderID uint, cardToken string) (models.Order, error) {
+	var order models.Order
+	if err := db.First(&order, "id = ? AND user_id = ?", orderID, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return order, ErrOrderNotFound
+		}
+		return order, err
+	}
+
+	if !order.Statut.CanTransitionTo(models.StatusPaid) {
+		return order, &models.TransitionError{From: order.Statut, To: models.StatusPaid}
+	}
+	if order.ExpiresAt != nil && order.ExpiresAt.Before(time.Now()) {
+		return order, ErrHoldExpired
+	}
+
+	due, err := order.Total.CheckedSub(order.WalletAmount)
+	if err != nil {
+		return order, err
+	}
+	amount := due.Amount
+
+	// Rien à régler par carte : le prestataire refuserait une autorisation nulle
+	var stored models.CardToken
+	var transactionID string
+	if amount > 0 {
+		var pan string
+		if stored, pan, err = resolveCardToken(db, vaultKey, userID, cardToken); err != nil {
+			return order, err
+		}
+		if transactionID, err = provider.Authorize(ctx, payment.Request{Amount: amount, Currency: order.Total.Currency, Card: pan}); err != nil {
+			return order, err
+		}
+		if err := provider.Capture(ctx, transactionID, amount); err != nil {
+			if voidErr := provider.Void(ctx, transactionID); voidErr != nil {
+				log.Printf("Annulation de l'autorisation %s impossible: %v", transactionID, voidErr)
+			}
+			return order, err
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := TransitionOrder(tx, &order, models.StatusPaid, models.ActorUser(userID)); err != nil {
+			return err
+		}
+
+		if amount > 0 {
+			order.PaymentProvider = provider.Name()
+		}
+		order.PaymentTransactionID = transactionID
+		order.CardBrand = stored.Brand
+		order.CardLast4 = stored.Last4
+		order.PaidAmount = order.Total
+		if err := tx.Model(&order).Updates(map[string]interface{}{
+			"payment_provider":       order.PaymentProvider,
+			"payment_transaction_id": order.PaymentTransactionID,
+			"card_brand":             order.CardBrand,
+			"card_last4":             order.CardLast4,
+			"paid_minor":             order.PaidAmount.Amount,
+			"paid_currency":          order.PaidAmount.Currency,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := awardLoyaltyPoints(tx, order, time.Now()); err != nil {
+			return err
+		}
+
+		if err := settleWaitlistOffer(tx, order.ID, models.WaitlistClaimed); err != nil {
+			return err
+		}
+
+		if amount > 0 {
+			if err := tx.Create(&models.Payment{
+				OrderID:       order.ID,
+				Provider:      provider.Name(),
+				TransactionID: transactionID,
+				Amount:        money.New(amount, order.Total.Currency),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		// Après la carte : un remboursement partiel revient d'abord sur la carte
+		if err := recordWalletPayment(tx, &order); err != nil {
+			return err
+		}
+		// En dernier : la série de numérotation reste verrouillée jusqu'au commit
+		return issueInvoice(tx, &order, time.Now())
+	})
+	if err != nil {
+		// Déjà capturé : le client est remboursé, la commande reste à payer
+		if amount > 0 {
+			if _, refundErr := provider.Refund(ctx, transactionID, amount); refundErr != nil {
+				log.Printf("Remboursement de la capture %s impossible: %v", transactionID, refundErr)
+			}
+		}
+		return order, err
+	}
+
+	return loadOrderDetails(db, order.ID)
+}
+
+// ExpireHolds passe en "expired" les réservations dont le délai est dépassé et
+// remet leurs places en stock. Chaque réservation est traitée dans sa propre
+// transaction et la transition est conditionnelle : si plusieurs instances de
+// l'API balaient en même temps, une seule restocke une réservation donnée. Les
+// places libérées sont proposées à la liste d'attente pendant offerTTL.
+func ExpireHolds(ctx context.Context, db *gorm.DB, now time.Time, offerTTL time.Duration) (int, error) {
+	var ids []uint
+	if err := db.WithContext(ctx).Model(&models.Order{}).
+		Where("statut = ? AND expires_at < ?", models.StatusPendingPayment, now).
+		Order("expires_at").
+		Limit(holdSweepBatch).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		var order models.Order
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Preload("Items").First(&order, id).Error; err != nil {
+				return err
+			}
+
+			if err := TransitionOrder(tx, &order, models.StatusExpired, models.ActorSystem); err != nil {
+				return err
+			}
+
+			if err := settleWaitlistOffer(tx, order.ID, models.WaitlistExpired); err != nil {
+				return err
+			}
+			if err := releasePromo(tx, order.ID); err != nil {
+				return err
+			}
+			if err := releaseWallet(tx, order); err != nil {
+				return err
+			}
+			if err := revokeLoyaltyPoints(tx, order, now); err != nil {
+				return err
+			}
+			return restockItems(tx, order.Items)
+		})
+
+		switch {
+		case err == nil:
+			expired++
+			ClearTravelFacets()
+			// L'offre passe à la personne suivante de la liste d'attente
+			promoteWaitlists(ctx, db, order.Items, offerTTL)
+		case errors.Is(err, models.ErrInvalidTransition), errors.Is(err, gorm.ErrRecordNotFound):
+			// déjà payée, annulée ou expirée par une autre instance
+		default:
+			return expired, err
+		}
+	}
+
+	return expired, nil
+}
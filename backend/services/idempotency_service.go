@@ -0,0 +1,14 @@
+package services
+
+import (
+	"context"
+	"h3-travel/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PurgeIdempotencyKeys supprime les clés d'idempotence enregistrées avant la date donnée.
+func PurgeIdempotencyKeys(ctx context.Context, db *gorm.DB, before time.Time) error {
+	return db.WithContext(ctx).Where("created_at < ?", before).Delete(&models.IdempotencyKey{}).Error
+}
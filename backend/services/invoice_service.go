@@ -0,0 +1,310 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"h3-travel/config"
+	"h3-travel/models"
+	"h3-travel/money"
+	"h3-travel/pdf"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvoiceNotFound = errors.New("facture non trouvée")
+
+// nextInvoiceNumber attribue le numéro suivant de la série du type et de l'année.
+// Le compteur est incrémenté dans la transaction de l'appelant : la ligne de la
+// série reste verrouillée jusqu'à sa fin et un rollback rend le numéro, d'où
+// une numérotation continue, sans trou ni doublon. Comme tous les paiements
+// attendent ce verrou, la facture est la dernière écriture de la transaction.
+func nextInvoiceNumber(tx *gorm.DB, kind models.InvoiceKind, at time.Time) (string, error) {
+	series := "FA"
+	if kind == models.KindCreditNote {
+		series = "AV"
+	}
+	prefix := fmt.Sprintf("%s-%d", series, at.Year())
+
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.InvoiceSequence{Prefix: prefix}).Error; err != nil {
+		return "", err
+	}
+	if err := tx.Model(&models.InvoiceSequence{}).Where("prefix = ?", prefix).
+		UpdateColumn("counter", gorm.Expr("counter + 1")).Error; err != nil {
+		return "", err
+	}
+
+	var counter int64
+	if err := tx.Model(&models.InvoiceSequence{}).Where("prefix = ?", prefix).
+		Pluck("counter", &counter).Error; err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%06d", prefix, counter), nil
+}
+
+// issueInvoice émet la facture de la commande qui vient d'être payée.
+func issueInvoice(tx *gorm.DB, order *models.Order, at time.Time) error {
+	number, err := nextInvoiceNumber(tx, models.KindInvoice, at)
+	if err != nil {
+		return err
+	}
+
+	invoice := models.Invoice{
+		Number:   number,
+		Kind:     models.KindInvoice,
+		OrderID:  order.ID,
+		Net:      order.Total.Sub(order.Tax),
+		Tax:      order.Tax,
+		Gross:    order.Total,
+		IssuedAt: at,
+	}
+	if err := tx.Create(&invoice).Error; err != nil {
+		return err
+	}
+	order.Invoices = append(order.Invoices, invoice)
+	return nil
+}
+
+// issueCreditNote émet l'avoir d'un remboursement. La TVA remboursée est
+// proportionnelle au montant ; l'avoir qui solde la commande reprend le reste
+// de la TVA facturée, pour que facture et avoirs s'annulent exactement.
+// order.RefundedAmount doit avoir été relu en base après la réservation du
+// montant (issueRefund). Une commande payée avant l'introduction des factures
+// n'a pas d'avoir.
+func issueCreditNote(tx *gorm.DB, order *models.Order, amount money.Money, reason string, at time.Time) error {
+	var invoice models.Invoice
+	if err := tx.Where("order_id = ? AND kind = ?", order.ID, models.KindInvoice).
+		Limit(1).Find(&invoice).Error; err != nil || invoice.ID == 0 {
+		return err
+	}
+
+	tax := invoice.Tax.Prorate(amount.Amount, invoice.Gross.Amount)
+	if order.RefundedAmount.Amount >= invoice.Gross.Amount {
+		var credited int64
+		if err := tx.Model(&models.Invoice{}).
+			Where("order_id = ? AND kind = ?", order.ID, models.KindCreditNote).
+			Select("COALESCE(SUM(tax_minor), 0)").Scan(&credited).Error; err != nil {
+			return err
+		}
+		tax = invoice.Tax.Sub(money.New(credited, invoice.Tax.Currency))
+	}
+
+	number, err := nextInvoiceNumber(tx, models.KindCreditNote, at)
+	if err != nil {
+		return err
+	}
+	creditNote := models.Invoice{
+		Number:            number,
+		Kind:              models.KindCreditNote,
+		OrderID:           order.ID,
+		CreditedInvoiceID: &invoice.ID,
+		Reason:            reason,
+		Net:               amount.Sub(tax),
+		Tax:               tax,
+		Gross:             amount,
+		IssuedAt:          at,
+	}
+	if err := tx.Create(&creditNote).Error; err != nil {
+		return err
+	}
+	order.Invoices = append(order.Invoices, creditNote)
+	return nil
+}
+
+// InvoicePDF génère le PDF de la facture (number vide) ou d'un avoir d'une
+// commande de l'utilisateur userID (0 : n'importe quel utilisateur).
+func InvoicePDF(db *gorm.DB, issuer config.InvoiceIssuer, userID, orderID uint, number string) ([]byte, models.Invoice, error) {
+	var order models.Order
+	query := db.Preload("Items")
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.First(&order, orderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.Invoice{}, ErrOrderNotFound
+		}
+		return nil, models.Invoice{}, err
+	}
+
+	var invoices []models.Invoice
+	if err := db.Where("order_id = ?", order.ID).Order("id").Find(&invoices).Error; err != nil {
+		return nil, models.Invoice{}, err
+	}
+
+	var invoice, document *models.Invoice
+	for i := range invoices {
+		if invoices[i].Kind == models.KindInvoice {
+			invoice = &invoices[i]
+		}
+		if (number == "" && invoices[i].Kind == models.KindInvoice) || (number != "" && invoices[i].Number == number) {
+			document = &invoices[i]
+		}
+	}
+	if document == nil || invoice == nil {
+		return nil, models.Invoice{}, ErrInvoiceNotFound
+	}
+
+	var customer models.User
+	if err := db.Unscoped().First(&customer, order.UserID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, models.Invoice{}, err
+	}
+
+	return renderInvoice(issuer, *document, *invoice, order, customer), *document, nil
+}
+
+// scaleBreakdown répartit les montants d'un avoir entre les taux de la facture,
+// au prorata ; la dernière ligne reçoit le reste des arrondis.
+func scaleBreakdown(breakdown []models.TaxBreakdown, invoice, document models.Invoice) []models.TaxBreakdown {
+	if document.Kind == models.KindInvoice {
+		return breakdown
+	}
+
+	scaled := make([]models.TaxBreakdown, len(breakdown))
+	gross, tax := document.Gross, document.Tax
+	for i, line := range breakdown {
+		if i == len(breakdown)-1 {
+			scaled[i] = models.TaxBreakdown{Rate: line.Rate, Gross: gross, Tax: tax, Net: gross.Sub(tax)}
+			break
+		}
+		lineGross := line.Gross.Prorate(document.Gross.Amount, invoice.Gross.Amount)
+		lineTax := line.Tax.Prorate(document.Tax.Amount, invoice.Tax.Amount)
+		scaled[i] = models.TaxBreakdown{Rate: line.Rate, Gross: lineGross, Tax: lineTax, Net: lineGross.Sub(lineTax)}
+		gross, tax = gross.Sub(lineGross), tax.Sub(lineTax)
+	}
+	return scaled
+}
+
+// formatRate : 550 -> "5,5 %"
+func formatRate(basisPoints int) string {
+	rate := fmt.Sprintf("%d,%02d", basisPoints/100, basisPoints%100)
+	rate = strings.TrimSuffix(strings.TrimSuffix(rate, "0"), ",0")
+	return rate + " %"
+}
+
+func renderInvoice(issuer config.InvoiceIssuer, document, invoice models.Invoice, order models.Order, customer models.User) []byte {
+	doc := pdf.New()
+	doc.AddPage()
+
+	title := "FACTURE"
+	if document.Kind == models.KindCreditNote {
+		title = "AVOIR"
+	}
+	doc.Text(40, 60, 20, true, title)
+	doc.Text(40, 80, 10, false, "N° "+document.Number)
+	doc.Text(40, 94, 10, false, "Date : "+document.IssuedAt.Format("02/01/2006"))
+	doc.Text(40, 108, 10, false, fmt.Sprintf("Commande n° %d", order.ID))
+
+	doc.Text(40, 140, 10, true, issuer.Name)
+	y := 154.0
+	for _, line := range strings.Split(issuer.Address, "\n") {
+		if line != "" {
+			doc.Text(40, y, 9, false, line)
+			y += 12
+		}
+	}
+
+	doc.Text(340, 140, 10, true, "Client")
+	doc.Text(340, 154, 9, false, customer.Email)
+	doc.Text(340, 166, 9, false, fmt.Sprintf("Compte n° %d", order.UserID))
+
+	y = 220
+	if document.Kind == models.KindCreditNote {
+		doc.Text(40, y, 10, false, "Avoir sur la facture "+invoice.Number+" du "+invoice.IssuedAt.Format("02/01/2006"))
+		y += 14
+		if document.Reason != "" {
+			doc.Text(40, y, 10, false, "Motif : "+document.Reason)
+			y += 14
+		}
+		y += 10
+		doc.Line(40, y, 555, y)
+		doc.Text(40, y+14, 9, true, "Désignation")
+		doc.Text(470, y+14, 9, true, "Montant TTC")
+		doc.Line(40, y+20, 555, y+20)
+		y += 34
+		doc.Text(40, y, 9, false, "Remboursement")
+		doc.Text(470, y, 9, false, document.Gross.String())
+		y += 20
+	} else {
+		doc.Line(40, y, 555, y)
+		doc.Text(40, y+14, 9, true, "Désignation")
+		doc.Text(250, y+14, 9, true, "Qté")
+		doc.Text(285, y+14, 9, true, "PU TTC")
+		doc.Text(355, y+14, 9, true, "Remise")
+		doc.Text(420, y+14, 9, true, "TVA")
+		doc.Text(470, y+14, 9, true, "Total TTC")
+		doc.Line(40, y+20, 555, y+20)
+		y += 34
+		for _, item := range order.Items {
+			if y > 720 {
+				doc.AddPage()
+				y = 60
+			}
+			doc.Text(40, y, 9, false, item.Title)
+			doc.Text(250, y, 9, false, fmt.Sprintf("%d", item.Quantity))
+			doc.Text(285, y, 9, false, item.UnitPrice.String())
+			if item.Discount.IsPositive() {
+				doc.Text(355, y, 9, false, "-"+item.Discount.String())
+			}
+			doc.Text(420, y, 9, false, formatRate(item.TaxRate))
+			doc.Text(470, y, 9, false, item.LineTotal().String())
+			y += 14
+		}
+		if order.PromoCode != "" {
+			doc.Text(40, y, 9, false, "Code promo "+order.PromoCode+" : -"+order.Discount.String())
+			y += 14
+		}
+		if order.LoyaltyPoints > 0 {
+			doc.Text(40, y, 9, false, fmt.Sprintf("%d points fidélité : -%s", order.LoyaltyPoints, order.LoyaltyDiscount))
+			y += 14
+		}
+		y += 6
+	}
+
+	if y > 640 {
+		doc.AddPage()
+		y = 60
+	}
+	doc.Line(40, y, 555, y)
+	doc.Text(40, y+14, 9, true, "Taux de TVA")
+	doc.Text(200, y+14, 9, true, "Base HT")
+	doc.Text(330, y+14, 9, true, "TVA")
+	doc.Text(470, y+14, 9, true, "TTC")
+	doc.Line(40, y+20, 555, y+20)
+	y += 34
+	for _, line := range scaleBreakdown(taxBreakdown(order), invoice, document) {
+		doc.Text(40, y, 9, false, formatRate(line.Rate))
+		doc.Text(200, y, 9, false, line.Net.String())
+		doc.Text(330, y, 9, false, line.Tax.String())
+		doc.Text(470, y, 9, false, line.Gross.String())
+		y += 14
+	}
+
+	y += 10
+	doc.Text(330, y, 10, false, "Total HT")
+	doc.Text(470, y, 10, false, document.Net.String())
+	doc.Text(330, y+14, 10, false, "TVA")
+	doc.Text(470, y+14, 10, false, document.Tax.String())
+	doc.Text(330, y+28, 10, true, "Total TTC")
+	doc.Text(470, y+28, 10, true, document.Gross.String())
+	y += 56
+
+	if document.Kind == models.KindInvoice && order.CardLast4 != "" {
+		doc.Text(40, y, 9, false, fmt.Sprintf("Payée par carte %s se terminant par %s.", order.CardBrand, order.CardLast4))
+	}
+
+	var mentions []string
+	if issuer.SIRET != "" {
+		mentions = append(mentions, "SIRET "+issuer.SIRET)
+	}
+	if issuer.VATNumber != "" {
+		mentions = append(mentions, "TVA intracommunautaire "+issuer.VATNumber)
+	}
+	if len(mentions) > 0 {
+		doc.Text(40, 800, 8, false, issuer.Name+" - "+strings.Join(mentions, " - "))
+	}
+
+	return doc.Bytes()
+}
@@ -0,0 +1,395 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"h3-travel/models"
+	"h3-travel/money"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrLoyaltyInsufficient = errors.New("solde de points de fidélité insuffisant")
+	ErrLoyaltyUnavailable  = errors.New("les points de fidélité ne peuvent pas être utilisés sur cette commande")
+)
+
+const (
+	loyaltyProgramID    = 1
+	loyaltyHistoryLimit = 50
+	loyaltyExpiryBatch  = 100
+)
+
+// LoadLoyaltyProgram retourne le programme configuré par un admin, à défaut le
+// programme par défaut. Les niveaux sont triés par seuil croissant.
+func LoadLoyaltyProgram(db *gorm.DB) (models.LoyaltyProgram, error) {
+	var program models.LoyaltyProgram
+	if err := db.Limit(1).Find(&program, loyaltyProgramID).Error; err != nil {
+		return program, err
+	}
+	if program.ID == 0 {
+		program = models.DefaultLoyaltyProgram
+		program.Tiers = append([]models.LoyaltyTier(nil), models.DefaultLoyaltyProgram.Tiers...)
+		return program, nil
+	}
+
+	err := db.Order("min_yearly_spend_minor").Find(&program.Tiers).Error
+	return program, err
+}
+
+// SaveLoyaltyProgram remplace les paramètres et les niveaux du programme. Les
+// points déjà acquis gardent leur date d'expiration.
+func SaveLoyaltyProgram(db *gorm.DB, input models.LoyaltyProgramInput) (models.LoyaltyProgram, error) {
+	program := models.LoyaltyProgram{
+		ID:             loyaltyProgramID,
+		EarnRate:       input.EarnRate,
+		PointValue:     money.New(input.PointValue, money.DefaultCurrency),
+		MaxBurnPercent: input.MaxBurnPercent,
+		ExpiryMonths:   input.ExpiryMonths,
+		UpdatedAt:      time.Now(),
+	}
+	for _, tier := range input.Tiers {
+		program.Tiers = append(program.Tiers, models.LoyaltyTier{
+			Name:           tier.Name,
+			MinYearlySpend: money.New(tier.MinYearlySpend, money.DefaultCurrency),
+			BonusPercent:   tier.BonusPercent,
+		})
+	}
+	sort.SliceStable(program.Tiers, func(i, j int) bool {
+		return program.Tiers[i].MinYearlySpend.Amount < program.Tiers[j].MinYearlySpend.Amount
+	})
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&program).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("1 = 1").Delete(&models.LoyaltyTier{}).Error; err != nil {
+			return err
+		}
+		if len(program.Tiers) == 0 {
+			return nil
+		}
+		return tx.Create(&program.Tiers).Error
+	})
+	return program, err
+}
+
+// tierFor retourne le niveau atteint avec la dépense donnée et le suivant (nil au-delà).
+func tierFor(tiers []models.LoyaltyTier, spend money.Money) (current, next *models.LoyaltyTier) {
+	for i := range tiers {
+		if spend.Amount >= tiers[i].MinYearlySpend.Amount {
+			current = &tiers[i]
+		} else {
+			return current, &tiers[i]
+		}
+	}
+	return current, nil
+}
+
+// yearlySpend : dépense des 12 derniers mois comptée pour le niveau (gains moins reprises)
+func yearlySpend(db *gorm.DB, userID uint, currency string, now time.Time) (money.Money, error) {
+	var total int64
+	err := db.Model(&models.LoyaltyTransaction{}).
+		Where("user_id = ? AND created_at > ?", userID, now.AddDate(-1, 0, 0)).
+		Select("COALESCE(SUM(spend_minor), 0)").Scan(&total).Error
+	return money.New(total, currency), err
+}
+
+func loyaltyBalance(db *gorm.DB, userID uint) (int, error) {
+	var points int
+	err := db.Model(&models.LoyaltyAccount{}).Where("user_id = ?", userID).Pluck("points", &points).Error
+	return points, err
+}
+
+func pointsExpiry(program models.LoyaltyProgram, now time.Time) *time.Time {
+	if program.ExpiryMonths == 0 {
+		return nil
+	}
+	expiresAt := now.AddDate(0, program.ExpiryMonths, 0)
+	return &expiresAt
+}
+
+// creditLoyaltyPoints ajoute (ou retire, si entry.Points est négatif, sans
+// condition de solde) des points au compte, créé au besoin, et inscrit l'écriture.
+func creditLoyaltyPoints(tx *gorm.DB, userID uint, entry models.LoyaltyTransaction) error {
+	if err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"points":     gorm.Expr("loyalty_accounts.points + ?", entry.Points),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&models.LoyaltyAccount{UserID: userID, Points: entry.Points}).Error; err != nil {
+		return err
+	}
+	entry.UserID = userID
+	return tx.Create(&entry).Error
+}
+
+// applyLoyaltyDiscount convertit les points demandés en remise sur la commande
+// en cours de création, dans la limite de la part du total autorisée par le
+// programme, et la répartit sur les lignes au prorata. Le solde n'est débité
+// qu'une fois la commande créée (spendLoyaltyPoints).
+func applyLoyaltyDiscount(tx *gorm.DB, order *models.Order, requested int) error {
+	program, err := LoadLoyaltyProgram(tx)
+	if err != nil {
+		return err
+	}
+	balance, err := loyaltyBalance(tx, order.UserID)
+	if err != nil {
+		return err
+	}
+	if requested > balance {
+		return ErrLoyaltyInsufficient
+	}
+
+	rate, err := FindExchangeRate(tx, program.PointValue.Currency, order.Total.Currency)
+	if err != nil {
+		if errors.Is(err, ErrRateUnavailable) {
+			return fmt.Errorf("%w : %v", ErrLoyaltyUnavailable, err)
+		}
+		return err
+	}
+	value := program.PointValue.Convert(order.Total.Currency, rate.Rate)
+	if !value.IsPositive() || program.MaxBurnPercent == 0 {
+		return ErrLoyaltyUnavailable
+	}
+
+	points := requested
+	if limit := order.Total.Percent(program.MaxBurnPercent).Amount / value.Amount; int64(points) > limit {
+		points = int(limit)
+	}
+	if points == 0 {
+		return nil
+	}
+
+	discount := value.Mul(points)
+	remaining, total := discount, order.Total
+	for i := range order.Items {
+		item := &order.Items[i]
+		share := discount.Prorate(item.LineTotal().Amount, total.Amount)
+		if i == len(order.Items)-1 {
+			share = remaining
+		}
+		if item.Discount, err = item.Discount.CheckedAdd(share); err != nil {
+			return err
+		}
+		remaining = remaining.Sub(share)
+	}
+
+	order.LoyaltyPoints = points
+	order.LoyaltyDiscount = discount
+	order.Total, err = order.Total.CheckedSub(discount)
+	return err
+}
+
+// spendLoyaltyPoints débite les points utilisés par la commande qui vient d'être
+// créée. La mise à jour est conditionnelle : deux commandes concurrentes ne
+// peuvent pas dépenser les mêmes points.
+func spendLoyaltyPoints(tx *gorm.DB, order models.Order) error {
+	res := tx.Model(&models.LoyaltyAccount{}).
+		Where("user_id = ? AND points >= ?", order.UserID, order.LoyaltyPoints).
+		UpdateColumns(map[string]interface{}{
+			"points":     gorm.Expr("points - ?", order.LoyaltyPoints),
+			"updated_at": time.Now(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrLoyaltyInsufficient
+	}
+
+	return tx.Create(&models.LoyaltyTransaction{
+		UserID:  order.UserID,
+		Kind:    models.LoyaltyBurn,
+		Points:  -order.LoyaltyPoints,
+		OrderID: &order.ID,
+	}).Error
+}
+
+// awardLoyaltyPoints crédite les points d'une commande qui vient d'être payée :
+// EarnRate points par unité dépensée (convertie dans la devise du programme),
+// majorés du bonus du niveau atteint avant cette commande. Sans taux de
+// conversion, la commande ne rapporte rien.
+func awardLoyaltyPoints(tx *gorm.DB, order models.Order, now time.Time) error {
+	program, err := LoadLoyaltyProgram(tx)
+	if err != nil {
+		return err
+	}
+	currency := program.PointValue.Currency
+	rate, err := FindExchangeRate(tx, order.Total.Currency, currency)
+	if err != nil {
+		if errors.Is(err, ErrRateUnavailable) {
+			return nil
+		}
+		return err
+	}
+	spend := order.Total.Convert(currency, rate.Rate)
+	if !spend.IsPositive() {
+		return nil
+	}
+
+	previous, err := yearlySpend(tx, order.UserID, currency, now)
+	if err != nil {
+		return err
+	}
+	bonus := 0
+	if tier, _ := tierFor(program.Tiers, previous); tier != nil {
+		bonus = tier.BonusPercent
+	}
+	unit := int64(math.Pow10(money.Exponent(currency)))
+	points := spend.Amount * int64(program.EarnRate) * int64(100+bonus) / (100 * unit)
+
+	return creditLoyaltyPoints(tx, order.UserID, models.LoyaltyTransaction{
+		Kind:      models.LoyaltyEarn,
+		Points:    int(points),
+		Spend:     spend,
+		OrderID:   &order.ID,
+		ExpiresAt: pointsExpiry(program, now),
+	})
+}
+
+// revokeLoyaltyPoints annule les effets d'une commande annulée, expirée ou
+// intégralement remboursée sur les points : ceux qu'elle avait rapportés sont
+// repris (le solde peut devenir négatif s'ils ont déjà été dépensés) et ceux
+// qu'elle avait consommés sont rendus avec une nouvelle date d'expiration.
+func revokeLoyaltyPoints(tx *gorm.DB, order models.Order, now time.Time) error {
+	var entries []models.LoyaltyTransaction
+	if err := tx.Where("order_id = ?", order.ID).Find(&entries).Error; err != nil {
+		return err
+	}
+
+	var earned, burned int
+	var spend money.Money
+	var earnedExpiry *time.Time
+	for _, entry := range entries {
+		switch entry.Kind {
+		case models.LoyaltyEarn, models.LoyaltyReverse:
+			if entry.Kind == models.LoyaltyEarn {
+				earnedExpiry = entry.ExpiresAt
+			}
+			earned += entry.Points
+			var err error
+			if spend, err = spend.CheckedAdd(entry.Spend); err != nil {
+				return err
+			}
+		case models.LoyaltyBurn, models.LoyaltyRestore:
+			burned -= entry.Points
+		}
+	}
+
+	if earned > 0 || spend.IsPositive() {
+		// La reprise porte l'échéance des points repris : elle annule ce lot-là
+		if err := creditLoyaltyPoints(tx, order.UserID, models.LoyaltyTransaction{
+			Kind:      models.LoyaltyReverse,
+			Points:    -earned,
+			Spend:     money.New(-spend.Amount, spend.Currency),
+			OrderID:   &order.ID,
+			ExpiresAt: earnedExpiry,
+		}); err != nil {
+			return err
+		}
+	}
+	if burned > 0 {
+		program, err := LoadLoyaltyProgram(tx)
+		if err != nil {
+			return err
+		}
+		return creditLoyaltyPoints(tx, order.UserID, models.LoyaltyTransaction{
+			Kind:      models.LoyaltyRestore,
+			Points:    burned,
+			OrderID:   &order.ID,
+			ExpiresAt: pointsExpiry(program, now),
+		})
+	}
+	return nil
+}
+
+// expiredPointsExpr : points arrivés à expiration et pas encore consommés. Les
+// débits (dépenses, expirations) consomment les points les plus anciens en
+// premier. Une reprise annule les points de sa propre commande : elle compte
+// avec eux, à leur échéance (sauf reprise antérieure, enregistrée sans échéance).
+const expiredPointsExpr = "SUM(CASE WHEN points < 0 AND (kind <> 'reverse' OR expires_at IS NULL) THEN points WHEN expires_at <= ? THEN points ELSE 0 END)"
+
+// ExpireLoyaltyPoints retire les points arrivés à expiration. Chaque compte est
+// traité dans sa propre transaction, après avoir verrouillé sa ligne : si
+// plusieurs instances de l'API balaient en même temps, les points n'expirent qu'une fois.
+func ExpireLoyaltyPoints(ctx context.Context, db *gorm.DB, now time.Time) (int, error) {
+	var userIDs []uint
+	if err := db.WithContext(ctx).Model(&models.LoyaltyTransaction{}).
+		Group("user_id").
+		Having(expiredPointsExpr+" > 0", now).
+		Limit(loyaltyExpiryBatch).
+		Pluck("user_id", &userIDs).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, userID := range userIDs {
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.LoyaltyAccount{}).Where("user_id = ?", userID).
+				UpdateColumn("updated_at", now).Error; err != nil {
+				return err
+			}
+
+			var points int
+			if err := tx.Model(&models.LoyaltyTransaction{}).Where("user_id = ?", userID).
+				Select("COALESCE("+expiredPointsExpr+", 0)", now).Scan(&points).Error; err != nil {
+				return err
+			}
+			if points <= 0 {
+				return nil
+			}
+
+			expired++
+			return creditLoyaltyPoints(tx, userID, models.LoyaltyTransaction{
+				Kind:   models.LoyaltyExpire,
+				Points: -points,
+			})
+		})
+		if err != nil {
+			return expired, err
+		}
+	}
+
+	return expired, nil
+}
+
+// GetLoyalty retourne le solde de points de l'utilisateur, sa valeur, le niveau
+// atteint et les dernières écritures.
+func GetLoyalty(db *gorm.DB, userID uint, now time.Time) (models.LoyaltySummary, error) {
+	summary := models.LoyaltySummary{Transactions: []models.LoyaltyTransaction{}}
+
+	program, err := LoadLoyaltyProgram(db)
+	if err != nil {
+		return summary, err
+	}
+	if summary.Points, err = loyaltyBalance(db, userID); err != nil {
+		return summary, err
+	}
+	summary.Value = program.PointValue.Mul(summary.Points)
+	if summary.Value.IsNegative() {
+		summary.Value = money.Zero(program.PointValue.Currency)
+	}
+
+	if summary.YearlySpend, err = yearlySpend(db, userID, program.PointValue.Currency, now); err != nil {
+		return summary, err
+	}
+	current, next := tierFor(program.Tiers, summary.YearlySpend)
+	if current != nil {
+		summary.Tier = current.Name
+	}
+	if next != nil {
+		gap := next.MinYearlySpend.Sub(summary.YearlySpend)
+		summary.NextTier = next.Name
+		summary.NextTierGap = &gap
+	}
+
+	err = db.Where("user_id = ?", userID).Order("id DESC").Limit(loyaltyHistoryLimit).Find(&summary.Transactions).Error
+	return summary, err
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"fmt"
+	"h3-travel/models"
+
+	"gorm.io/gorm"
+)
+
+// legacyAmount : ancienne colonne float (en euros) et la colonne en centimes qui la remplace
+type legacyAmount struct {
+	model  interface{}
+	table  string
+	legacy string
+	minor  string
+}
+
+var legacyAmounts = []legacyAmount{
+	{&models.Travel{}, "travels", "price", "price_minor"},
+	{&models.Order{}, "orders", "total", "total_minor"},
+	{&models.Order{}, "orders", "paid_amount", "paid_minor"},
+	{&models.Order{}, "orders", "refunded_amount", "refunded_minor"},
+	{&models.OrderItem{}, "order_items", "unit_price", "unit_price_minor"},
+	{&models.OrderItem{}, "order_items", "discount", "discount_minor"},
+	{&models.Payment{}, "payments", "amount", "amount_minor"},
+	{&models.Refund{}, "refunds", "amount", "amount_minor"},
+}
+
+// legacyCurrencies : anciennes colonnes de devise, recopiées dans les colonnes des montants
+var legacyCurrencies = []struct {
+	model   interface{}
+	table   string
+	targets []string
+}{
+	{&models.Order{}, "orders", []string{"total_currency", "paid_currency", "refunded_currency"}},
+	{&models.OrderItem{}, "order_items", []string{"unit_price_currency", "discount_currency"}},
+	{&models.Payment{}, "payments", []string{"amount_currency"}},
+}
+
+// MigrateLegacyAmounts convertit en unités mineures les montants des bases créées
+// avant le type money.Money (tous en euros), puis supprime les anciennes colonnes.
+// À appeler après AutoMigrate ; sans colonne héritée, ne fait rien.
+func MigrateLegacyAmounts(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		migrator := tx.Migrator()
+
+		for _, col := range legacyAmounts {
+			if !migrator.HasColumn(col.model, col.legacy) {
+				continue
+			}
+			sql := fmt.Sprintf("UPDATE %s SET %s = ROUND(%s * 100) WHERE %s IS NOT NULL", col.table, col.minor, col.legacy, col.legacy)
+			if err := tx.Exec(sql).Error; err != nil {
+				return err
+			}
+			if err := migrator.DropColumn(col.model, col.legacy); err != nil {
+				return err
+			}
+		}
+
+		for _, col := range legacyCurrencies {
+			if !migrator.HasColumn(col.model, "currency") {
+				continue
+			}
+			for _, target := range col.targets {
+				sql := fmt.Sprintf("UPDATE %s SET %s = currency WHERE currency IS NOT NULL", col.table, target)
+				if err := tx.Exec(sql).Error; err != nil {
+					return err
+				}
+			}
+			if err := migrator.DropColumn(col.model, "currency"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
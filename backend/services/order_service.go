@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"h3-travel/models"
+	"h3-travel/money"
+	"h3-travel/payment"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTravelNotFound    = errors.New("travel non trouvé")
+	ErrTravelUnavailable = errors.New("travel indisponible")
+	ErrOrderNotFound     = errors.New("commande non trouvée")
+	ErrCurrencyMismatch  = errors.New("les travels d'une commande doivent être dans la même devise")
+)
+
+// mergeItems regroupe les lignes portant sur le même travel (voyageurs compris)
+// et les trie par ID : les lignes de travels sont ainsi toujours verrouillées
+// dans le même ordre.
+func mergeItems(items []models.OrderItemInput) []models.OrderItemInput {
+	index := make(map[uint]int)
+	merged := make([]models.OrderItemInput, 0, len(items))
+	for _, item := range items {
+		i, ok := index[item.TravelID]
+		if !ok {
+			i = len(merged)
+			index[item.TravelID] = i
+			merged = append(merged, models.OrderItemInput{TravelID: item.TravelID, DepartureID: item.DepartureID})
+		}
+		merged[i].Quantity += item.Quantity
+		merged[i].Passengers = append(merged[i].Passengers, item.Passengers...)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].TravelID < merged[j].TravelID })
+
+	return merged
+}
+
+// CreateOrder réserve les places de chaque ligne pour la durée ttl et crée la
+// commande en attente de paiement, dans une seule transaction. Le stock est
+// décrémenté de façon conditionnelle (stock >= quantité) : si une seule ligne
+// n'est pas disponible, rien n'est réservé. Les voyageurs saisis sont chiffrés
+// avec key ; la part réglée avec le porte-monnaie est prélevée dans la même transaction.
+func CreateOrder(db *gorm.DB, key []byte, userID uint, input models.CreateOrderInput, ttl time.Duration) (models.Order, error) {
+	var order models.Order
+	actor := models.ActorUser(userID)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if order, err = createHold(tx, userID, input, ttl, actor); err != nil {
+			return err
+		}
+
+		var lists []models.PassengerListInput
+		for _, item := range mergeItems(input.Items) {
+			if len(item.Passengers) > 0 {
+				lists = append(lists, models.PassengerListInput{TravelID: item.TravelID, Passengers: item.Passengers})
+			}
+		}
+		if len(lists) > 0 {
+			if order.Passengers, err = replacePassengers(tx, key, order, lists, time.Now()); err != nil {
+				return err
+			}
+		}
+
+		return payFromWallet(tx, &order, input.WalletAmount, actor)
+	})
+	if err == nil {
+		ClearTravelFacets()
+	}
+
+	return order, err
+}
+
+// createHold réserve les places et crée la commande en attente de paiement dans
+// la transaction tx, au prix donné par les règles de tarification, remises du
+// code promo et des points de fidélité éventuels déduites, avec la TVA de chaque ligne.
+func createHold(tx *gorm.DB, userID uint, input models.CreateOrderInput, ttl time.Duration, actor string) (models.Order, error) {
+	expiresAt := time.Now().Add(ttl)
+	order := models.Order{
+		UserID:    userID,
+		Statut:    models.StatusPendingPayment,
+		ExpiresAt: &expiresAt,
+	}
+
+	if err := checkDepartures(input.Items); err != nil {
+		return order, err
+	}
+	merged := mergeItems(input.Items)
+	travelIDs := make([]uint, 0, len(merged))
+	for _, item := range merged {
+		travelIDs = append(travelIDs, item.TravelID)
+	}
+	rules, err := loadPricingRules(tx, travelIDs)
+	if err != nil {
+		return order, err
+	}
+	taxRates, err := loadTaxRates(tx)
+	if err != nil {
+		return order, err
+	}
+	now := time.Now()
+
+	for _, item := range merged {
+		travel, err := reserveItem(tx, item, now)
+		if err != nil {
+			return order, err
+		}
+
+		if order.Total.Currency == "" {
+			order.Total = money.Zero(travel.Price.Currency)
+		}
+		if travel.Price.Currency != order.Total.Currency {
+			return order, fmt.Errorf("%w (travel %d en %s)", ErrCurrencyMismatch, item.TravelID, travel.Price.Currency)
+		}
+
+		// Prix au stock d'avant cette commande
+		travel.Stock += item.Quantity
+		applyQuote(&travel, QuotePrice(travel, rules, now))
+
+		taxCategory := travel.TaxCategory
+		if taxCategory == "" {
+			taxCategory = models.TaxStandard
+		}
+		taxRate, ok := taxRates[taxCategory]
+		if !ok {
+			return order, fmt.Errorf("%w (travel %d : %s)", ErrTaxCategoryUnknown, item.TravelID, taxCategory)
+		}
+
+		line := models.OrderItem{
+			TravelID:    item.TravelID,
+			DepartureID: item.DepartureID,
+			Title:       travel.Title,
+			Quantity:    item.Quantity,
+			UnitPrice:   travel.Price,
+			Discount:    money.Zero(travel.Price.Currency),
+			TaxCategory: taxCategory,
+			TaxRate:     taxRate,
+		}
+		order.Items = append(order.Items, line)
+		order.Total = order.Total.Add(line.LineTotal())
+	}
+	order.Discount = money.Zero(order.Total.Currency)
+	order.PaidAmount = money.Zero(order.Total.Currency)
+	order.RefundedAmount = money.Zero(order.Total.Currency)
+	order.WalletAmount = money.Zero(order.Total.Currency)
+	order.LoyaltyDiscount = money.Zero(order.Total.Currency)
+
+	var promo models.PromoCode
+	if input.PromoCode != "" {
+		if promo, err = applyPromo(tx, &order, userID, input.PromoCode, now); err != nil {
+			return order, err
+		}
+	}
+	if input.LoyaltyPoints > 0 {
+		if err := applyLoyaltyDiscount(tx, &order, input.LoyaltyPoints); err != nil {
+			return order, err
+		}
+	}
+
+	applyTaxes(&order)
+
+	if err := tx.Create(&order).Error; err != nil {
+		return order, err
+	}
+
+	if promo.ID != 0 {
+		if err := tx.Create(&models.PromoRedemption{
+			PromoCodeID: promo.ID,
+			UserID:      userID,
+			OrderID:     order.ID,
+			Discount:    order.Discount,
+		}).Error; err != nil {
+			return order, err
+		}
+	}
+	if order.LoyaltyPoints > 0 {
+		if err := spendLoyaltyPoints(tx, order); err != nil {
+			return order, err
+		}
+	}
+
+	return order, recordHistory(tx, order.ID, "", order.Statut, actor)
+}
+
+// reserveItem décrémente le stock de la ligne, celui du départ choisi pour un
+// travel qui en a, et retourne le travel tel qu'il se vend à cette date : stock,
+// capacité et date du départ, et son prix s'il en a un.
+func reserveItem(tx *gorm.DB, item models.OrderItemInput, now time.Time) (models.Travel, error) {
+	var travel models.Travel
+	if item.DepartureID != nil {
+		departure, err := reserveDeparture(tx, item, now)
+		if err != nil {
+			return travel, err
+		}
+		if err := tx.First(&travel, item.TravelID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return travel, fmt.Errorf("%w (travel %d)", ErrTravelNotFound, item.TravelID)
+			}
+			return travel, err
+		}
+		if !travel.Active {
+			return travel, fmt.Errorf("%w (travel %d)", ErrTravelUnavailable, item.TravelID)
+		}
+
+		travel.Stock, travel.Capacity = departure.Stock, departure.Capacity
+		travel.DepartureDate = &departure.StartDate
+		if departure.Price.IsPositive() {
+			travel.Price = departure.Price
+		}
+		return travel, nil
+	}
+
+	// Décrément d'abord : la ligne du travel est ensuite verrouillée et le
+	// stock relu est exact pour les règles de remplissage
+	res := tx.Model(&models.Travel{}).
+		Where("id = ? AND stock >= ? AND active = ?", item.TravelID, item.Quantity, true).
+		UpdateColumn("stock", gorm.Expr("stock - ?", item.Quantity))
+	if res.Error != nil {
+		return travel, res.Error
+	}
+
+	if err := tx.First(&travel, item.TravelID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return travel, fmt.Errorf("%w (travel %d)", ErrTravelNotFound, item.TravelID)
+		}
+		return travel, err
+	}
+	var dated int64
+	if err := tx.Model(&models.Departure{}).Where("travel_id = ?", item.TravelID).Count(&dated).Error; err != nil {
+		return travel, err
+	}
+	if dated > 0 {
+		return travel, fmt.Errorf("%w (travel %d)", ErrDepartureRequired, item.TravelID)
+	}
+	if res.RowsAffected == 0 {
+		return travel, fmt.Errorf("%w (travel %d)", ErrTravelUnavailable, item.TravelID)
+	}
+	return travel, nil
+}
+
+// CancelOrder annule une commande, remet en stock chacune de ses lignes et
+// rembourse le montant prévu par la politique d'annulation des travels, le
+// tout dans une transaction. La transition est conditionnelle pour qu'une
+// double annulation concurrente ne restocke ni ne rembourse deux fois. Les
+// places libérées sont proposées à la liste d'attente pendant offerTTL.
+func CancelOrder(ctx context.Context, db *gorm.DB, provider payment.Provider, userID, orderID uint, toWallet bool, offerTTL time.Duration) (models.Order, models.CancellationQuote, error) {
+	return cancelOrder(ctx, db, provider, orderID, userID, models.RefundByPolicy, toWallet, "Annulation", models.ActorUser(userID), offerTTL)
+}
+
+// cancelOrder annule la commande orderID et la rembourse selon mode, en avoir sur
+// le porte-monnaie si toWallet. userID à 0 : la commande peut appartenir à
+// n'importe quel utilisateur (annulation par un admin).
+func cancelOrder(ctx context.Context, db *gorm.DB, provider payment.Provider, orderID, userID uint, mode models.RefundMode, toWallet bool, reason, actor string, offerTTL time.Duration) (models.Order, models.CancellationQuote, error) {
+	var order models.Order
+	var quote models.CancellationQuote
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if userID != 0 {
+			err = tx.Preload("Items").First(&order, "id = ? AND user_id = ?", orderID, userID).Error
+		} else {
+			err = tx.Preload("Items").First(&order, orderID).Error
+		}
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrderNotFound
+			}
+			return err
+		}
+
+		wasPaid := order.Statut != models.StatusPendingPayment
+		if err := TransitionOrder(tx, &order, models.StatusCancelled, actor); err != nil {
+			return err
+		}
+		// Une réservation jamais payée rend son code promo et la part prélevée sur le porte-monnaie
+		if !wasPaid {
+			if err := releasePromo(tx, order.ID); err != nil {
+				return err
+			}
+			if err := releaseWallet(tx, order); err != nil {
+				return err
+			}
+		}
+		if err := revokeLoyaltyPoints(tx, order, time.Now()); err != nil {
+			return err
+		}
+
+		if err := restockItems(tx, order.Items); err != nil {
+			return err
+		}
+		if err := settleWaitlistOffer(tx, order.ID, models.WaitlistDeclined); err != nil {
+			return err
+		}
+
+		quote = models.CancellationQuote{OrderID: order.ID, PaidAmount: order.PaidAmount, RefundAmount: money.Zero(order.PaidAmount.Currency)}
+		if !order.RefundableAmount.IsPositive() {
+			return nil
+		}
+
+		switch mode {
+		case models.RefundNone:
+			return nil
+		case models.RefundFull:
+			quote.RefundAmount = order.RefundableAmount
+		default:
+			if quote, err = quoteCancellation(tx, order, time.Now()); err != nil {
+				return err
+			}
+		}
+		if quote.RefundAmount.IsPositive() {
+			refunds, err := issueRefund(tx, &order, quote.RefundAmount, toWallet, reason, actor)
+			if err != nil {
+				return err
+			}
+			order.Refunds = refunds
+		}
+		return nil
+	})
+	if err != nil {
+		return order, quote, err
+	}
+	ClearTravelFacets()
+	settleRefunds(ctx, db, provider, order.Refunds)
+
+	// Les places libérées sont proposées à la liste d'attente
+	promoteWaitlists(ctx, db, order.Items, offerTTL)
+	return order, quote, nil
+}
+
+func restockItems(tx *gorm.DB, items []models.OrderItem) error {
+	for _, item := range items {
+		if item.DepartureID != nil {
+			if err := tx.Model(&models.Departure{}).
+				Where("id = ?", *item.DepartureID).
+				UpdateColumn("stock", gorm.Expr("stock + ?", item.Quantity)).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err := tx.Model(&models.Travel{}).
+			Where("id = ?", item.TravelID).
+			UpdateColumn("stock", gorm.Expr("stock + ?", item.Quantity)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OrderSnapshotsMissing indique si la base précède l'enregistrement du titre sur
+// les lignes de commande. À appeler avant AutoMigrate, qui ajoute la colonne : la
+// reprise par BackfillOrderSnapshots n'est alors faite qu'une fois.
+func OrderSnapshotsMissing(db *gorm.DB) bool {
+	migrator := db.Migrator()
+	return migrator.HasTable(&models.OrderItem{}) && !migrator.HasColumn(&models.OrderItem{}, "title")
+}
+
+// BackfillOrderSnapshots fige le titre des lignes de commande créées avant que
+// celui-ci ne soit enregistré à l'achat, à partir du travel (même supprimé).
+func BackfillOrderSnapshots(db *gorm.DB) error {
+	return db.Exec(`UPDATE order_items SET title = (SELECT travels.title FROM travels WHERE travels.id = order_items.travel_id)
+		WHERE title = '' AND EXISTS (SELECT 1 FROM travels WHERE travels.id = order_items.travel_id)`).Error
+}
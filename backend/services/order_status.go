@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"h3-travel/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TransitionOrder fait passer la commande vers le statut demandé si la machine
+// à états l'autorise, et trace le changement dans l'historique. La mise à jour
+// est conditionnée au statut lu : une transition concurrente fait échouer celle-ci.
+func TransitionOrder(tx *gorm.DB, order *models.Order, to models.OrderStatus, actor string) error {
+	from := order.Statut
+	if !from.CanTransitionTo(to) {
+		return &models.TransitionError{From: from, To: to}
+	}
+
+	res := tx.Model(&models.Order{}).
+		Where("id = ? AND statut = ?", order.ID, from).
+		Update("statut", to)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return &models.TransitionError{From: from, To: to}
+	}
+	order.Statut = to
+
+	return recordHistory(tx, order.ID, from, to, actor)
+}
+
+// ConfirmOrder fait passer une commande payée à l'état confirmé, une fois le
+// voyage validé auprès des prestataires.
+func ConfirmOrder(db *gorm.DB, orderID uint, actor string) (models.Order, error) {
+	var order models.Order
+	if err := db.First(&order, orderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return order, ErrOrderNotFound
+		}
+		return order, err
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return TransitionOrder(tx, &order, models.StatusConfirmed, actor)
+	}); err != nil {
+		return order, err
+	}
+	return loadOrderDetails(db, order.ID)
+}
+
+// completedOrdersExpr : commandes dont chaque ligne a une date de départ
+// (celle du départ réservé, sinon celle du travel) passée
+const completedOrdersExpr = "NOT EXISTS (SELECT 1 FROM order_items oi " +
+	"JOIN travels t ON t.id = oi.travel_id LEFT JOIN departures d ON d.id = oi.departure_id " +
+	"WHERE oi.order_id = orders.id AND (COALESCE(d.start_date, t.departure_date) IS NULL OR COALESCE(d.start_date, t.departure_date) > ?))"
+
+// CompleteOrders passe à l'état terminé les commandes payées ou confirmées dont
+// tous les voyages sont partis. Une commande sans date de départ reste en
+// l'état. Comme pour ExpireHolds, chaque commande a sa propre transaction et
+// une commande déjà traitée par une autre instance est ignorée.
+func CompleteOrders(ctx context.Context, db *gorm.DB, now time.Time) (int, error) {
+	var ids []uint
+	if err := db.WithContext(ctx).Model(&models.Order{}).
+		Where("statut IN ?", []models.OrderStatus{models.StatusPaid, models.StatusConfirmed}).
+		Where(completedOrdersExpr, now).
+		Order("id").
+		Limit(holdSweepBatch).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	completed := 0
+	for _, id := range ids {
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var order models.Order
+			if err := tx.First(&order, id).Error; err != nil {
+				return err
+			}
+			return TransitionOrder(tx, &order, models.StatusCompleted, models.ActorSystem)
+		})
+
+		switch {
+		case err == nil:
+			completed++
+		case errors.Is(err, models.ErrInvalidTransition), errors.Is(err, gorm.ErrRecordNotFound):
+			// annulée, remboursée ou terminée entretemps
+		default:
+			return completed, err
+		}
+	}
+
+	return completed, nil
+}
+
+func recordHistory(tx *gorm.DB, orderID uint, from, to models.OrderStatus, actor string) error {
+	return tx.Create(&models.OrderHistory{
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   to,
+		Actor:      actor,
+		ChangedAt:  time.Now(),
+	}).Error
+}
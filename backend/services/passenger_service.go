@@ -0,0 +1,208 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"h3-travel/models"
+	"h3-travel/vault"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPassengerInvalid = errors.New("voyageur invalide")
+	ErrPassengersLocked = errors.New("les voyageurs de cette commande ne sont plus modifiables")
+)
+
+const dateLayout = "2006-01-02"
+
+// normalizePassenger nettoie la saisie et vérifie sa cohérence : date de
+// naissance passée, document d'identité valable au moins jusqu'au départ (ou
+// jusqu'à aujourd'hui si la date de départ n'est pas connue).
+func normalizePassenger(p models.PassengerDetails, departure *time.Time, now time.Time) (models.PassengerDetails, error) {
+	p.FirstName = strings.TrimSpace(p.FirstName)
+	p.LastName = strings.TrimSpace(p.LastName)
+	p.DocumentNumber = strings.ToUpper(strings.TrimSpace(p.DocumentNumber))
+	p.Nationality = strings.ToUpper(p.Nationality)
+	if p.FirstName == "" || p.LastName == "" || p.DocumentNumber == "" {
+		return p, fmt.Errorf("%w : nom, prénom et numéro de document requis", ErrPassengerInvalid)
+	}
+
+	birth, err := time.Parse(dateLayout, p.DateOfBirth)
+	if err != nil || birth.After(now) {
+		return p, fmt.Errorf("%w : date de naissance %q", ErrPassengerInvalid, p.DateOfBirth)
+	}
+
+	expiry, err := time.Parse(dateLayout, p.DocumentExpiry)
+	if err != nil {
+		return p, fmt.Errorf("%w : date d'expiration %q", ErrPassengerInvalid, p.DocumentExpiry)
+	}
+	validUntil := now
+	if departure != nil && departure.After(now) {
+		validUntil = *departure
+	}
+	// Le document doit être valable toute la journée du départ
+	if expiry.Before(time.Date(validUntil.Year(), validUntil.Month(), validUntil.Day(), 0, 0, 0, 0, time.UTC)) {
+		return p, fmt.Errorf("%w : le document de %s %s expire avant le voyage", ErrPassengerInvalid, p.FirstName, p.LastName)
+	}
+	return p, nil
+}
+
+func listTravelIDs(lists []models.PassengerListInput) []uint {
+	ids := make([]uint, 0, len(lists))
+	for _, list := range lists {
+		ids = append(ids, list.TravelID)
+	}
+	return ids
+}
+
+// replacePassengers valide, chiffre et enregistre les voyageurs des lignes
+// données, en remplaçant ceux déjà saisis pour ces travels. Chaque ligne doit
+// compter exactement autant de voyageurs que de places réservées.
+func replacePassengers(tx *gorm.DB, key []byte, order models.Order, lists []models.PassengerListInput, now time.Time) ([]models.Passenger, error) {
+	quantities := make(map[uint]int, len(order.Items))
+	for _, item := range order.Items {
+		quantities[item.TravelID] += item.Quantity
+	}
+
+	departures, err := departureDates(tx, order.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	var passengers []models.Passenger
+	seen := make(map[uint]bool, len(lists))
+	for _, list := range lists {
+		quantity, ok := quantities[list.TravelID]
+		if !ok || seen[list.TravelID] {
+			return nil, fmt.Errorf("%w : travel %d absent de la commande ou cité deux fois", ErrPassengerInvalid, list.TravelID)
+		}
+		seen[list.TravelID] = true
+		if len(list.Passengers) != quantity {
+			return nil, fmt.Errorf("%w : %d voyageur(s) pour %d place(s) sur le travel %d",
+				ErrPassengerInvalid, len(list.Passengers), quantity, list.TravelID)
+		}
+
+		for _, details := range list.Passengers {
+			details, err := normalizePassenger(details, departures[list.TravelID], now)
+			if err != nil {
+				return nil, err
+			}
+			plaintext, err := json.Marshal(details)
+			if err != nil {
+				return nil, err
+			}
+			sealed, err := vault.Seal(key, plaintext, passengerContext(order.ID, list.TravelID))
+			if err != nil {
+				return nil, err
+			}
+			passengers = append(passengers, models.Passenger{
+				OrderID:          order.ID,
+				TravelID:         list.TravelID,
+				Sealed:           sealed,
+				PassengerDetails: details,
+			})
+		}
+	}
+
+	if err := tx.Where("order_id = ? AND travel_id IN ?", order.ID, listTravelIDs(lists)).Delete(&models.Passenger{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&passengers).Error; err != nil {
+		return nil, err
+	}
+	return passengers, nil
+}
+
+// passengerContext lie les données chiffrées d'un voyageur à sa commande et à
+// son travel : recopiées sur une autre ligne ou dans une autre table, elles ne
+// se déchiffrent plus.
+func passengerContext(orderID, travelID uint) []byte {
+	return []byte(fmt.Sprintf("passenger:%d:%d", orderID, travelID))
+}
+
+// openPassengers déchiffre les voyageurs chargés depuis la base.
+func openPassengers(key []byte, passengers []models.Passenger) error {
+	for i := range passengers {
+		plaintext, err := vault.Open(key, passengers[i].Sealed, passengerContext(passengers[i].OrderID, passengers[i].TravelID))
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(plaintext, &passengers[i].PassengerDetails); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func findUserOrder(db *gorm.DB, userID, orderID uint) (models.Order, error) {
+	var order models.Order
+	if err := db.Preload("Items").First(&order, "id = ? AND user_id = ?", orderID, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return order, ErrOrderNotFound
+		}
+		return order, err
+	}
+	return order, nil
+}
+
+// GetPassengers retourne, déchiffrés, les voyageurs d'une commande de l'utilisateur.
+func GetPassengers(db *gorm.DB, key []byte, userID, orderID uint) ([]models.Passenger, error) {
+	if _, err := findUserOrder(db, userID, orderID); err != nil {
+		return nil, err
+	}
+
+	passengers := []models.Passenger{}
+	if err := db.Where("order_id = ?", orderID).Order("travel_id").Order("id").Find(&passengers).Error; err != nil {
+		return nil, err
+	}
+	return passengers, openPassengers(key, passengers)
+}
+
+// UpdatePassengers remplace les voyageurs des travels cités. Ce n'est possible
+// que sur une commande en cours, jusqu'à cutoff avant le départ de chacun d'eux.
+func UpdatePassengers(db *gorm.DB, key []byte, userID, orderID uint, lists []models.PassengerListInput, cutoff time.Duration) ([]models.Passenger, error) {
+	var passengers []models.Passenger
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		order, err := findUserOrder(tx, userID, orderID)
+		if err != nil {
+			return err
+		}
+		switch order.Statut {
+		case models.StatusPendingPayment, models.StatusPaid, models.StatusConfirmed:
+		default:
+			return ErrPassengersLocked
+		}
+
+		// Seuls les travels cités et déjà commandés comptent : les autres sont refusés ensuite
+		now := time.Now()
+		listed := make(map[uint]bool, len(lists))
+		for _, travelID := range listTravelIDs(lists) {
+			listed[travelID] = true
+		}
+		departures, err := departureDates(tx, order.Items)
+		if err != nil {
+			return err
+		}
+		for _, item := range order.Items {
+			departure := departures[item.TravelID]
+			if listed[item.TravelID] && departure != nil && !now.Before(departure.Add(-cutoff)) {
+				return ErrPassengersLocked
+			}
+		}
+
+		if _, err := replacePassengers(tx, key, order, lists, now); err != nil {
+			return err
+		}
+		return tx.Where("order_id = ?", orderID).Order("travel_id").Order("id").Find(&passengers).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return passengers, openPassengers(key, passengers)
+}
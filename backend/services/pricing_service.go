@@ -0,0 +1,276 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"h3-travel/models"
+	"h3-travel/money"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPricingRuleNotFound = errors.New("règle de tarification non trouvée")
+	ErrPricingRuleInvalid  = errors.New("règle de tarification invalide")
+)
+
+// loadPricingRules charge les règles actives qui concernent au moins un des travels.
+func loadPricingRules(db *gorm.DB, travelIDs []uint) ([]models.PricingRule, error) {
+	var rules []models.PricingRule
+	if len(travelIDs) == 0 {
+		return rules, nil
+	}
+	err := db.Where("active = ? AND (travel_id IS NULL OR travel_id IN ?)", true, travelIDs).
+		Order("id").Find(&rules).Error
+	return rules, err
+}
+
+// daysBeforeDeparture : nombre de jours entiers restant avant le départ, nil si
+// la date de départ est inconnue ou passée.
+func daysBeforeDeparture(travel models.Travel, now time.Time) *int {
+	if travel.DepartureDate == nil || travel.DepartureDate.Before(now) {
+		return nil
+	}
+	days := int(math.Floor(travel.DepartureDate.Sub(now).Hours() / 24))
+	return &days
+}
+
+// ruleMatches indique si la règle se déclenche ; threshold sert à départager les
+// règles d'un même type (la plus petite valeur est la plus exigeante).
+func ruleMatches(rule models.PricingRule, days *int, stock, capacity int) (bool, int) {
+	switch rule.Kind {
+	case models.PricingEarlyBird:
+		return days != nil && *days >= rule.Days, -rule.Days
+	case models.PricingLastMinute:
+		return days != nil && *days <= rule.Days, rule.Days
+	case models.PricingOccupancy:
+		return capacity > 0 && stock*100 < rule.StockPercent*capacity, rule.StockPercent
+	}
+	return false, 0
+}
+
+// QuotePrice calcule le prix du travel à l'instant now à partir de son prix de
+// base et des règles données. Pour chaque type, la règle au seuil le plus
+// exigeant parmi celles déclenchées s'applique (à seuil égal, celle propre au
+// travel l'emporte sur une règle globale) ; les ajustements des différents types
+// s'additionnent, toujours calculés sur le prix de base. Le prix ne descend pas sous zéro.
+func QuotePrice(travel models.Travel, rules []models.PricingRule, now time.Time) models.PriceQuote {
+	quote := models.PriceQuote{
+		TravelID:            travel.ID,
+		At:                  now,
+		BasePrice:           travel.Price,
+		Price:               travel.Price,
+		DaysBeforeDeparture: daysBeforeDeparture(travel, now),
+		Stock:               travel.Stock,
+		Capacity:            travel.Capacity,
+		Rules:               []models.AppliedPricingRule{},
+	}
+
+	type candidate struct {
+		rule      models.PricingRule
+		threshold int
+	}
+	best := make(map[models.PricingRuleKind]candidate)
+	var kinds []models.PricingRuleKind
+	for _, rule := range rules {
+		if rule.TravelID != nil && *rule.TravelID != travel.ID {
+			continue
+		}
+		ok, threshold := ruleMatches(rule, quote.DaysBeforeDeparture, travel.Stock, travel.Capacity)
+		if !ok {
+			continue
+		}
+		current, seen := best[rule.Kind]
+		if !seen {
+			kinds = append(kinds, rule.Kind)
+		}
+		if !seen || threshold < current.threshold ||
+			(threshold == current.threshold && current.rule.TravelID == nil && rule.TravelID != nil) {
+			best[rule.Kind] = candidate{rule, threshold}
+		}
+	}
+
+	for _, kind := range kinds {
+		rule := best[kind].rule
+		adjustment := travel.Price.Percent(rule.Percent)
+		quote.Price = quote.Price.Add(adjustment)
+		quote.Rules = append(quote.Rules, models.AppliedPricingRule{
+			RuleID:     rule.ID,
+			Name:       rule.Name,
+			Kind:       rule.Kind,
+			Percent:    rule.Percent,
+			Adjustment: adjustment,
+		})
+	}
+	if quote.Price.IsNegative() {
+		quote.Price = money.Zero(quote.Price.Currency)
+	}
+
+	return quote
+}
+
+// applyQuote remplace le prix du travel par le prix calculé et garde le prix de base à côté.
+func applyQuote(travel *models.Travel, quote models.PriceQuote) {
+	if len(quote.Rules) == 0 {
+		return
+	}
+	base := travel.Price
+	travel.BasePrice = &base
+	travel.Price = quote.Price
+	travel.PricingRules = quote.Rules
+}
+
+// ApplyDynamicPricing remplace le prix de chaque travel par celui que donnent les
+// règles de tarification actives à l'instant now.
+func ApplyDynamicPricing(db *gorm.DB, travels []models.Travel, now time.Time) error {
+	ids := make([]uint, 0, len(travels))
+	for _, travel := range travels {
+		ids = append(ids, travel.ID)
+	}
+	rules, err := loadPricingRules(db, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range travels {
+		applyQuote(&travels[i], QuotePrice(travels[i], rules, now))
+	}
+	return nil
+}
+
+// DryRunPricing calcule le prix d'un travel sans rien enregistrer, éventuellement
+// à une autre date ou avec un autre stock que l'actuel.
+func DryRunPricing(db *gorm.DB, input models.PricingDryRunInput) (models.PriceQuote, error) {
+	var travel models.Travel
+	if err := db.First(&travel, input.TravelID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.PriceQuote{}, ErrTravelNotFound
+		}
+		return models.PriceQuote{}, err
+	}
+
+	at := time.Now()
+	if input.At != nil {
+		at = *input.At
+	}
+	if input.Stock != nil {
+		travel.Stock = *input.Stock
+	}
+
+	rules, err := loadPricingRules(db, []uint{travel.ID})
+	if err != nil {
+		return models.PriceQuote{}, err
+	}
+	return QuotePrice(travel, rules, at), nil
+}
+
+// BackfillTravelCapacity renseigne la capacité des travels créés avant son
+// introduction : stock restant plus places vendues dans les commandes en cours.
+func BackfillTravelCapacity(db *gorm.DB) error {
+	return db.Exec(`UPDATE travels SET capacity = stock + COALESCE((
+			SELECT SUM(order_items.quantity) FROM order_items
+			JOIN orders ON orders.id = order_items.order_id
+			WHERE order_items.travel_id = travels.id AND orders.statut NOT IN (?, ?) AND orders.deleted_at IS NULL
+		), 0)
+		WHERE capacity = 0`, models.StatusCancelled, models.StatusExpired).Error
+}
+
+// --- Administration des règles de tarification ---
+
+func ListPricingRules(db *gorm.DB) ([]models.PricingRule, error) {
+	var rules []models.PricingRule
+	err := db.Order("id").Find(&rules).Error
+	return rules, err
+}
+
+func GetPricingRule(db *gorm.DB, id uint) (models.PricingRule, error) {
+	var rule models.PricingRule
+	if err := db.First(&rule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return rule, ErrPricingRuleNotFound
+		}
+		return rule, err
+	}
+	return rule, nil
+}
+
+// SavePricingRule crée (id = 0) ou remplace une règle de tarification.
+func SavePricingRule(db *gorm.DB, id uint, input models.PricingRuleInput) (models.PricingRule, error) {
+	var rule models.PricingRule
+	if id != 0 {
+		var err error
+		if rule, err = GetPricingRule(db, id); err != nil {
+			return rule, err
+		}
+	}
+
+	if err := pricingRuleFromInput(&rule, input); err != nil {
+		return rule, err
+	}
+	if rule.TravelID != nil {
+		var found int64
+		if err := db.Model(&models.Travel{}).Where("id = ?", *rule.TravelID).Count(&found).Error; err != nil {
+			return rule, err
+		}
+		if found == 0 {
+			return rule, ErrTravelNotFound
+		}
+	}
+
+	if id != 0 {
+		return rule, db.Save(&rule).Error
+	}
+
+	// Create remplace la valeur false par le défaut de la colonne
+	active := rule.Active
+	if err := db.Create(&rule).Error; err != nil {
+		return rule, err
+	}
+	if !active {
+		rule.Active = false
+		if err := db.Model(&rule).UpdateColumn("active", false).Error; err != nil {
+			return rule, err
+		}
+	}
+	return rule, nil
+}
+
+func DeletePricingRule(db *gorm.DB, id uint) error {
+	res := db.Delete(&models.PricingRule{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrPricingRuleNotFound
+	}
+	return nil
+}
+
+func pricingRuleFromInput(rule *models.PricingRule, input models.PricingRuleInput) error {
+	rule.Name = input.Name
+	rule.Kind = input.Kind
+	rule.TravelID = input.TravelID
+	rule.Percent = input.Percent
+	rule.Days = 0
+	rule.StockPercent = 0
+
+	switch input.Kind {
+	case models.PricingEarlyBird:
+		if input.Days == 0 {
+			return fmt.Errorf("%w : nombre de jours requis", ErrPricingRuleInvalid)
+		}
+		rule.Days = input.Days
+	case models.PricingLastMinute:
+		rule.Days = input.Days
+	case models.PricingOccupancy:
+		if input.StockPercent == 0 {
+			return fmt.Errorf("%w : pourcentage de stock requis", ErrPricingRuleInvalid)
+		}
+		rule.StockPercent = input.StockPercent
+	}
+
+	rule.Active = input.Active == nil || *input.Active
+	return nil
+}
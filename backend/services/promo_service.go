@@ -0,0 +1,322 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"h3-travel/models"
+	"h3-travel/money"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPromoNotFound      = errors.New("code promo inconnu")
+	ErrPromoInvalid       = errors.New("code promo invalide")
+	ErrPromoCodeTaken     = errors.New("code promo déjà existant")
+	ErrPromoInactive      = errors.New("code promo inactif ou hors de sa période de validité")
+	ErrPromoExhausted     = errors.New("code promo épuisé")
+	ErrPromoUserLimit     = errors.New("nombre d'utilisations du code promo atteint pour ce compte")
+	ErrPromoMinOrder      = errors.New("montant minimum de commande non atteint pour ce code promo")
+	ErrPromoNotApplicable = errors.New("code promo non applicable aux travels commandés")
+)
+
+func normalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// applyPromo valide le code pour la commande en cours de création, répartit la
+// remise sur les lignes concernées et compte l'utilisation. Le compteur global
+// est incrémenté par une mise à jour conditionnelle qui verrouille aussi la
+// ligne du code : les utilisations concurrentes d'un même code sont sérialisées,
+// ce qui rend sûr le contrôle de la limite par utilisateur qui suit.
+func applyPromo(tx *gorm.DB, order *models.Order, userID uint, code string, now time.Time) (models.PromoCode, error) {
+	var promo models.PromoCode
+	if err := tx.Preload("Travels").First(&promo, "code = ?", normalizePromoCode(code)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return promo, ErrPromoNotFound
+		}
+		return promo, err
+	}
+
+	if !promo.Active || (promo.ValidFrom != nil && now.Before(*promo.ValidFrom)) || (promo.ValidUntil != nil && now.After(*promo.ValidUntil)) {
+		return promo, ErrPromoInactive
+	}
+
+	subtotal := order.Total
+	if promo.MinOrder.IsPositive() &&
+		(promo.MinOrder.Currency != subtotal.Currency || subtotal.Amount < promo.MinOrder.Amount) {
+		return promo, ErrPromoMinOrder
+	}
+
+	restricted := make(map[uint]bool, len(promo.Travels))
+	for _, t := range promo.Travels {
+		restricted[t.TravelID] = true
+	}
+	var eligible []int
+	eligibleTotal := money.Zero(subtotal.Currency)
+	for i, item := range order.Items {
+		if len(restricted) == 0 || restricted[item.TravelID] {
+			eligible = append(eligible, i)
+			eligibleTotal = eligibleTotal.Add(item.UnitPrice.Mul(item.Quantity))
+		}
+	}
+	if len(eligible) == 0 || !eligibleTotal.IsPositive() {
+		return promo, ErrPromoNotApplicable
+	}
+
+	switch promo.Kind {
+	case models.PromoPercent:
+		for _, i := range eligible {
+			item := &order.Items[i]
+			item.Discount = item.UnitPrice.Mul(item.Quantity).Percent(promo.Percent)
+		}
+	case models.PromoFixed:
+		if promo.Amount.Currency != subtotal.Currency {
+			return promo, ErrPromoNotApplicable
+		}
+		// Répartition au prorata des lignes ; le reste des arrondis va à la dernière
+		discount, err := promo.Amount.CheckedMin(eligibleTotal)
+		if err != nil {
+			return promo, err
+		}
+		remaining := discount
+		for n, i := range eligible {
+			item := &order.Items[i]
+			line := item.UnitPrice.Mul(item.Quantity)
+			if n == len(eligible)-1 {
+				item.Discount = remaining
+			} else {
+				item.Discount = money.New(discount.Amount*line.Amount/eligibleTotal.Amount, subtotal.Currency)
+			}
+			remaining = remaining.Sub(item.Discount)
+		}
+	}
+
+	order.Discount = money.Zero(subtotal.Currency)
+	for _, item := range order.Items {
+		order.Discount = order.Discount.Add(item.Discount)
+	}
+	order.Total = subtotal.Sub(order.Discount)
+	order.PromoCode = promo.Code
+
+	res := tx.Model(&models.PromoCode{}).
+		Where("id = ? AND (max_uses = 0 OR used_count < max_uses)", promo.ID).
+		UpdateColumn("used_count", gorm.Expr("used_count + 1"))
+	if res.Error != nil {
+		return promo, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return promo, ErrPromoExhausted
+	}
+
+	if promo.MaxUsesPerUser > 0 {
+		var used int64
+		if err := tx.Model(&models.PromoRedemption{}).
+			Where("promo_code_id = ? AND user_id = ?", promo.ID, userID).
+			Count(&used).Error; err != nil {
+			return promo, err
+		}
+		if used >= int64(promo.MaxUsesPerUser) {
+			return promo, ErrPromoUserLimit
+		}
+	}
+
+	return promo, nil
+}
+
+// releasePromo rend l'utilisation du code promo d'une commande jamais payée (expirée ou annulée).
+func releasePromo(tx *gorm.DB, orderID uint) error {
+	var redemption models.PromoRedemption
+	if err := tx.Where("order_id = ?", orderID).Limit(1).Find(&redemption).Error; err != nil || redemption.ID == 0 {
+		return err
+	}
+	if err := tx.Delete(&redemption).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&models.PromoCode{}).
+		Where("id = ? AND used_count > 0", redemption.PromoCodeID).
+		UpdateColumn("used_count", gorm.Expr("used_count - 1")).Error
+}
+
+// --- Administration des codes promo ---
+
+func ListPromoCodes(db *gorm.DB) ([]models.PromoCode, error) {
+	var promos []models.PromoCode
+	if err := db.Preload("Travels").Order("id").Find(&promos).Error; err != nil {
+		return nil, err
+	}
+	for i := range promos {
+		fillPromoTravelIDs(&promos[i])
+	}
+	return promos, nil
+}
+
+func GetPromoCode(db *gorm.DB, id uint) (models.PromoCode, error) {
+	var promo models.PromoCode
+	if err := db.Preload("Travels").First(&promo, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return promo, ErrPromoNotFound
+		}
+		return promo, err
+	}
+	fillPromoTravelIDs(&promo)
+	return promo, nil
+}
+
+// SavePromoCode crée (id = 0) ou remplace un code promo. Le compteur d'utilisations n'est jamais modifié.
+func SavePromoCode(db *gorm.DB, id uint, input models.PromoCodeInput) (models.PromoCode, error) {
+	var promo models.PromoCode
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if id != 0 {
+			if err := tx.First(&promo, id).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrPromoNotFound
+				}
+				return err
+			}
+		}
+		if err := promoFromInput(&promo, input); err != nil {
+			return err
+		}
+
+		var taken int64
+		if err := tx.Unscoped().Model(&models.PromoCode{}).
+			Where("code = ? AND id <> ?", promo.Code, id).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrPromoCodeTaken
+		}
+
+		travelIDs := uniqueIDs(input.TravelIDs)
+		if len(travelIDs) > 0 {
+			var found int64
+			if err := tx.Model(&models.Travel{}).Where("id IN ?", travelIDs).Count(&found).Error; err != nil {
+				return err
+			}
+			if found != int64(len(travelIDs)) {
+				return ErrTravelNotFound
+			}
+		}
+
+		if id == 0 {
+			// Create remplace la valeur false par le défaut de la colonne
+			active := promo.Active
+			if err := tx.Omit(clause.Associations).Create(&promo).Error; err != nil {
+				return err
+			}
+			if !active {
+				promo.Active = false
+				if err := tx.Model(&promo).UpdateColumn("active", false).Error; err != nil {
+					return err
+				}
+			}
+		} else if err := tx.Omit("UsedCount", clause.Associations).Save(&promo).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("promo_code_id = ?", promo.ID).Delete(&models.PromoCodeTravel{}).Error; err != nil {
+			return err
+		}
+		promo.Travels = nil
+		for _, travelID := range travelIDs {
+			promo.Travels = append(promo.Travels, models.PromoCodeTravel{PromoCodeID: promo.ID, TravelID: travelID})
+		}
+		if len(promo.Travels) > 0 {
+			if err := tx.Create(&promo.Travels).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return promo, err
+	}
+
+	fillPromoTravelIDs(&promo)
+	return promo, nil
+}
+
+func DeletePromoCode(db *gorm.DB, id uint) error {
+	res := db.Delete(&models.PromoCode{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrPromoNotFound
+	}
+	return nil
+}
+
+func promoFromInput(promo *models.PromoCode, input models.PromoCodeInput) error {
+	promo.Code = normalizePromoCode(input.Code)
+	if promo.Code == "" {
+		return fmt.Errorf("%w : code vide", ErrPromoInvalid)
+	}
+	promo.Kind = input.Kind
+	promo.Percent = 0
+	promo.Amount = money.Money{}
+
+	switch input.Kind {
+	case models.PromoPercent:
+		if input.Percent == 0 {
+			return fmt.Errorf("%w : pourcentage requis", ErrPromoInvalid)
+		}
+		promo.Percent = input.Percent
+	case models.PromoFixed:
+		if input.Amount == nil || !input.Amount.IsPositive() {
+			return fmt.Errorf("%w : montant requis", ErrPromoInvalid)
+		}
+		promo.Amount = *input.Amount
+	}
+
+	promo.MinOrder = money.Money{}
+	if input.MinOrder != nil {
+		promo.MinOrder = *input.MinOrder
+	}
+	for _, m := range []*money.Money{&promo.Amount, &promo.MinOrder} {
+		if m.Currency == "" {
+			m.Currency = money.DefaultCurrency
+			continue
+		}
+		currency, err := money.NormalizeCurrency(m.Currency)
+		if err != nil {
+			return fmt.Errorf("%w : %v", ErrPromoInvalid, err)
+		}
+		m.Currency = currency
+	}
+
+	if input.ValidFrom != nil && input.ValidUntil != nil && !input.ValidUntil.After(*input.ValidFrom) {
+		return fmt.Errorf("%w : fin de validité avant le début", ErrPromoInvalid)
+	}
+	promo.ValidFrom = input.ValidFrom
+	promo.ValidUntil = input.ValidUntil
+	promo.MaxUses = input.MaxUses
+	promo.MaxUsesPerUser = input.MaxUsesPerUser
+	promo.Active = input.Active == nil || *input.Active
+	return nil
+}
+
+func fillPromoTravelIDs(promo *models.PromoCode) {
+	promo.TravelIDs = make([]uint, 0, len(promo.Travels))
+	for _, t := range promo.Travels {
+		promo.TravelIDs = append(promo.TravelIDs, t.TravelID)
+	}
+}
+
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"h3-travel/models"
+	"h3-travel/money"
+	"h3-travel/payment"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefundAmount  = errors.New("montant de remboursement invalide")
+	ErrRefundExceedsBalance = errors.New("montant supérieur au solde remboursable")
+)
+
+// RefundOrder rembourse tout ou partie d'une commande : amount est exprimé en
+// unités mineures dans la devise de la commande (amount <= 0 : tout le solde
+// remboursable), en avoir sur le porte-monnaie du client si toWallet. Un
+// remboursement total d'une commande encore active la fait passer à l'état
+// "refunded" et annule les points de fidélité qu'elle avait rapportés. La part
+// sur carte est transmise au prestataire une fois la transaction validée.
+func RefundOrder(ctx context.Context, db *gorm.DB, provider payment.Provider, orderID uint, amount int64, toWallet bool, reason, actor string) (models.Order, error) {
+	var order models.Order
+	var refunds []models.Refund
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&order, orderID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrderNotFound
+			}
+			return err
+		}
+
+		refund := order.RefundableAmount
+		if amount > 0 {
+			refund = money.New(amount, order.PaidAmount.Currency)
+		}
+		var err error
+		if refunds, err = issueRefund(tx, &order, refund, toWallet, reason, actor); err != nil {
+			return err
+		}
+
+		if order.RefundableAmount.IsZero() && order.Statut.CanTransitionTo(models.StatusRefunded) {
+			if err := TransitionOrder(tx, &order, models.StatusRefunded, actor); err != nil {
+				return err
+			}
+			return revokeLoyaltyPoints(tx, order, time.Now())
+		}
+		return nil
+	})
+	if err != nil {
+		return order, err
+	}
+
+	settleRefunds(ctx, db, provider, refunds)
+	return loadOrderDetails(db, order.ID)
+}
+
+// issueRefund réserve le montant sur le solde de la commande (mise à jour
+// conditionnelle, sûre en cas de remboursements concurrents), le répartit
+// paiement par paiement, l'inscrit au registre et émet l'avoir correspondant.
+// La part payée avec le porte-monnaie y est recréditée, comme tout le montant
+// si toWallet ; le reste est enregistré en attente, à transmettre au prestataire
+// par settleRefunds une fois la transaction validée.
+func issueRefund(tx *gorm.DB, order *models.Order, amount money.Money, toWallet bool, reason, actor string) ([]models.Refund, error) {
+	if !amount.IsPositive() || amount.Currency != order.PaidAmount.Currency {
+		return nil, ErrInvalidRefundAmount
+	}
+
+	res := tx.Model(&models.Order{}).
+		Where("id = ? AND refunded_minor + ? <= paid_minor", order.ID, amount.Amount).
+		UpdateColumn("refunded_minor", gorm.Expr("refunded_minor + ?", amount.Amount))
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrRefundExceedsBalance
+	}
+	// Relu après la mise à jour : inclut les remboursements concurrents validés
+	// depuis le chargement de la commande, pour savoir si celui-ci la solde
+	var refunded int64
+	if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).
+		Pluck("refunded_minor", &refunded).Error; err != nil {
+		return nil, err
+	}
+	order.RefundedAmount = money.New(refunded, order.PaidAmount.Currency)
+	order.RefundableAmount = order.PaidAmount.Sub(order.RefundedAmount)
+
+	var payments []models.Payment
+	if err := tx.Where("order_id = ?", order.ID).Order("id").Find(&payments).Error; err != nil {
+		return nil, err
+	}
+
+	var refunds []models.Refund
+	remaining := amount
+	for _, p := range payments {
+		if !remaining.IsPositive() {
+			break
+		}
+
+		var alreadyRefunded int64
+		if err := tx.Model(&models.Refund{}).Where("payment_id = ?", p.ID).
+			Select("COALESCE(SUM(amount_minor), 0)").Scan(&alreadyRefunded).Error; err != nil {
+			return nil, err
+		}
+
+		part, err := remaining.CheckedMin(p.Amount.Sub(money.New(alreadyRefunded, p.Amount.Currency)))
+		if err != nil {
+			return nil, err
+		}
+		if !part.IsPositive() {
+			continue
+		}
+
+		refund := models.Refund{
+			OrderID:   order.ID,
+			PaymentID: p.ID,
+			Amount:    part,
+			Reason:    reason,
+			Actor:     actor,
+			ToWallet:  toWallet || p.Provider == models.WalletProvider,
+			Status:    models.RefundPending,
+		}
+		if refund.ToWallet {
+			entry, err := creditWallet(tx, order.UserID, part, models.WalletTransaction{
+				Kind:    models.WalletRefund,
+				OrderID: &order.ID,
+				Reason:  reason,
+			})
+			if err != nil {
+				return nil, err
+			}
+			refund.ProviderRefundID = fmt.Sprintf("wtx_%d", entry.ID)
+			refund.Status = models.RefundSucceeded
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, refund)
+		remaining = remaining.Sub(part)
+	}
+
+	if remaining.IsPositive() {
+		return nil, ErrRefundExceedsBalance
+	}
+	return refunds, issueCreditNote(tx, order, amount, reason, time.Now())
+}
+
+// settleRefunds transmet au prestataire les remboursements sur carte en
+// attente, hors de toute transaction : chacun passe à "succeeded" ou "failed".
+// Un remboursement refusé reste dû au client ; RetryFailedRefunds le relance.
+func settleRefunds(ctx context.Context, db *gorm.DB, provider payment.Provider, refunds []models.Refund) {
+	for i := range refunds {
+		refund := &refunds[i]
+		if refund.Status != models.RefundPending {
+			continue
+		}
+
+		var p models.Payment
+		if err := db.First(&p, refund.PaymentID).Error; err != nil {
+			log.Printf("Remboursement %d : paiement %d introuvable: %v", refund.ID, refund.PaymentID, err)
+			continue
+		}
+
+		providerRefundID, err := provider.Refund(ctx, p.TransactionID, refund.Amount.Amount)
+		if err != nil {
+			log.Printf("Remboursement %d de la commande %d refusé par le prestataire: %v", refund.ID, refund.OrderID, err)
+			refund.Status = models.RefundFailed
+		} else {
+			refund.Status = models.RefundSucceeded
+			refund.ProviderRefundID = providerRefundID
+		}
+		if err := db.Model(&models.Refund{}).Where("id = ? AND status = ?", refund.ID, models.RefundPending).
+			Updates(map[string]interface{}{"status": refund.Status, "provider_refund_id": refund.ProviderRefundID}).Error; err != nil {
+			log.Printf("Statut du remboursement %d non enregistré: %v", refund.ID, err)
+		}
+	}
+}
+
+// RetryFailedRefunds retransmet au prestataire les remboursements refusés de la
+// commande. Le passage de "failed" à "pending" est conditionnel : deux relances
+// simultanées ne remboursent pas deux fois.
+func RetryFailedRefunds(ctx context.Context, db *gorm.DB, provider payment.Provider, orderID uint) (models.Order, error) {
+	var failed []models.Refund
+	if err := db.Where("order_id = ? AND status = ?", orderID, models.RefundFailed).Order("id").Find(&failed).Error; err != nil {
+		return models.Order{}, err
+	}
+	if len(failed) == 0 {
+		if err := db.Select("id").First(&models.Order{}, orderID).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Order{}, ErrOrderNotFound
+		}
+	}
+
+	var retried []models.Refund
+	for _, refund := range failed {
+		res := db.Model(&models.Refund{}).Where("id = ? AND status = ?", refund.ID, models.RefundFailed).
+			Update("status", models.RefundPending)
+		if res.Error != nil {
+			return models.Order{}, res.Error
+		}
+		if res.RowsAffected == 1 {
+			refund.Status = models.RefundPending
+			retried = append(retried, refund)
+		}
+	}
+
+	settleRefunds(ctx, db, provider, retried)
+	return loadOrderDetails(db, orderID)
+}
+
+func loadOrderDetails(db *gorm.DB, orderID uint) (models.Order, error) {
+	var order models.Order
+	err := db.Preload("Items").Preload("Payments").Preload("Refunds").Preload("Invoices").First(&order, orderID).Error
+	return order, err
+}
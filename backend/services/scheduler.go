@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+// Scheduler exécute des jobs périodiques dans des goroutines, jusqu'à
+// l'annulation du contexte passé à Start.
+type Scheduler struct {
+	jobs []job
+	wg   sync.WaitGroup
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// Every enregistre un job ; à appeler avant Start.
+func (s *Scheduler) Every(name string, interval time.Duration, run func(ctx context.Context) error) {
+	s.jobs = append(s.jobs, job{name: name, interval: interval, run: run})
+}
+
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go func(j job) {
+			defer s.wg.Done()
+
+			ticker := time.NewTicker(j.interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					log.Printf("Job %s arrêté", j.name)
+					return
+				case <-ticker.C:
+					if err := j.run(ctx); err != nil {
+						log.Printf("Job %s en erreur: %v", j.name, err)
+					}
+				}
+			}
+		}(j)
+	}
+}
+
+// Wait bloque jusqu'à ce que tous les jobs aient terminé leur passage en cours.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"gorm.io/gorm"
+)
+
+// travelSearchSetup crée, une fois pour toutes, les configurations de recherche
+// plein texte insensibles aux accents (français et anglais) et la colonne
+// search_vector des travels. Colonne générée : PostgreSQL la recalcule à chaque
+// écriture du titre ou de la description, création comme mise à jour.
+var travelSearchSetup = []string{
+	`CREATE EXTENSION IF NOT EXISTS unaccent`,
+	`DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'h3_french') THEN
+		CREATE TEXT SEARCH CONFIGURATION h3_french (COPY = french);
+		ALTER TEXT SEARCH CONFIGURATION h3_french ALTER MAPPING FOR hword, hword_part, word WITH unaccent, french_stem;
+	END IF;
+	IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'h3_english') THEN
+		CREATE TEXT SEARCH CONFIGURATION h3_english (COPY = english);
+		ALTER TEXT SEARCH CONFIGURATION h3_english ALTER MAPPING FOR hword, hword_part, word WITH unaccent, english_stem;
+	END IF;
+END $$`,
+	`ALTER TABLE travels ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('h3_french', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('h3_english', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('h3_french', coalesce(description, '')), 'B') ||
+		setweight(to_tsvector('h3_english', coalesce(description, '')), 'B')
+	) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_travels_search_vector ON travels USING GIN (search_vector)`,
+}
+
+// SetupTravelSearch prépare l'index de recherche plein texte des travels
+// (PostgreSQL). À appeler après AutoMigrate ; sans effet s'il existe déjà.
+func SetupTravelSearch(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, sql := range travelSearchSetup {
+			if err := tx.Exec(sql).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// travelSearchQuery : la recherche de l'utilisateur, comprise en français ou en
+// anglais (syntaxe web : "phrase exacte", -exclu, or)
+func travelSearchQuery(q string) interface{} {
+	return gorm.Expr("(websearch_to_tsquery('h3_french', ?) || websearch_to_tsquery('h3_english', ?))", q, q)
+}
+
+// searchTravels restreint la requête aux travels correspondant à q.
+func searchTravels(query *gorm.DB, q string) *gorm.DB {
+	return query.Where("search_vector @@ ?", travelSearchQuery(q))
+}
+
+// Marqueurs provisoires du surlignage (zone d'usage privé d'Unicode) : ts_headline
+// les pose sur le texte brut, ils deviennent des balises <mark> après l'échappement.
+const (
+	markStart = "\ue000"
+	markStop  = "\ue001"
+)
+
+// htmlEscaped : l'expression échappée pour HTML. Les extraits surlignés sont du
+// HTML ; le texte saisi par les admins ne doit pas y introduire de balises.
+func htmlEscaped(expr string) string {
+	return "replace(replace(replace(replace(" + expr + ", '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '\"', '&quot;')"
+}
+
+// searchHeadline : extrait surligné de la colonne, analysé avec la configuration
+// (française, sinon anglaise) qui l'a trouvée, puis échappé pour HTML. Attend
+// trois fois la recherche en paramètre.
+func searchHeadline(column, options string) string {
+	text := "translate(" + column + ", '" + markStart + markStop + "', '')"
+	options = `StartSel="` + markStart + `", StopSel="` + markStop + `", ` + options
+	headline := func(config string) string {
+		return "ts_headline('" + config + "', " + text + ", websearch_to_tsquery('" + config + "', ?), '" + options + "')"
+	}
+	matched := "CASE WHEN to_tsvector('h3_french', " + text + ") @@ websearch_to_tsquery('h3_french', ?) " +
+		"THEN " + headline("h3_french") + " ELSE " + headline("h3_english") + " END"
+	return "replace(replace(" + htmlEscaped(matched) + ", '" + markStart + "', '<mark>'), '" + markStop + "', '</mark>')"
+}
+
+// withSearchHighlights ajoute aux colonnes lues la pertinence (search_rank) et
+// les extraits surlignés du titre et de la description, échappés pour HTML
+// (seules les balises <mark> sont à interpréter).
+func withSearchHighlights(query *gorm.DB, q string) *gorm.DB {
+	return query.Select(
+		"travels.*, ts_rank(search_vector, ?) AS search_rank, "+
+			searchHeadline("title", "HighlightAll=true")+" AS highlight, "+
+			searchHeadline("description", "MinWords=10, MaxWords=30, MaxFragments=2")+" AS snippet",
+		travelSearchQuery(q), q, q, q, q, q, q)
+}
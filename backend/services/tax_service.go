@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"h3-travel/models"
+	"h3-travel/money"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrTaxCategoryUnknown = errors.New("catégorie de TVA inconnue")
+
+// loadTaxRates retourne le taux de chaque catégorie : celui enregistré par un
+// admin, à défaut le taux légal par défaut.
+func loadTaxRates(db *gorm.DB) (map[string]int, error) {
+	rates := make(map[string]int, len(models.DefaultTaxRates))
+	for category, rate := range models.DefaultTaxRates {
+		rates[category] = rate
+	}
+
+	var stored []models.TaxRate
+	if err := db.Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	for _, rate := range stored {
+		rates[rate.Category] = rate.Rate
+	}
+	return rates, nil
+}
+
+// applyTaxes calcule la TVA comprise dans chaque ligne (remise déduite) et dans
+// le total de la commande. Le taux de chaque ligne doit déjà être renseigné.
+func applyTaxes(order *models.Order) {
+	order.Tax = money.Zero(order.Total.Currency)
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.Tax = item.LineTotal().IncludedTax(item.TaxRate)
+		order.Tax = order.Tax.Add(item.Tax)
+	}
+	order.Net = order.Total.Sub(order.Tax)
+}
+
+// taxBreakdown ventile les lignes de la commande par taux de TVA, par taux croissant.
+func taxBreakdown(order models.Order) []models.TaxBreakdown {
+	byRate := make(map[int]*models.TaxBreakdown)
+	var rates []int
+	for _, item := range order.Items {
+		line, ok := byRate[item.TaxRate]
+		if !ok {
+			zero := money.Zero(order.Total.Currency)
+			line = &models.TaxBreakdown{Rate: item.TaxRate, Net: zero, Tax: zero, Gross: zero}
+			byRate[item.TaxRate] = line
+			rates = append(rates, item.TaxRate)
+		}
+		line.Gross = line.Gross.Add(item.LineTotal())
+		line.Tax = line.Tax.Add(item.Tax)
+		line.Net = line.Gross.Sub(line.Tax)
+	}
+	sort.Ints(rates)
+
+	breakdown := make([]models.TaxBreakdown, 0, len(rates))
+	for _, rate := range rates {
+		breakdown = append(breakdown, *byRate[rate])
+	}
+	return breakdown
+}
+
+func ListTaxRates(db *gorm.DB) ([]models.TaxRate, error) {
+	rates, err := loadTaxRates(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []models.TaxRate
+	if err := db.Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	updatedAt := make(map[string]time.Time, len(stored))
+	for _, rate := range stored {
+		updatedAt[rate.Category] = rate.UpdatedAt
+	}
+
+	list := make([]models.TaxRate, 0, len(rates))
+	for category, rate := range rates {
+		list = append(list, models.TaxRate{Category: category, Rate: rate, UpdatedAt: updatedAt[category]})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Category < list[j].Category })
+	return list, nil
+}
+
+// SetTaxRate remplace le taux d'une catégorie connue. Les commandes existantes
+// gardent le taux figé à l'achat.
+func SetTaxRate(db *gorm.DB, category string, rate int) (models.TaxRate, error) {
+	taxRate := models.TaxRate{Category: category, Rate: rate, UpdatedAt: time.Now()}
+	if _, ok := models.DefaultTaxRates[category]; !ok {
+		return taxRate, ErrTaxCategoryUnknown
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "category"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"}),
+	}).Create(&taxRate).Error
+	return taxRate, err
+}
+
+// ValidTaxCategory : catégorie vide (celle par défaut) ou connue
+func ValidTaxCategory(category string) bool {
+	if category == "" {
+		return true
+	}
+	_, ok := models.DefaultTaxRates[category]
+	return ok
+}
@@ -0,0 +1,478 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"h3-travel/models"
+	"h3-travel/money"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTravelInvalid       = errors.New("travel invalide")
+	ErrTravelTypeImmutable = errors.New("le type d'un travel ne peut pas être modifié")
+	ErrStockOverCapacity   = errors.New("le stock ne peut pas dépasser la capacité")
+	ErrStockChanged        = errors.New("le stock a changé pendant la mise à jour")
+)
+
+// WithTravelDetails charge les attributs propres au type de chaque travel : vol,
+// hôtel et ses chambres, composants d'un séjour packagé.
+func WithTravelDetails(db *gorm.DB) *gorm.DB {
+	return db.Preload("Flight").
+		Preload("Hotel.RoomTypes").
+		Preload("Components", func(tx *gorm.DB) *gorm.DB { return tx.Order("position") }).
+		Preload("Components.Travel.Flight").
+		Preload("Components.Travel.Hotel.RoomTypes")
+}
+
+const (
+	defaultTravelPageSize = 20
+	defaultTravelSort     = "created_at"
+)
+
+// travelSortColumns : clés de tri acceptées et colonne correspondante ; un "-"
+// devant la clé trie par ordre décroissant. S'y ajoute relevance, la plus
+// pertinente d'abord, qui suppose une recherche.
+var travelSortColumns = map[string]string{
+	"price":          "price_minor",
+	"created_at":     "created_at",
+	"title":          "title",
+	"departure_date": "departure_date",
+	"stock":          "stock",
+}
+
+// Facettes du catalogue : chacune est aussi un critère de recherche
+const (
+	facetType         = "type"
+	facetPrice        = "price"
+	facetCountry      = "country"
+	facetAvailability = "availability"
+)
+
+// priceCurrency : devise du filtre de prix et des tranches de prix (EUR par défaut)
+func priceCurrency(filter models.TravelFilter) (string, error) {
+	if filter.PriceCurrency == "" {
+		return money.DefaultCurrency, nil
+	}
+	currency, err := money.NormalizeCurrency(filter.PriceCurrency)
+	if err != nil {
+		return "", fmt.Errorf("%w : devise %q", ErrTravelInvalid, filter.PriceCurrency)
+	}
+	return currency, nil
+}
+
+// catalogQuery : travels correspondant à la recherche et aux critères qui ne
+// sont pas des facettes (statut, date de création).
+func catalogQuery(db *gorm.DB, filter models.TravelFilter) *gorm.DB {
+	query := db.Model(&models.Travel{})
+	if q := strings.TrimSpace(filter.Q); q != "" {
+		query = searchTravels(query, q)
+	}
+	switch filter.Active {
+	case "all":
+	case "false":
+		query = query.Where("active = ?", false)
+	default:
+		query = query.Where("active = ?", true)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		query = query.Where("created_at >= ?", filter.CreatedFrom)
+	}
+	if !filter.CreatedTo.IsZero() {
+		query = query.Where("created_at < ?", filter.CreatedTo.AddDate(0, 0, 1))
+	}
+	return query
+}
+
+// inStockExpr : le travel a encore des places à la date donnée en paramètre. Un
+// travel daté se vend par départ : il lui faut un départ à venir non complet.
+const inStockExpr = "(EXISTS (SELECT 1 FROM departures WHERE departures.travel_id = travels.id AND departures.deleted_at IS NULL AND departures.start_date > ? AND departures.stock > 0)" +
+	" OR (travels.stock > 0 AND NOT EXISTS (SELECT 1 FROM departures WHERE departures.travel_id = travels.id AND departures.deleted_at IS NULL)))"
+
+// facetFilters restreint la requête aux critères de facettes du filtre, sauf
+// celui de la facette except : ses comptes ignorent la valeur déjà choisie.
+func facetFilters(db, query *gorm.DB, filter models.TravelFilter, currency, except string, now time.Time) *gorm.DB {
+	if filter.Type != "" && except != facetType {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if (filter.MinPrice != nil || filter.MaxPrice != nil) && except != facetPrice {
+		query = query.Where("price_currency = ?", currency)
+		if filter.MinPrice != nil {
+			query = query.Where("price_minor >= ?", *filter.MinPrice)
+		}
+		if filter.MaxPrice != nil {
+			query = query.Where("price_minor <= ?", *filter.MaxPrice)
+		}
+	}
+	if filter.Country != "" && except != facetCountry {
+		query = query.Where("id IN (?)", destinations(db).Select("travel_id").Where("country = ?", strings.ToUpper(filter.Country)))
+	}
+	if filter.InStock && except != facetAvailability {
+		query = query.Where(inStockExpr, now)
+	}
+	return query
+}
+
+// ListTravels retourne une page du catalogue correspondant au filtre, avec ses
+// attributs de type, et la position de la page parmi les résultats. Sans
+// précision, seuls les travels actifs sont retenus ; le filtre de prix porte sur
+// le prix de catalogue, avant règles de tarification. Avec une recherche, les
+// résultats sont triés par pertinence et portent leurs extraits surlignés.
+func ListTravels(db *gorm.DB, filter models.TravelFilter) ([]models.Travel, models.Pagination, error) {
+	q := strings.TrimSpace(filter.Q)
+	sort := filter.Sort
+	if sort == "" {
+		sort = defaultTravelSort
+		if q != "" {
+			sort = "relevance"
+		}
+	}
+	column, direction := "search_rank DESC", ""
+	if sort == "relevance" {
+		if q == "" {
+			return nil, models.Pagination{}, fmt.Errorf("%w : le tri par pertinence suppose une recherche (q)", ErrTravelInvalid)
+		}
+	} else {
+		if strings.HasPrefix(sort, "-") {
+			sort, direction = sort[1:], " DESC"
+		}
+		var ok bool
+		if column, ok = travelSortColumns[sort]; !ok {
+			return nil, models.Pagination{}, fmt.Errorf("%w : tri %q inconnu", ErrTravelInvalid, filter.Sort)
+		}
+	}
+
+	currency, err := priceCurrency(filter)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	query := facetFilters(db, catalogQuery(db, filter), filter, currency, "", time.Now())
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	page, pageSize := filter.Page, filter.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultTravelPageSize
+	}
+
+	if q != "" {
+		query = withSearchHighlights(query, q)
+	}
+	travels := []models.Travel{}
+	err = WithTravelDetails(query).
+		Order(column + direction).Order("id" + direction).
+		Limit(pageSize).Offset((page - 1) * pageSize).
+		Find(&travels).Error
+	return travels, models.NewPagination(page, pageSize, total), err
+}
+
+// isCode : exactement n lettres majuscules (codes IATA, ISO 3166-1...)
+func isCode(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func invalidTravel(format string, args ...interface{}) error {
+	return fmt.Errorf("%w : %s", ErrTravelInvalid, fmt.Sprintf(format, args...))
+}
+
+func validateFlight(flight *models.FlightDetails) error {
+	flight.Origin = strings.ToUpper(strings.TrimSpace(flight.Origin))
+	flight.Destination = strings.ToUpper(strings.TrimSpace(flight.Destination))
+	flight.Carrier = strings.TrimSpace(flight.Carrier)
+	flight.FlightNumber = strings.ToUpper(strings.TrimSpace(flight.FlightNumber))
+
+	switch {
+	case !isCode(flight.Origin, 3) || !isCode(flight.Destination, 3):
+		return invalidTravel("aéroports de départ et d'arrivée requis (codes IATA à 3 lettres)")
+	case flight.Origin == flight.Destination:
+		return invalidTravel("les aéroports de départ et d'arrivée doivent être différents")
+	case flight.Carrier == "" || len(flight.Carrier) > 100:
+		return invalidTravel("compagnie requise (100 caractères au plus)")
+	case len(flight.FlightNumber) > 10:
+		return invalidTravel("numéro de vol trop long")
+	case flight.DepartureAt.IsZero() || !flight.ArrivalAt.After(flight.DepartureAt):
+		return invalidTravel("l'arrivée doit suivre le départ")
+	}
+	return nil
+}
+
+func validateHotel(hotel *models.HotelDetails) error {
+	hotel.Address = strings.TrimSpace(hotel.Address)
+	hotel.City = strings.TrimSpace(hotel.City)
+	hotel.Country = strings.ToUpper(strings.TrimSpace(hotel.Country))
+
+	switch {
+	case hotel.Address == "" || len(hotel.Address) > 255 || hotel.City == "" || len(hotel.City) > 100:
+		return invalidTravel("adresse et ville de l'hôtel requises")
+	case !isCode(hotel.Country, 2):
+		return invalidTravel("pays de l'hôtel requis (code ISO à 2 lettres)")
+	case hotel.Stars < 1 || hotel.Stars > 5:
+		return invalidTravel("classement de l'hôtel compris entre 1 et 5 étoiles")
+	case hotel.Nights < 1:
+		return invalidTravel("au moins une nuit")
+	case len(hotel.RoomTypes) == 0:
+		return invalidTravel("au moins un type de chambre")
+	}
+	for i := range hotel.RoomTypes {
+		room := &hotel.RoomTypes[i]
+		room.Name = strings.TrimSpace(room.Name)
+		if room.Name == "" || len(room.Name) > 100 || len(room.Description) > 255 || room.Capacity < 1 {
+			return invalidTravel("type de chambre %d : nom et capacité requis", i+1)
+		}
+	}
+	return nil
+}
+
+// validatePackage vérifie que le séjour réunit au moins un vol et un hôtel du
+// catalogue et charge chacun d'eux dans son composant.
+func validatePackage(db *gorm.DB, travel *models.Travel) error {
+	ids := make([]uint, 0, len(travel.Components))
+	seen := make(map[uint]bool, len(travel.Components))
+	for _, component := range travel.Components {
+		if component.TravelID == 0 || seen[component.TravelID] || component.TravelID == travel.ID {
+			return invalidTravel("composants du séjour invalides ou en double")
+		}
+		seen[component.TravelID] = true
+		ids = append(ids, component.TravelID)
+	}
+
+	var found []models.Travel
+	if len(ids) > 0 {
+		if err := db.Preload("Flight").Preload("Hotel.RoomTypes").Where("id IN ?", ids).Find(&found).Error; err != nil {
+			return err
+		}
+	}
+	byID := make(map[uint]models.Travel, len(found))
+	for _, component := range found {
+		byID[component.ID] = component
+	}
+
+	var flights, hotels int
+	for i := range travel.Components {
+		component, ok := byID[travel.Components[i].TravelID]
+		if !ok {
+			return invalidTravel("composant %d introuvable", travel.Components[i].TravelID)
+		}
+		switch component.Type {
+		case models.TravelFlight:
+			flights++
+		case models.TravelHotel:
+			hotels++
+		default:
+			return invalidTravel("le composant %d n'est ni un vol ni un hôtel", component.ID)
+		}
+		travel.Components[i].Position = i
+		travel.Components[i].Travel = &component
+	}
+	if flights == 0 || hotels == 0 {
+		return invalidTravel("un séjour comprend au moins un vol et un hôtel")
+	}
+	return nil
+}
+
+// validateTravelDetails vérifie que le travel porte les attributs de son type,
+// et seulement ceux-là.
+func validateTravelDetails(db *gorm.DB, travel *models.Travel) error {
+	if !travel.Type.Valid() {
+		return invalidTravel("type requis : flight, hotel ou package")
+	}
+	if (travel.Flight != nil) != (travel.Type == models.TravelFlight) {
+		return invalidTravel("les attributs de vol sont requis pour un travel de type flight, et lui sont réservés")
+	}
+	if (travel.Hotel != nil) != (travel.Type == models.TravelHotel) {
+		return invalidTravel("les attributs d'hôtel sont requis pour un travel de type hotel, et lui sont réservés")
+	}
+	if len(travel.Components) > 0 && travel.Type != models.TravelPackage {
+		return invalidTravel("seul un travel de type package a des composants")
+	}
+
+	switch travel.Type {
+	case models.TravelFlight:
+		return validateFlight(travel.Flight)
+	case models.TravelHotel:
+		return validateHotel(travel.Hotel)
+	default:
+		return validatePackage(db, travel)
+	}
+}
+
+// defaultDeparture renseigne la date de départ, qui sert à la politique
+// d'annulation, à partir du vol ou du premier vol du séjour si elle n'est pas saisie.
+func defaultDeparture(travel *models.Travel) {
+	if travel.DepartureDate != nil {
+		return
+	}
+	if travel.Flight != nil {
+		departure := travel.Flight.DepartureAt
+		travel.DepartureDate = &departure
+	}
+	for _, component := range travel.Components {
+		if flight := component.Travel.Flight; flight != nil &&
+			(travel.DepartureDate == nil || flight.DepartureAt.Before(*travel.DepartureDate)) {
+			departure := flight.DepartureAt
+			travel.DepartureDate = &departure
+		}
+	}
+}
+
+// saveTravelDetails enregistre les attributs propres au type du travel
+func saveTravelDetails(tx *gorm.DB, travel *models.Travel) error {
+	switch {
+	case travel.Flight != nil:
+		travel.Flight.TravelID = travel.ID
+		return tx.Create(travel.Flight).Error
+	case travel.Hotel != nil:
+		travel.Hotel.TravelID = travel.ID
+		if err := tx.Omit("RoomTypes").Create(travel.Hotel).Error; err != nil {
+			return err
+		}
+		for i := range travel.Hotel.RoomTypes {
+			travel.Hotel.RoomTypes[i].ID = 0
+			travel.Hotel.RoomTypes[i].TravelID = travel.ID
+		}
+		return tx.Create(&travel.Hotel.RoomTypes).Error
+	case len(travel.Components) > 0:
+		for i := range travel.Components {
+			travel.Components[i].ID = 0
+			travel.Components[i].PackageID = travel.ID
+		}
+		return tx.Omit("Travel").Create(&travel.Components).Error
+	}
+	return nil
+}
+
+// CreateTravel valide les attributs du type du travel et l'enregistre avec eux.
+func CreateTravel(db *gorm.DB, travel *models.Travel) error {
+	if err := validateTravelDetails(db, travel); err != nil {
+		return err
+	}
+	defaultDeparture(travel)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Flight", "Hotel", "Components").Create(travel).Error; err != nil {
+			return err
+		}
+		return saveTravelDetails(tx, travel)
+	})
+}
+
+// UpdateTravelDetails remplace les attributs propres au type du travel s'ils
+// sont fournis dans input. Le type lui-même ne change pas.
+func UpdateTravelDetails(db *gorm.DB, travel *models.Travel, input models.Travel) error {
+	if input.Type != "" && input.Type != travel.Type {
+		return ErrTravelTypeImmutable
+	}
+	if input.Flight == nil && input.Hotel == nil && input.Components == nil {
+		return nil
+	}
+
+	travel.Flight, travel.Hotel, travel.Components = input.Flight, input.Hotel, input.Components
+	if err := validateTravelDetails(db, travel); err != nil {
+		return err
+	}
+	if input.DepartureDate == nil && travel.Type != models.TravelHotel {
+		travel.DepartureDate = nil
+		defaultDeparture(travel)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.FlightDetails{}, &models.HotelRoomType{}, &models.HotelDetails{}} {
+			if err := tx.Where("travel_id = ?", travel.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("package_id = ?", travel.ID).Delete(&models.PackageComponent{}).Error; err != nil {
+			return err
+		}
+		if travel.DepartureDate != nil {
+			if err := tx.Model(travel).UpdateColumn("departure_date", travel.DepartureDate).Error; err != nil {
+				return err
+			}
+		}
+		return saveTravelDetails(tx, travel)
+	})
+}
+
+// UpdateTravel met à jour un travel, ses attributs de type et son stock dans
+// une seule transaction, et retourne le stock d'avant. Le nouveau stock
+// s'applique en variation : une commande passée entretemps garde sa place.
+func UpdateTravel(db *gorm.DB, travelID uint, input models.Travel) (models.Travel, int, error) {
+	var travel models.Travel
+	var previousStock int
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&travel, travelID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTravelNotFound
+			}
+			return err
+		}
+		previousStock = travel.Stock
+
+		if err := UpdateTravelDetails(tx, &travel, input); err != nil {
+			return err
+		}
+		if err := updateTravelStock(tx, &travel, input); err != nil {
+			return err
+		}
+		// La politique d'annulation se modifie via /travels/:id/cancellation-policy
+		return tx.Model(&travel).Omit(clause.Associations, "stock", "capacity").Updates(input).Error
+	})
+
+	return travel, previousStock, err
+}
+
+// updateTravelStock porte le stock à input.Stock, par un décrément ou un
+// incrément conditionnel plutôt qu'une valeur absolue. La capacité (places
+// vendues plus stock) prend input.Capacity si elle est fournie, sinon elle suit
+// la variation du stock.
+func updateTravelStock(tx *gorm.DB, travel *models.Travel, input models.Travel) error {
+	delta := 0
+	if input.Stock != 0 {
+		delta = input.Stock - travel.Stock
+	}
+	if delta == 0 && input.Capacity == 0 {
+		return nil
+	}
+
+	// Un travel pas encore repris par BackfillTravelCapacity part de son stock
+	var capacity interface{} = gorm.Expr("CASE WHEN capacity = 0 THEN stock ELSE capacity END + ?", delta)
+	query := tx.Model(&models.Travel{}).Where("id = ? AND stock + ? >= 0", travel.ID, delta)
+	if input.Capacity != 0 {
+		if travel.Stock+delta > input.Capacity {
+			return ErrStockOverCapacity
+		}
+		capacity = input.Capacity
+		query = query.Where("stock + ? <= ?", delta, input.Capacity)
+	}
+
+	res := query.Updates(map[string]interface{}{
+		"stock":    gorm.Expr("stock + ?", delta),
+		"capacity": capacity,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrStockChanged
+	}
+	return tx.Select("stock", "capacity").First(travel, travel.ID).Error
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"h3-travel/models"
+	"h3-travel/notify"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSeatsAvailable  = errors.New("des places sont disponibles")
+	ErrAlreadyWaitlist = errors.New("déjà inscrit sur la liste d'attente")
+	ErrNotOnWaitlist   = errors.New("pas inscrit sur la liste d'attente")
+	ErrWaitlistDated   = errors.New("ce travel se réserve par départ : pas de liste d'attente")
+)
+
+// JoinWaitlist inscrit l'utilisateur sur la liste d'attente d'un travel qui n'a
+// plus assez de places pour la quantité demandée. La liste porte sur le stock du
+// travel : un travel qui se réserve par départ n'en a pas.
+func JoinWaitlist(db *gorm.DB, userID, travelID uint, quantity int) (models.WaitlistEntry, error) {
+	if quantity < 1 {
+		quantity = 1
+	}
+	entry := models.WaitlistEntry{TravelID: travelID, UserID: userID, Quantity: quantity, Status: models.WaitlistWaiting}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var travel models.Travel
+		if err := tx.First(&travel, travelID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTravelNotFound
+			}
+			return err
+		}
+		if !travel.Active {
+			return ErrTravelUnavailable
+		}
+		var dated int64
+		if err := tx.Model(&models.Departure{}).Where("travel_id = ?", travelID).Count(&dated).Error; err != nil {
+			return err
+		}
+		if dated > 0 {
+			return ErrWaitlistDated
+		}
+		if travel.Stock >= quantity {
+			return ErrSeatsAvailable
+		}
+
+		var count int64
+		if err := tx.Model(&models.WaitlistEntry{}).
+			Where("travel_id = ? AND user_id = ? AND status IN ?", travelID, userID,
+				[]models.WaitlistStatus{models.WaitlistWaiting, models.WaitlistOffered}).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyWaitlist
+		}
+
+		return tx.Create(&entry).Error
+	})
+	if err != nil {
+		return entry, err
+	}
+
+	entry.Position, err = waitlistPosition(db, entry)
+	return entry, err
+}
+
+// LeaveWaitlist retire l'utilisateur de la liste d'attente d'un travel.
+func LeaveWaitlist(db *gorm.DB, userID, travelID uint) error {
+	res := db.Model(&models.WaitlistEntry{}).
+		Where("travel_id = ? AND user_id = ? AND status = ?", travelID, userID, models.WaitlistWaiting).
+		Update("status", models.WaitlistCancelled)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotOnWaitlist
+	}
+	return nil
+}
+
+func waitlistPosition(db *gorm.DB, entry models.WaitlistEntry) (int, error) {
+	var ahead int64
+	err := db.Model(&models.WaitlistEntry{}).
+		Where("travel_id = ? AND status = ? AND id < ?", entry.TravelID, models.WaitlistWaiting, entry.ID).
+		Count(&ahead).Error
+	return int(ahead) + 1, err
+}
+
+// PromoteWaitlist propose les places libérées d'un travel aux inscrits, dans
+// l'ordre d'inscription : chacun reçoit une réservation limitée dans le temps
+// et une notification. La promotion s'arrête dès que la personne en tête de
+// liste ne peut pas être servie. Chaque réservation proposée est valable offerTTL.
+func PromoteWaitlist(ctx context.Context, db *gorm.DB, travelID uint, offerTTL time.Duration) error {
+	for {
+		entry, promoted, err := promoteNext(db, travelID, offerTTL)
+		if err != nil || !promoted {
+			return err
+		}
+
+		notify.Send(ctx, entry.UserID, "Une place s'est libérée",
+			fmt.Sprintf("Une réservation de %d place(s) vous attend (commande %d). Payez-la avant le %s pour la conserver.",
+				entry.Quantity, *entry.OrderID, entry.OfferExpiresAt.Format("02/01/2006 15:04")))
+	}
+}
+
+func promoteNext(db *gorm.DB, travelID uint, ttl time.Duration) (models.WaitlistEntry, bool, error) {
+	var entry models.WaitlistEntry
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("travel_id = ? AND status = ?", travelID, models.WaitlistWaiting).
+			Order("id").First(&entry).Error; err != nil {
+			return err
+		}
+
+		order, err := createHold(tx, entry.UserID, models.CreateOrderInput{
+			Items: []models.OrderItemInput{{TravelID: travelID, Quantity: entry.Quantity}},
+		}, ttl, models.ActorSystem)
+		if err != nil {
+			return err
+		}
+
+		// Conditionnel : si une autre instance a déjà servi cette inscription, on annule tout
+		res := tx.Model(&models.WaitlistEntry{}).
+			Where("id = ? AND status = ?", entry.ID, models.WaitlistWaiting).
+			Updates(map[string]interface{}{
+				"status":           models.WaitlistOffered,
+				"order_id":         order.ID,
+				"offer_expires_at": order.ExpiresAt,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errWaitlistRace
+		}
+
+		entry.Status = models.WaitlistOffered
+		entry.OrderID = &order.ID
+		entry.OfferExpiresAt = order.ExpiresAt
+		return nil
+	})
+
+	switch {
+	case err == nil:
+		ClearTravelFacets()
+		return entry, true, nil
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, ErrTravelUnavailable):
+		// personne en attente, ou pas assez de places pour la tête de liste
+		return entry, false, nil
+	case errors.Is(err, errWaitlistRace):
+		return promoteNext(db, travelID, ttl)
+	default:
+		return entry, false, err
+	}
+}
+
+var errWaitlistRace = errors.New("inscription déjà servie")
+
+// settleWaitlistOffer clôt l'offre de liste d'attente liée à une réservation, s'il y en a une.
+func settleWaitlistOffer(tx *gorm.DB, orderID uint, status models.WaitlistStatus) error {
+	return tx.Model(&models.WaitlistEntry{}).
+		Where("order_id = ? AND status = ?", orderID, models.WaitlistOffered).
+		Update("status", status).Error
+}
+
+// promoteWaitlists relance la liste d'attente des travels dont des places ont été libérées.
+func promoteWaitlists(ctx context.Context, db *gorm.DB, items []models.OrderItem, offerTTL time.Duration) {
+	seen := make(map[uint]bool)
+	for _, item := range items {
+		// La liste d'attente porte sur le stock du travel : un travel daté n'en a pas
+		if seen[item.TravelID] || item.DepartureID != nil {
+			continue
+		}
+		seen[item.TravelID] = true
+
+		if err := PromoteWaitlist(ctx, db, item.TravelID, offerTTL); err != nil {
+			log.Printf("Promotion de la liste d'attente du travel %d impossible: %v", item.TravelID, err)
+		}
+	}
+}
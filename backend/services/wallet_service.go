@@ -0,0 +1,291 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"h3-travel/models"
+	"h3-travel/money"
+	"math/big"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrWalletInsufficient = errors.New("solde du porte-monnaie insuffisant")
+	ErrGiftCardNotFound   = errors.New("carte cadeau inconnue")
+	ErrGiftCardInvalid    = errors.New("carte cadeau invalide")
+	ErrGiftCardRedeemed   = errors.New("carte cadeau déjà utilisée")
+	ErrGiftCardExpired    = errors.New("carte cadeau expirée")
+)
+
+const (
+	walletHistoryLimit = 50
+	// Sans 0/O ni 1/I : un code se recopie sans ambiguïté
+	giftCardAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	giftCardLength   = 16
+)
+
+// creditWallet ajoute amount au porte-monnaie de l'utilisateur dans sa devise
+// (créé au besoin) et l'inscrit au registre.
+func creditWallet(tx *gorm.DB, userID uint, amount money.Money, entry models.WalletTransaction) (models.WalletTransaction, error) {
+	if err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "currency"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"balance":    gorm.Expr("wallets.balance + ?", amount.Amount),
+			"updated_at": time.Now(),
+		}),
+	}).Create(&models.Wallet{UserID: userID, Currency: amount.Currency, Balance: amount.Amount}).Error; err != nil {
+		return entry, err
+	}
+	return recordWalletTransaction(tx, userID, amount, entry)
+}
+
+// debitWallet retire amount du porte-monnaie. La mise à jour est conditionnelle
+// (solde >= montant) : deux paiements concurrents ne peuvent pas rendre le solde négatif.
+func debitWallet(tx *gorm.DB, userID uint, amount money.Money, entry models.WalletTransaction) (models.WalletTransaction, error) {
+	res := tx.Model(&models.Wallet{}).
+		Where("user_id = ? AND currency = ? AND balance >= ?", userID, amount.Currency, amount.Amount).
+		UpdateColumns(map[string]interface{}{
+			"balance":    gorm.Expr("balance - ?", amount.Amount),
+			"updated_at": time.Now(),
+		})
+	if res.Error != nil {
+		return entry, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return entry, ErrWalletInsufficient
+	}
+	return recordWalletTransaction(tx, userID, money.New(-amount.Amount, amount.Currency), entry)
+}
+
+// recordWalletTransaction inscrit le mouvement au registre avec le solde qui en
+// résulte, relu sur la ligne du porte-monnaie que la transaction vient de verrouiller.
+func recordWalletTransaction(tx *gorm.DB, userID uint, amount money.Money, entry models.WalletTransaction) (models.WalletTransaction, error) {
+	var balance int64
+	if err := tx.Model(&models.Wallet{}).Where("user_id = ? AND currency = ?", userID, amount.Currency).
+		Pluck("balance", &balance).Error; err != nil {
+		return entry, err
+	}
+
+	entry.UserID = userID
+	entry.Amount = amount
+	entry.BalanceAfter = money.New(balance, amount.Currency)
+	return entry, tx.Create(&entry).Error
+}
+
+// payFromWallet prélève sur le porte-monnaie la part demandée de la commande qui
+// vient d'être créée, plafonnée à son total. Une commande entièrement réglée
+// ainsi, ou gratuite (code promo à 100 %), est payée sur-le-champ, sans passer
+// par la carte.
+func payFromWallet(tx *gorm.DB, order *models.Order, requested int64, actor string) error {
+	amount := money.New(requested, order.Total.Currency).Min(order.Total)
+	if amount.IsPositive() {
+		if _, err := debitWallet(tx, order.UserID, amount, models.WalletTransaction{
+			Kind:    models.WalletPayment,
+			OrderID: &order.ID,
+			Reason:  fmt.Sprintf("Commande n° %d", order.ID),
+		}); err != nil {
+			return err
+		}
+		order.WalletAmount = amount
+		if err := tx.Model(order).UpdateColumns(map[string]interface{}{
+			"wallet_minor":    amount.Amount,
+			"wallet_currency": amount.Currency,
+		}).Error; err != nil {
+			return err
+		}
+		order.PaymentProvider = models.WalletProvider
+	}
+	if order.WalletAmount.Amount < order.Total.Amount {
+		return nil
+	}
+
+	if err := TransitionOrder(tx, order, models.StatusPaid, actor); err != nil {
+		return err
+	}
+	order.PaidAmount = order.Total
+	if err := tx.Model(order).Updates(map[string]interface{}{
+		"payment_provider": order.PaymentProvider,
+		"paid_minor":       order.PaidAmount.Amount,
+		"paid_currency":    order.PaidAmount.Currency,
+	}).Error; err != nil {
+		return err
+	}
+	if err := recordWalletPayment(tx, order); err != nil {
+		return err
+	}
+	if err := awardLoyaltyPoints(tx, *order, time.Now()); err != nil {
+		return err
+	}
+	return issueInvoice(tx, order, time.Now())
+}
+
+// recordWalletPayment inscrit la part réglée avec le porte-monnaie parmi les
+// paiements de la commande, au moment où celle-ci est payée.
+func recordWalletPayment(tx *gorm.DB, order *models.Order) error {
+	if !order.WalletAmount.IsPositive() {
+		return nil
+	}
+
+	var entryID uint
+	if err := tx.Model(&models.WalletTransaction{}).
+		Where("order_id = ? AND kind = ?", order.ID, models.WalletPayment).
+		Order("id DESC").Limit(1).Pluck("id", &entryID).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.Payment{
+		OrderID:       order.ID,
+		Provider:      models.WalletProvider,
+		TransactionID: fmt.Sprintf("wtx_%d", entryID),
+		Amount:        order.WalletAmount,
+	}).Error
+}
+
+// releaseWallet rend au porte-monnaie la part prélevée sur une commande jamais
+// payée (expirée ou annulée).
+func releaseWallet(tx *gorm.DB, order models.Order) error {
+	if !order.WalletAmount.IsPositive() {
+		return nil
+	}
+	_, err := creditWallet(tx, order.UserID, order.WalletAmount, models.WalletTransaction{
+		Kind:    models.WalletRelease,
+		OrderID: &order.ID,
+		Reason:  fmt.Sprintf("Commande n° %d non payée", order.ID),
+	})
+	return err
+}
+
+// GetWallet retourne les soldes de l'utilisateur et les dernières écritures de son registre.
+func GetWallet(db *gorm.DB, userID uint) (models.WalletSummary, error) {
+	summary := models.WalletSummary{Balances: []money.Money{}, Transactions: []models.WalletTransaction{}}
+
+	var wallets []models.Wallet
+	if err := db.Where("user_id = ?", userID).Order("currency").Find(&wallets).Error; err != nil {
+		return summary, err
+	}
+	for _, wallet := range wallets {
+		summary.Balances = append(summary.Balances, money.New(wallet.Balance, wallet.Currency))
+	}
+
+	err := db.Where("user_id = ?", userID).Order("id DESC").Limit(walletHistoryLimit).Find(&summary.Transactions).Error
+	return summary, err
+}
+
+// --- Cartes cadeaux ---
+
+func normalizeGiftCardCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToUpper(code))
+}
+
+func hashGiftCardCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeGiftCardCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// newGiftCardCode : 16 caractères aléatoires groupés par 4 (XXXX-XXXX-XXXX-XXXX)
+func newGiftCardCode() (string, error) {
+	var b strings.Builder
+	size := big.NewInt(int64(len(giftCardAlphabet)))
+	for i := 0; i < giftCardLength; i++ {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(giftCardAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// IssueGiftCard émet une carte cadeau ; son code n'est retourné qu'ici.
+func IssueGiftCard(db *gorm.DB, input models.GiftCardInput, actor string) (models.GiftCard, error) {
+	amount := input.Amount
+	if amount.Currency == "" {
+		amount.Currency = money.DefaultCurrency
+	}
+	currency, err := money.NormalizeCurrency(amount.Currency)
+	if err != nil {
+		return models.GiftCard{}, fmt.Errorf("%w : %v", ErrGiftCardInvalid, err)
+	}
+	amount.Currency = currency
+	if !amount.IsPositive() {
+		return models.GiftCard{}, fmt.Errorf("%w : montant requis", ErrGiftCardInvalid)
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return models.GiftCard{}, fmt.Errorf("%w : date d'expiration passée", ErrGiftCardInvalid)
+	}
+
+	code, err := newGiftCardCode()
+	if err != nil {
+		return models.GiftCard{}, err
+	}
+	card := models.GiftCard{
+		CodeHash:  hashGiftCardCode(code),
+		Code:      code,
+		Last4:     code[len(code)-4:],
+		Amount:    amount,
+		ExpiresAt: input.ExpiresAt,
+		Note:      input.Note,
+		IssuedBy:  actor,
+	}
+	return card, db.Create(&card).Error
+}
+
+func ListGiftCards(db *gorm.DB) ([]models.GiftCard, error) {
+	var cards []models.GiftCard
+	err := db.Order("id DESC").Find(&cards).Error
+	return cards, err
+}
+
+// RedeemGiftCard crédite la valeur de la carte sur le porte-monnaie de
+// l'utilisateur. La carte est marquée utilisée par une mise à jour
+// conditionnelle : utilisée deux fois en même temps, elle n'est créditée qu'une fois.
+func RedeemGiftCard(db *gorm.DB, userID uint, code string) (models.WalletTransaction, error) {
+	var entry models.WalletTransaction
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var card models.GiftCard
+		if err := tx.First(&card, "code_hash = ?", hashGiftCardCode(code)).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrGiftCardNotFound
+			}
+			return err
+		}
+
+		now := time.Now()
+		if card.RedeemedAt != nil {
+			return ErrGiftCardRedeemed
+		}
+		if card.ExpiresAt != nil && now.After(*card.ExpiresAt) {
+			return ErrGiftCardExpired
+		}
+
+		res := tx.Model(&models.GiftCard{}).
+			Where("id = ? AND redeemed_at IS NULL", card.ID).
+			Updates(map[string]interface{}{"redeemed_by": userID, "redeemed_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrGiftCardRedeemed
+		}
+
+		var err error
+		entry, err = creditWallet(tx, userID, card.Amount, models.WalletTransaction{
+			Kind:       models.WalletGiftCard,
+			GiftCardID: &card.ID,
+			Reason:     "Carte cadeau se terminant par " + card.Last4,
+		})
+		return err
+	})
+
+	return entry, err
+}
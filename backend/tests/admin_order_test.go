@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"h3-travel/controllers"
+	"h3-travel/models"
+	"h3-travel/money"
+	"h3-travel/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func adminOrdersRouter() *gin.Engine {
+	router := gin.New()
+	asAdmin := func(handler gin.HandlerFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set("user_id", uint(99))
+			handler(c)
+		}
+	}
+	router.GET("/admin/orders", asAdmin(controllers.AdminListOrders))
+	router.GET("/admin/orders/:id", asAdmin(controllers.AdminGetOrder))
+	router.PUT("/admin/orders/:id/confirm", asAdmin(controllers.AdminConfirmOrder))
+	router.PUT("/admin/orders/:id/cancel", asAdmin(controllers.AdminCancelOrder))
+	router.POST("/admin/orders/:id/notes", asAdmin(controllers.AdminAddOrderNote))
+	return router
+}
+
+func adminRequest(method, path string, input interface{}) *httptest.ResponseRecorder {
+	return jsonRequest(adminOrdersRouter(), method, path, input)
+}
+
+func listedOrderIDs(t *testing.T, query string) []uint {
+	orders := decodeOK[[]models.Order](t, adminRequest("GET", "/admin/orders"+query, nil))
+	ids := make([]uint, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+	return ids
+}
+
+func TestAdminListOrdersFilters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	rome := models.Travel{Title: "Rome", Price: money.New(30000, "EUR"), Stock: 10, Active: true}
+	oslo := models.Travel{Title: "Oslo", Price: money.New(20000, "EUR"), Stock: 10, Active: true}
+	db.Create(&rome)
+	db.Create(&oslo)
+
+	first := paidOrder(t, orderItemsRouter(1), rome.ID, 1)
+	second := createHold(t, orderItemsRouter(2), oslo.ID, 1)
+	third := createHold(t, orderItemsRouter(2), rome.ID, 2)
+
+	// Commande ancienne, hors de la période filtrée
+	lastYear := time.Now().AddDate(-1, 0, 0)
+	db.Model(&models.Order{}).Where("id = ?", first.ID).Update("created_at", lastYear)
+
+	assert.Equal(t, []uint{third.ID, second.ID, first.ID}, listedOrderIDs(t, ""))
+	assert.Equal(t, []uint{first.ID}, listedOrderIDs(t, "?status=paid"))
+	assert.Equal(t, []uint{third.ID, second.ID}, listedOrderIDs(t, "?user_id=2"))
+	assert.Equal(t, []uint{third.ID, first.ID}, listedOrderIDs(t, fmt.Sprintf("?travel_id=%d", rome.ID)))
+	assert.Equal(t, []uint{third.ID}, listedOrderIDs(t, fmt.Sprintf("?travel_id=%d&user_id=2", rome.ID)))
+
+	today := time.Now().Format("2006-01-02")
+	assert.Equal(t, []uint{third.ID, second.ID}, listedOrderIDs(t, "?from="+today))
+	assert.Equal(t, []uint{first.ID}, listedOrderIDs(t, "?to="+lastYear.Format("2006-01-02")))
+
+	resp := adminRequest("GET", "/admin/orders?page=2&page_size=2", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "3", resp.Header().Get("X-Total-Count"))
+	page := decodeJSON[[]models.Order](t, resp)
+	if assert.Len(t, page, 1) {
+		assert.Equal(t, first.ID, page[0].ID)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, adminRequest("GET", "/admin/orders?from=hier", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, adminRequest("GET", "/admin/orders?page_size=1000", nil).Code)
+}
+
+func TestAdminOrderDetailsNotesAndForceCancel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	customer := models.User{Email: "client@example.com", Password: "hash", Role: "user"}
+	db.Create(&customer)
+
+	// Politique la plus stricte : une annulation par le client ne serait pas remboursée
+	departure := time.Now().Add(24 * time.Hour)
+	travel := models.Travel{Title: "Rome", Price: money.New(30000, "EUR"), Stock: 5, Active: true, DepartureDate: &departure,
+		CancellationTiers: []models.CancellationTier{{MinDaysBefore: 30, RefundPercent: 100}}}
+	db.Create(&travel)
+
+	order := paidOrder(t, orderItemsRouter(customer.ID), travel.ID, 2)
+
+	resp := adminRequest("POST", fmt.Sprintf("/admin/orders/%d/notes", order.ID), models.OrderNoteInput{Body: "Client rappelé"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, http.StatusBadRequest, adminRequest("POST", fmt.Sprintf("/admin/orders/%d/notes", order.ID), models.OrderNoteInput{}).Code)
+	assert.Equal(t, http.StatusNotFound, adminRequest("POST", "/admin/orders/999/notes", models.OrderNoteInput{Body: "?"}).Code)
+
+	resp = adminRequest("GET", fmt.Sprintf("/admin/orders/%d", order.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	details := decodeJSON[models.AdminOrderDetails](t, resp)
+	if assert.NotNil(t, details.Customer) {
+		assert.Equal(t, "client@example.com", details.Customer.Email)
+	}
+	assert.NotContains(t, resp.Body.String(), "hash")
+	if assert.Len(t, details.Travels, 1) {
+		assert.Equal(t, "Rome", details.Travels[0].Title)
+	}
+	if assert.Len(t, details.Notes, 1) {
+		assert.Equal(t, "Client rappelé", details.Notes[0].Body)
+		assert.Equal(t, uint(99), details.Notes[0].AuthorID)
+	}
+	assert.Len(t, details.History, 2)
+	assert.Len(t, details.Payments, 1)
+	assert.Equal(t, http.StatusNotFound, adminRequest("GET", "/admin/orders/999", nil).Code)
+
+	// Les notes internes ne sont jamais renvoyées au client
+	req := httptest.NewRequest("GET", "/orders/user", nil)
+	userResp := httptest.NewRecorder()
+	userRouter := gin.New()
+	userRouter.GET("/orders/user", func(c *gin.Context) {
+		c.Set("user_id", customer.ID)
+		controllers.GetUserOrders(c)
+	})
+	userRouter.ServeHTTP(userResp, req)
+	assert.NotContains(t, userResp.Body.String(), "Client rappelé")
+
+	assert.Equal(t, http.StatusBadRequest, adminRequest("PUT", fmt.Sprintf("/admin/orders/%d/cancel", order.ID), models.AdminCancelOrderInput{Refund: "half"}).Code)
+
+	// Annulation forcée : remboursement intégral malgré la politique
+	resp = adminRequest("PUT", fmt.Sprintf("/admin/orders/%d/cancel", order.ID), models.AdminCancelOrderInput{Reason: "Vol annulé"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	cancelled := decodeJSON[models.CancelOrderResponse](t, resp)
+	assert.Equal(t, models.StatusCancelled, cancelled.Statut)
+	assert.Equal(t, eur(60000), cancelled.Refund.RefundAmount)
+	if assert.Len(t, cancelled.Refunds, 1) {
+		assert.Equal(t, "admin:99", cancelled.Refunds[0].Actor)
+		assert.Equal(t, "Vol annulé", cancelled.Refunds[0].Reason)
+	}
+	assert.Equal(t, 5, stockOf(db, travel.ID))
+
+	assert.Equal(t, http.StatusConflict, adminRequest("PUT", fmt.Sprintf("/admin/orders/%d/cancel", order.ID), nil).Code)
+}
+
+func TestAdminForceCancelWithoutRefund(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Rome", Price: money.New(30000, "EUR"), Stock: 5, Active: true}
+	db.Create(&travel)
+	order := paidOrder(t, orderItemsRouter(1), travel.ID, 1)
+
+	resp := adminRequest("PUT", fmt.Sprintf("/admin/orders/%d/cancel", order.ID), models.AdminCancelOrderInput{Refund: models.RefundNone})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	cancelled := decodeJSON[models.CancelOrderResponse](t, resp)
+	assert.Equal(t, models.StatusCancelled, cancelled.Statut)
+	assert.Equal(t, eur(0), cancelled.Refund.RefundAmount)
+	assert.Equal(t, eur(30000), cancelled.RefundableAmount)
+	assert.Empty(t, cancelled.Refunds)
+}
+
+// Cycle de vie : payée, confirmée par un admin, puis terminée après le départ
+func TestAdminConfirmAndCompleteOrders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	departure := time.Now().Add(10 * 24 * time.Hour)
+	rome := models.Travel{Title: "Rome", Price: eur(30000), Stock: 5, Active: true, DepartureDate: &departure}
+	hotel := models.Travel{Title: "Hôtel sans date", Price: eur(20000), Stock: 5, Active: true}
+	db.Create(&rome)
+	db.Create(&hotel)
+	confirmed := paidOrder(t, orderItemsRouter(1), rome.ID, 1)
+	paid := paidOrder(t, orderItemsRouter(1), rome.ID, 1)
+	undated := paidOrder(t, orderItemsRouter(1), hotel.ID, 1)
+	pending := createHold(t, orderItemsRouter(1), rome.ID, 1)
+
+	path := fmt.Sprintf("/admin/orders/%d/confirm", confirmed.ID)
+	resp := adminRequest("PUT", path, nil)
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	order := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, models.StatusConfirmed, order.Statut)
+	assert.Equal(t, http.StatusConflict, adminRequest("PUT", path, nil).Code)
+	assert.Equal(t, http.StatusConflict, adminRequest("PUT", fmt.Sprintf("/admin/orders/%d/confirm", pending.ID), nil).Code)
+	assert.Equal(t, http.StatusNotFound, adminRequest("PUT", "/admin/orders/999/confirm", nil).Code)
+
+	completed, err := services.CompleteOrders(context.Background(), db, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, completed)
+
+	completed, err = services.CompleteOrders(context.Background(), db, departure.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, completed)
+	for id, status := range map[uint]models.OrderStatus{
+		confirmed.ID: models.StatusCompleted,
+		paid.ID:      models.StatusCompleted,
+		undated.ID:   models.StatusPaid,
+		pending.ID:   models.StatusPendingPayment,
+	} {
+		var reloaded models.Order
+		db.First(&reloaded, id)
+		assert.Equal(t, status, reloaded.Statut, "commande %d", id)
+	}
+}
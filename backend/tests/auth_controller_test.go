@@ -86,8 +86,7 @@ func TestLoginWithMockDB(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, resp.Code)
 
-	var result map[string]interface{}
-	_ = json.Unmarshal(resp.Body.Bytes(), &result)
+	result := decodeJSON[map[string]interface{}](t, resp)
 	assert.NotEmpty(t, result["token"])
 
 	// --- Cas échec : mauvais mot de passe ---
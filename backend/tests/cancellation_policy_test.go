@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"h3-travel/controllers"
+	"h3-travel/models"
+	"h3-travel/money"
+	"h3-travel/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefundPercentFor(t *testing.T) {
+	tiers := []models.CancellationTier{
+		{MinDaysBefore: 7, RefundPercent: 50},
+		{MinDaysBefore: 31, RefundPercent: 100},
+	}
+
+	assert.Equal(t, 100, models.RefundPercentFor(tiers, 45))
+	assert.Equal(t, 100, models.RefundPercentFor(tiers, 31))
+	assert.Equal(t, 50, models.RefundPercentFor(tiers, 30))
+	assert.Equal(t, 50, models.RefundPercentFor(tiers, 7))
+	assert.Equal(t, 0, models.RefundPercentFor(tiers, 6))
+	assert.Equal(t, 100, models.RefundPercentFor(nil, 0))
+}
+
+func TestCancellationQuoteAndPolicyRefund(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	departure := time.Now().Add(10 * 24 * time.Hour)
+	travel := models.Travel{Title: "Lisbonne", Price: money.New(40000, "EUR"), Stock: 5, Active: true, DepartureDate: &departure}
+	db.Create(&travel)
+
+	_, err := services.SetCancellationPolicy(db, travel.ID, []models.CancellationTierInput{
+		{MinDaysBefore: 31, RefundPercent: 100},
+		{MinDaysBefore: 7, RefundPercent: 50},
+	})
+	assert.NoError(t, err)
+
+	router := orderItemsRouter(1)
+	router.GET("/orders/:id/cancellation-quote", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		controllers.GetCancellationQuote(c)
+	})
+	order := paidOrder(t, router, travel.ID, 2)
+
+	// Aperçu : 9 jours avant le départ, palier à 50 %
+	req := httptest.NewRequest("GET", fmt.Sprintf("/orders/%d/cancellation-quote", order.ID), nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	quote := decodeJSON[models.CancellationQuote](t, resp)
+	assert.Equal(t, eur(40000), quote.RefundAmount)
+	if assert.Len(t, quote.Lines, 1) {
+		assert.Equal(t, 50, quote.Lines[0].RefundPercent)
+		assert.Equal(t, 9, *quote.Lines[0].DaysBeforeDeparture)
+	}
+
+	// L'annulation rembourse le même montant
+	req = httptest.NewRequest("PUT", fmt.Sprintf("/orders/%d/cancel", order.ID), nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	cancelled := decodeJSON[models.CancelOrderResponse](t, resp)
+	assert.Equal(t, models.StatusCancelled, cancelled.Statut)
+	assert.Equal(t, eur(40000), cancelled.Refund.RefundAmount)
+	assert.Equal(t, eur(40000), cancelled.RefundedAmount)
+	assert.Equal(t, eur(40000), cancelled.RefundableAmount)
+	assert.Equal(t, 5, stockOf(db, travel.ID))
+
+	// Plus d'aperçu possible sur une commande annulée
+	req = httptest.NewRequest("GET", fmt.Sprintf("/orders/%d/cancellation-quote", order.ID), nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+}
+
+// Un paiement enregistré dans une autre devise que les lignes donne une erreur, pas une panique.
+func TestCancellationQuoteCurrencyMismatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Lisbonne", Price: money.New(40000, "EUR"), Stock: 5, Active: true}
+	db.Create(&travel)
+
+	router := orderItemsRouter(1)
+	router.GET("/orders/:id/cancellation-quote", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		controllers.GetCancellationQuote(c)
+	})
+	order := paidOrder(t, router, travel.ID, 1)
+	db.Model(&models.Order{}).Where("id = ?", order.ID).Update("paid_currency", "USD")
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/orders/%d/cancellation-quote", order.ID), nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	req = httptest.NewRequest("PUT", fmt.Sprintf("/orders/%d/cancel", order.ID), nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Equal(t, 4, stockOf(db, travel.ID))
+}
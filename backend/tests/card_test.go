@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"h3-travel/card"
+	"h3-travel/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cardErrorCode(err error) string {
+	var invalid *card.ValidationError
+	if errors.As(err, &invalid) {
+		return invalid.Code
+	}
+	return ""
+}
+
+func TestLuhnRejectsTooShortNumbers(t *testing.T) {
+	assert.False(t, utils.ValidateCardNumber(""))
+	assert.False(t, utils.ValidateCardNumber("0"))
+	assert.True(t, utils.ValidateCardNumber("4242424242424242"))
+}
+
+func TestCardValidateNumber(t *testing.T) {
+	cases := []struct {
+		number string
+		brand  card.Brand
+		code   string
+	}{
+		{"4242 4242 4242 4242", card.Visa, ""},
+		{"4242-4242-4242-4242", card.Visa, ""},
+		{"5555555555554444", card.Mastercard, ""},
+		{"2223003122003222", card.Mastercard, ""},
+		{"378282246310005", card.Amex, ""},
+		{"4970100000000154", card.CB, ""},
+		{"", "", "empty"},
+		{"4", "", "invalid_length"},
+		{"4242/4242", "", "invalid_characters"},
+		{"6011111111111117", "", "unknown_brand"},
+		{"37828224631000", "", "invalid_length"},
+		{"4242424242424241", "", "luhn"},
+	}
+
+	for _, tc := range cases {
+		_, brand, err := card.ValidateNumber(tc.number)
+		assert.Equal(t, tc.code, cardErrorCode(err), tc.number)
+		if tc.code == "" {
+			assert.Equal(t, tc.brand, brand, tc.number)
+		}
+	}
+}
+
+func TestCardValidateExpiryAndCVV(t *testing.T) {
+	now := time.Date(2026, time.March, 15, 12, 0, 0, 0, time.UTC)
+
+	assert.NoError(t, card.ValidateExpiry(3, 2026, now))
+	assert.NoError(t, card.ValidateExpiry(4, 27, now))
+	assert.Equal(t, "expired", cardErrorCode(card.ValidateExpiry(2, 2026, now)))
+	assert.Equal(t, "invalid_expiry_month", cardErrorCode(card.ValidateExpiry(13, 2027, now)))
+	assert.Equal(t, "invalid_expiry_year", cardErrorCode(card.ValidateExpiry(1, 2099, now)))
+
+	assert.NoError(t, card.ValidateCVV("123", card.Visa))
+	assert.NoError(t, card.ValidateCVV("1234", card.Amex))
+	assert.Equal(t, "invalid_cvv_length", cardErrorCode(card.ValidateCVV("123", card.Amex)))
+	assert.Equal(t, "invalid_cvv", cardErrorCode(card.ValidateCVV("12a", card.Visa)))
+}
+
+func TestCardValidateTestCards(t *testing.T) {
+	details := card.Details{Number: "4242 4242 4242 4242", ExpMonth: 12, ExpYear: 2030, CVV: "123"}
+	now := time.Date(2026, time.March, 15, 12, 0, 0, 0, time.UTC)
+
+	validated, err := card.Validate(details, now, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "4242424242424242", validated.Number)
+	assert.Equal(t, "4242", validated.Last4)
+
+	_, err = card.Validate(details, now, false)
+	assert.Equal(t, "test_card", cardErrorCode(err))
+}
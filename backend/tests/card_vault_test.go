@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"h3-travel/models"
+	"h3-travel/money"
+	"h3-travel/vault"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVaultSealOpen(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	sealed, err := vault.Seal(key, []byte("4242424242424242"), nil)
+	assert.NoError(t, err)
+	assert.False(t, bytes.Contains(sealed, []byte("4242424242424242")))
+
+	plain, err := vault.Open(key, sealed, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "4242424242424242", string(plain))
+
+	// Données associées : liées au chiffré sans y figurer
+	bound, err := vault.Seal(key, []byte("4242424242424242"), []byte("order:1"))
+	assert.NoError(t, err)
+	_, err = vault.Open(key, bound, []byte("order:2"))
+	assert.ErrorIs(t, err, vault.ErrInvalidCiphertext)
+	_, err = vault.Open(key, bound, nil)
+	assert.ErrorIs(t, err, vault.ErrInvalidCiphertext)
+
+	sealed[len(sealed)-1] ^= 0xff
+	_, err = vault.Open(key, sealed, nil)
+	assert.ErrorIs(t, err, vault.ErrInvalidCiphertext)
+
+	_, err = vault.ParseKey("trop-courte")
+	assert.Error(t, err)
+}
+
+func TestTokenizeCardAndPayWithToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Rome", Price: money.New(30000, "EUR"), Stock: 3, Active: true}
+	db.Create(&travel)
+	router := orderItemsRouter(1)
+
+	resp := tokenizeCard(router, "4242 4242 4242 4242")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.NotContains(t, resp.Body.String(), "4242424242424242")
+
+	token := decodeJSON[models.CardToken](t, resp)
+	assert.Regexp(t, `^tok_[0-9a-f]{32}$`, token.Token)
+	assert.Equal(t, "visa", token.Brand)
+	assert.Equal(t, "4242", token.Last4)
+
+	// Seul le numéro chiffré est stocké dans le coffre
+	var stored models.CardToken
+	db.Where("token = ?", token.Token).First(&stored)
+	assert.False(t, bytes.Contains(stored.EncryptedPAN, []byte("4242424242424242")))
+
+	// Un autre utilisateur ne peut pas utiliser ce token
+	order := createHold(t, orderItemsRouter(2), travel.ID, 1)
+	assert.Equal(t, http.StatusBadRequest, payWithToken(orderItemsRouter(2), order.ID, token.Token).Code)
+
+	order = createHold(t, router, travel.ID, 1)
+	resp = payWithToken(router, order.ID, token.Token)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	paid := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, "visa", paid.CardBrand)
+	assert.Equal(t, "4242", paid.CardLast4)
+	assert.NotContains(t, resp.Body.String(), "4242424242424242")
+}
+
+func TestTokenizeCardReportsFailedCheck(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	SetupSQLiteDB(t)
+
+	resp := tokenizeCard(orderItemsRouter(1), "4242424242424241")
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	result := decodeJSON[map[string]string](t, resp)
+	assert.Equal(t, "card", result["field"])
+	assert.Equal(t, "luhn", result["code"])
+}
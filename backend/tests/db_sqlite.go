@@ -0,0 +1,44 @@
+package tests
+
+import (
+	"h3-travel/config"
+	"h3-travel/models"
+	"h3-travel/payment"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// SetupSQLiteDB ouvre une vraie base SQLite (sans CGO) pour les tests où
+// sqlmock ne suffit pas, par exemple les accès concurrents.
+func SetupSQLiteDB(t *testing.T) *gorm.DB {
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=busy_timeout(5000)"
+
+	gormDB, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Impossible d'ouvrir SQLite: %v", err)
+	}
+
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		t.Fatalf("Impossible de récupérer la connexion SQLite: %v", err)
+	}
+	// SQLite n'accepte qu'un écrivain à la fois
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := gormDB.AutoMigrate(models.All()...); err != nil {
+		t.Fatalf("Migration impossible: %v", err)
+	}
+
+	config.DB = gormDB
+	config.Payment = payment.NewMockProvider()
+	config.VaultKey = []byte("0123456789abcdef0123456789abcdef")
+	t.Cleanup(func() { sqlDB.Close() })
+
+	return gormDB
+}
@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"h3-travel/controllers"
+	"h3-travel/models"
+	"h3-travel/money"
+	"h3-travel/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func departureRequest(method, path string, input interface{}) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/travels/:id/departures", controllers.ListDepartures)
+	router.POST("/travels/:id/departures", controllers.CreateDeparture)
+	router.PUT("/travels/:id/departures/:departure_id", controllers.UpdateDeparture)
+	router.DELETE("/travels/:id/departures/:departure_id", controllers.DeleteDeparture)
+
+	return jsonRequest(router, method, path, input)
+}
+
+func createDeparture(t *testing.T, travelID uint, input models.DepartureInput) models.Departure {
+	return decodeOK[models.Departure](t, departureRequest("POST", fmt.Sprintf("/travels/%d/departures", travelID), input))
+}
+
+func listDepartures(t *testing.T, travelID uint, query string) []models.Departure {
+	return decodeOK[[]models.Departure](t, departureRequest("GET", fmt.Sprintf("/travels/%d/departures%s", travelID, query), nil))
+}
+
+func departureStock(db *gorm.DB, departureID uint) int {
+	var departure models.Departure
+	db.First(&departure, departureID)
+	return departure.Stock
+}
+
+func orderDeparture(userID, travelID uint, departureID *uint, quantity int) *httptest.ResponseRecorder {
+	return jsonRequest(orderItemsRouter(userID), "POST", "/orders", models.CreateOrderInput{
+		Items: []models.OrderItemInput{{TravelID: travelID, DepartureID: departureID, Quantity: quantity}},
+	})
+}
+
+func weekend(in time.Duration) models.DepartureInput {
+	start := time.Now().Add(in).UTC().Truncate(time.Second)
+	return models.DepartureInput{StartDate: start, EndDate: start.Add(48 * time.Hour), Capacity: 4}
+}
+
+func TestAdminManagesDepartures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Week-end à Lisbonne", Price: eur(40000), Stock: 0, Active: true}
+	db.Create(&travel)
+	path := fmt.Sprintf("/travels/%d/departures", travel.ID)
+
+	past := weekend(-24 * time.Hour)
+	backwards := weekend(24 * time.Hour)
+	backwards.EndDate = backwards.StartDate.Add(-time.Hour)
+	otherCurrency := weekend(24 * time.Hour)
+	otherCurrency.Price = &money.Money{Amount: 50000, Currency: "USD"}
+	for _, input := range []models.DepartureInput{past, backwards, otherCurrency} {
+		assert.Equal(t, http.StatusBadRequest, departureRequest("POST", path, input).Code)
+	}
+	assert.Equal(t, http.StatusNotFound, departureRequest("POST", "/travels/999/departures", weekend(24*time.Hour)).Code)
+
+	later := createDeparture(t, travel.ID, weekend(60*24*time.Hour))
+	summer := weekend(30 * 24 * time.Hour)
+	summer.Price = &money.Money{Amount: 55000}
+	soon := createDeparture(t, travel.ID, summer)
+	assert.Equal(t, 4, soon.Stock)
+	assert.Equal(t, eur(55000), soon.Price)
+	assert.True(t, soon.Bookable)
+	assert.True(t, later.Price.IsZero())
+
+	listed := listDepartures(t, travel.ID, "")
+	if assert.Len(t, listed, 2) {
+		assert.Equal(t, soon.ID, listed[0].ID)
+	}
+
+	// Un départ passé disparaît de la liste et n'est plus réservable
+	db.Model(&models.Departure{}).Where("id = ?", later.ID).Update("start_date", time.Now().Add(-time.Hour))
+	assert.Len(t, listDepartures(t, travel.ID, ""), 1)
+	all := listDepartures(t, travel.ID, "?include_past=true")
+	if assert.Len(t, all, 2) {
+		assert.False(t, all[0].Bookable)
+	}
+
+	// Capacité : les places restantes suivent, sans descendre sous les places réservées
+	departureID := soon.ID
+	assert.Equal(t, http.StatusOK, orderDeparture(1, travel.ID, &departureID, 3).Code)
+	update := summer
+	update.Capacity = 2
+	resp := departureRequest("PUT", fmt.Sprintf("%s/%d", path, soon.ID), update)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	update.Capacity = 6
+	resp = departureRequest("PUT", fmt.Sprintf("%s/%d", path, soon.ID), update)
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	updated := decodeJSON[models.Departure](t, resp)
+	assert.Equal(t, 6, updated.Capacity)
+	assert.Equal(t, 3, updated.Stock)
+
+	assert.Equal(t, http.StatusConflict, departureRequest("DELETE", fmt.Sprintf("%s/%d", path, soon.ID), nil).Code)
+	assert.Equal(t, http.StatusOK, departureRequest("DELETE", fmt.Sprintf("%s/%d", path, later.ID), nil).Code)
+	assert.Equal(t, http.StatusNotFound, departureRequest("DELETE", fmt.Sprintf("%s/%d", path, later.ID), nil).Code)
+}
+
+func TestOrderBooksDeparture(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Week-end à Lisbonne", Price: eur(40000), Stock: 10, Active: true}
+	other := models.Travel{Title: "Rome", Price: eur(30000), Stock: 10, Active: true}
+	db.Create(&travel)
+	db.Create(&other)
+	input := weekend(10 * 24 * time.Hour)
+	input.Price = &money.Money{Amount: 45000}
+	departure := createDeparture(t, travel.ID, input)
+	full := createDeparture(t, travel.ID, weekend(20*24*time.Hour))
+
+	// Un travel daté se réserve par départ, et un départ n'appartient qu'à son travel
+	assert.Equal(t, http.StatusBadRequest, orderDeparture(1, travel.ID, nil, 1).Code)
+	assert.Equal(t, http.StatusNotFound, orderDeparture(1, other.ID, &departure.ID, 1).Code)
+	assert.Equal(t, http.StatusBadRequest, orderDeparture(1, travel.ID, &full.ID, 5).Code)
+
+	resp := orderDeparture(1, travel.ID, &departure.ID, 2)
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	order := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, eur(90000), order.Total)
+	if assert.Len(t, order.Items, 1) && assert.NotNil(t, order.Items[0].DepartureID) {
+		assert.Equal(t, departure.ID, *order.Items[0].DepartureID)
+	}
+	assert.Equal(t, 2, departureStock(db, departure.ID))
+	assert.Equal(t, 10, stockOf(db, travel.ID))
+
+	// Deux départs d'un même travel ne vont pas dans une même commande
+	body, _ := json.Marshal(models.CreateOrderInput{Items: []models.OrderItemInput{
+		{TravelID: travel.ID, DepartureID: &departure.ID, Quantity: 1},
+		{TravelID: travel.ID, DepartureID: &full.ID, Quantity: 1},
+	}})
+	req := httptest.NewRequest("POST", "/orders", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	mixed := httptest.NewRecorder()
+	orderItemsRouter(1).ServeHTTP(mixed, req)
+	assert.Equal(t, http.StatusBadRequest, mixed.Code)
+
+	// Annulée, la commande rend ses places au départ
+	assert.Equal(t, http.StatusOK, cancelOrderRequest(1, order.ID, nil).Code)
+	assert.Equal(t, 4, departureStock(db, departure.ID))
+
+	// Une réservation expirée aussi
+	resp = orderDeparture(1, travel.ID, &departure.ID, 1)
+	order = decodeJSON[models.Order](t, resp)
+	expireNow(db, order.ID)
+	_, err := services.ExpireHolds(t.Context(), db, time.Now(), time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, departureStock(db, departure.ID))
+
+	// Un départ passé n'est plus réservable
+	db.Model(&models.Departure{}).Where("id = ?", departure.ID).Update("start_date", time.Now().Add(-time.Minute))
+	assert.Equal(t, http.StatusBadRequest, orderDeparture(1, travel.ID, &departure.ID, 1).Code)
+	assert.Equal(t, 4, departureStock(db, departure.ID))
+}
+
+// La politique d'annulation se calcule à la date du départ réservé.
+func TestCancellationUsesDepartureDate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Week-end à Lisbonne", Price: eur(40000), Stock: 10, Active: true}
+	db.Create(&travel)
+	_, err := services.SetCancellationPolicy(db, travel.ID, []models.CancellationTierInput{
+		{MinDaysBefore: 31, RefundPercent: 100},
+		{MinDaysBefore: 7, RefundPercent: 50},
+	})
+	assert.NoError(t, err)
+	departure := createDeparture(t, travel.ID, weekend(10*24*time.Hour))
+
+	router := orderItemsRouter(1)
+	resp := orderDeparture(1, travel.ID, &departure.ID, 1)
+	order := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, http.StatusOK, payOrder(t, router, order.ID, "4242424242424242").Code)
+
+	resp = cancelOrderRequest(1, order.ID, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	cancelled := decodeJSON[models.CancelOrderResponse](t, resp)
+	assert.Equal(t, eur(20000), cancelled.Refund.RefundAmount)
+	if assert.Len(t, cancelled.Refund.Lines, 1) {
+		assert.Equal(t, 9, *cancelled.Refund.Lines[0].DaysBeforeDeparture)
+	}
+}
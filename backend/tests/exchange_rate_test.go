@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"h3-travel/controllers"
+	"h3-travel/models"
+	"h3-travel/money"
+	"h3-travel/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setRate(base, quote string, rate float64) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.PUT("/admin/rates/:base/:quote", controllers.AdminSetExchangeRate)
+
+	return jsonRequest(router, "PUT", fmt.Sprintf("/admin/rates/%s/%s", base, quote), models.ExchangeRateInput{Rate: rate})
+}
+
+func getTravels(path string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/travels", controllers.GetTravels)
+	router.GET("/travels/:id", controllers.GetTravel)
+
+	return jsonRequest(router, "GET", path, nil)
+}
+
+func TestTravelPricesInRequestedCurrency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	rome := models.Travel{Title: "Rome", Price: eur(30000), Stock: 5, Active: true}
+	tokyo := models.Travel{Title: "Tokyo", Price: money.New(250000, "JPY"), Stock: 5, Active: true}
+	db.Create(&rome)
+	db.Create(&tokyo)
+
+	assert.Equal(t, http.StatusOK, setRate("eur", "usd", 1.1).Code)
+	assert.Equal(t, http.StatusOK, setRate("EUR", "USD", 1.08).Code) // remplace le taux existant
+	assert.Equal(t, http.StatusOK, setRate("USD", "JPY", 150).Code)
+	assert.Equal(t, http.StatusBadRequest, setRate("EUR", "EUR", 1).Code)
+	assert.Equal(t, http.StatusBadRequest, setRate("EUR", "DOLLAR", 1).Code)
+	assert.Equal(t, http.StatusBadRequest, setRate("EUR", "GBP", 0).Code)
+
+	rates, err := services.ListExchangeRates(db)
+	assert.NoError(t, err)
+	assert.Len(t, rates, 2)
+
+	resp := getTravels("/travels?currency=usd")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	page := decodeJSON[models.TravelPage](t, resp)
+	travels := page.Data
+	if assert.Len(t, travels, 2) {
+		assert.Equal(t, eur(30000), travels[0].Price)
+		assert.Equal(t, money.New(32400, "USD"), *travels[0].DisplayPrice)
+		assert.Equal(t, 1.08, travels[0].DisplayRate.Rate)
+
+		// JPY -> USD déduit du taux USD -> JPY
+		assert.Equal(t, money.New(166667, "USD"), *travels[1].DisplayPrice)
+		assert.Equal(t, "JPY", travels[1].DisplayRate.Base)
+	}
+
+	resp = getTravels(fmt.Sprintf("/travels/%d?currency=USD", rome.ID))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	travel := decodeJSON[models.Travel](t, resp)
+	assert.Equal(t, money.New(32400, "USD"), *travel.DisplayPrice)
+
+	// Sans paramètre, aucun prix converti
+	resp = getTravels(fmt.Sprintf("/travels/%d", rome.ID))
+	assert.NotContains(t, resp.Body.String(), "DisplayPrice")
+
+	assert.Equal(t, http.StatusBadRequest, getTravels("/travels?currency=GBP").Code)
+	assert.Equal(t, http.StatusBadRequest, getTravels("/travels?currency=12").Code)
+}
+
+func TestOrderRejectsMixedCurrencies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	rome := models.Travel{Title: "Rome", Price: eur(30000), Stock: 5, Active: true}
+	tokyo := models.Travel{Title: "Tokyo", Price: money.New(250000, "JPY"), Stock: 5, Active: true}
+	db.Create(&rome)
+	db.Create(&tokyo)
+
+	body, _ := json.Marshal(models.CreateOrderInput{Items: []models.OrderItemInput{
+		{TravelID: rome.ID, Quantity: 1},
+		{TravelID: tokyo.ID, Quantity: 1},
+	}})
+	req := httptest.NewRequest("POST", "/orders", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	orderItemsRouter(1).ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, 5, stockOf(db, rome.ID))
+
+	// Une commande en yens est payée en yens
+	order := paidOrder(t, orderItemsRouter(1), tokyo.ID, 2)
+	assert.Equal(t, money.New(500000, "JPY"), order.PaidAmount)
+	if assert.Len(t, order.Payments, 1) {
+		assert.Equal(t, money.New(500000, "JPY"), order.Payments[0].Amount)
+	}
+}
+
+func TestMigrateLegacyAmounts(t *testing.T) {
+	db := SetupSQLiteDB(t)
+
+	// Base créée avant le type money.Money : montants en euros dans des colonnes float
+	db.Exec(`ALTER TABLE travels ADD COLUMN "price" real`)
+	db.Exec(`ALTER TABLE orders ADD COLUMN "total" real`)
+	db.Exec(`ALTER TABLE payments ADD COLUMN "currency" text`)
+
+	travel := models.Travel{Title: "Rome", Stock: 5, Active: true}
+	db.Create(&travel)
+	db.Exec("UPDATE travels SET price = 299.99 WHERE id = ?", travel.ID)
+	order := models.Order{UserID: 1, Statut: models.StatusPaid}
+	db.Create(&order)
+	db.Exec("UPDATE orders SET total = 599.98 WHERE id = ?", order.ID)
+
+	assert.NoError(t, services.MigrateLegacyAmounts(db))
+
+	assert.False(t, db.Migrator().HasColumn(&models.Travel{}, "price"))
+	assert.False(t, db.Migrator().HasColumn(&models.Order{}, "total"))
+	assert.False(t, db.Migrator().HasColumn(&models.Payment{}, "currency"))
+
+	var migrated models.Travel
+	db.First(&migrated, travel.ID)
+	assert.Equal(t, eur(29999), migrated.Price)
+	var migratedOrder models.Order
+	db.First(&migratedOrder, order.ID)
+	assert.Equal(t, eur(59998), migratedOrder.Total)
+
+	// Une seconde exécution ne fait rien
+	assert.NoError(t, services.MigrateLegacyAmounts(db))
+}
@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// jsonRequest envoie une requête au router, avec input encodé en JSON comme
+// corps s'il est fourni, et retourne la réponse enregistrée.
+func jsonRequest(router http.Handler, method, path string, input interface{}) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	if input != nil {
+		_ = json.NewEncoder(&body).Encode(input)
+	}
+	req := httptest.NewRequest(method, path, &body)
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+// decodeJSON décode le corps JSON de la réponse. Un corps illisible fait
+// échouer le test au lieu de laisser passer une valeur vide.
+func decodeJSON[T any](t *testing.T, resp *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &v), resp.Body.String())
+	return v
+}
+
+// decodeOK vérifie que la requête a réussi, puis décode sa réponse.
+func decodeOK[T any](t *testing.T, resp *httptest.ResponseRecorder) T {
+	t.Helper()
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	return decodeJSON[T](t, resp)
+}
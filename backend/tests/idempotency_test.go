@@ -0,0 +1,167 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"h3-travel/controllers"
+	middlewares "h3-travel/middleware"
+	"h3-travel/models"
+	"h3-travel/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func idempotentOrderRequest(router *gin.Engine, key string, input models.CreateOrderInput) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(input)
+	req := httptest.NewRequest("POST", "/orders", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(middlewares.IdempotencyHeader, key)
+	}
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestIdempotencyKeyReplaysCreateOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Rome", Price: money.New(30000, "EUR"), Stock: 5, Active: true}
+	db.Create(&travel)
+
+	router := gin.New()
+	router.POST("/orders", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+	}, middlewares.Idempotency(), controllers.CreateOrder)
+
+	input := models.CreateOrderInput{Items: []models.OrderItemInput{{TravelID: travel.ID, Quantity: 1}}}
+
+	first := idempotentOrderRequest(router, "retry-123", input)
+	assert.Equal(t, http.StatusOK, first.Code)
+
+	// Même clé, même corps : réponse d'origine rejouée, aucune nouvelle commande
+	second := idempotentOrderRequest(router, "retry-123", input)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	assert.JSONEq(t, first.Body.String(), second.Body.String())
+
+	var count int64
+	db.Model(&models.Order{}).Count(&count)
+	assert.Equal(t, int64(1), count)
+	assert.Equal(t, 4, stockOf(db, travel.ID))
+
+	// Même clé, corps différent : 422
+	other := models.CreateOrderInput{Items: []models.OrderItemInput{{TravelID: travel.ID, Quantity: 2}}}
+	conflict := idempotentOrderRequest(router, "retry-123", other)
+	assert.Equal(t, http.StatusUnprocessableEntity, conflict.Code)
+	assert.Equal(t, 4, stockOf(db, travel.ID))
+
+	// Sans clé, chaque requête crée une commande
+	assert.Equal(t, http.StatusOK, idempotentOrderRequest(router, "", input).Code)
+	assert.Equal(t, http.StatusOK, idempotentOrderRequest(router, "", input).Code)
+	db.Model(&models.Order{}).Count(&count)
+	assert.Equal(t, int64(3), count)
+}
+
+func TestIdempotencyKeyIsScopedPerUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Ski", Price: money.New(50000, "EUR"), Stock: 5, Active: true}
+	db.Create(&travel)
+
+	handler := []gin.HandlerFunc{middlewares.Idempotency(), controllers.CreateOrder}
+	router := gin.New()
+	router.POST("/orders", append([]gin.HandlerFunc{func(c *gin.Context) {
+		var userID uint = 1
+		if c.GetHeader("X-Test-User") == "2" {
+			userID = 2
+		}
+		c.Set("user_id", userID)
+	}}, handler...)...)
+
+	input := models.CreateOrderInput{Items: []models.OrderItemInput{{TravelID: travel.ID, Quantity: 1}}}
+	body, _ := json.Marshal(input)
+
+	for _, user := range []string{"1", "2"} {
+		req := httptest.NewRequest("POST", "/orders", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middlewares.IdempotencyHeader, "same-key")
+		req.Header.Set("X-Test-User", user)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Empty(t, resp.Header().Get("Idempotent-Replayed"))
+	}
+
+	assert.Equal(t, 3, stockOf(db, travel.ID))
+}
+
+func TestIdempotencyKeyReleasedOnPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	calls := 0
+	router := gin.New()
+	router.Use(gin.CustomRecovery(func(c *gin.Context, _ any) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	router.POST("/orders", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+	}, middlewares.Idempotency(), func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("panne")
+		}
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	input := models.CreateOrderInput{Items: []models.OrderItemInput{{TravelID: 1, Quantity: 1}}}
+	assert.Equal(t, http.StatusInternalServerError, idempotentOrderRequest(router, "retry-panic", input).Code)
+	var count int64
+	db.Model(&models.IdempotencyKey{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	// La clé libérée, le nouvel essai est traité au lieu de recevoir 409
+	assert.Equal(t, http.StatusOK, idempotentOrderRequest(router, "retry-panic", input).Code)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyKeyReleasedWhenResponseNotSaved(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	_ = db.Callback().Update().Before("gorm:update").Register("test:fail_idempotency", func(tx *gorm.DB) {
+		if tx.Statement.Table == "idempotency_keys" {
+			_ = tx.AddError(errors.New("panne"))
+		}
+	})
+	t.Cleanup(func() { _ = db.Callback().Update().Remove("test:fail_idempotency") })
+
+	calls := 0
+	router := gin.New()
+	router.POST("/orders", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+	}, middlewares.Idempotency(), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	input := models.CreateOrderInput{Items: []models.OrderItemInput{{TravelID: 1, Quantity: 1}}}
+	assert.Equal(t, http.StatusOK, idempotentOrderRequest(router, "unsaved", input).Code)
+	var count int64
+	db.Model(&models.IdempotencyKey{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+
+	// Sans réponse enregistrée, le nouvel essai est traité au lieu de recevoir 409
+	assert.Equal(t, http.StatusOK, idempotentOrderRequest(router, "unsaved", input).Code)
+	assert.Equal(t, 2, calls)
+}
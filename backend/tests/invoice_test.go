@@ -0,0 +1,219 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"h3-travel/controllers"
+	"h3-travel/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func getDocument(router *gin.Engine, path string) *httptest.ResponseRecorder {
+	return jsonRequest(router, "GET", path, nil)
+}
+
+func invoiceNumber(kind string, n int) string {
+	return fmt.Sprintf("%s-%d-%06d", kind, time.Now().Year(), n)
+}
+
+func TestOrderTaxBreakdown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	hotel := models.Travel{Title: "Hôtel à Nice", Price: eur(12000), Stock: 5, Active: true}
+	train := models.Travel{Title: "Train de nuit", Price: eur(5500), Stock: 5, Active: true, TaxCategory: models.TaxIntermediate}
+	db.Create(&hotel)
+	db.Create(&train)
+
+	resp := orderWithPromo(1, "", models.OrderItemInput{TravelID: hotel.ID, Quantity: 1}, models.OrderItemInput{TravelID: train.ID, Quantity: 2})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	order := decodeJSON[models.Order](t, resp)
+	// 120 € TTC à 20 % : 20 € de TVA ; 110 € TTC à 10 % : 10 € de TVA
+	assert.Equal(t, eur(23000), order.Total)
+	assert.Equal(t, eur(3000), order.Tax)
+	assert.Equal(t, eur(20000), order.Net)
+	if assert.Len(t, order.Items, 2) {
+		assert.Equal(t, models.TaxStandard, order.Items[0].TaxCategory)
+		assert.Equal(t, 2000, order.Items[0].TaxRate)
+		assert.Equal(t, eur(2000), order.Items[0].Tax)
+		assert.Equal(t, 1000, order.Items[1].TaxRate)
+		assert.Equal(t, eur(1000), order.Items[1].Tax)
+	}
+
+	// Le taux est figé à l'achat
+	db.Create(&models.TaxRate{Category: models.TaxIntermediate, Rate: 550})
+	var stored models.Order
+	db.Preload("Items").First(&stored, order.ID)
+	assert.Equal(t, eur(3000), stored.Tax)
+	assert.Equal(t, eur(20000), stored.Net)
+	assert.Equal(t, 1000, stored.Items[1].TaxRate)
+}
+
+func TestInvoiceIssuedOnPaymentWithSequentialNumbers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Séjour à Annecy", Price: eur(36000), Stock: 10, Active: true}
+	db.Create(&travel)
+	router := orderItemsRouter(1)
+
+	// Une commande non payée n'a pas de facture
+	hold := createHold(t, router, travel.ID, 1)
+	assert.Equal(t, http.StatusNotFound, getDocument(router, fmt.Sprintf("/orders/%d/invoice", hold.ID)).Code)
+
+	// Un paiement refusé ne consomme pas de numéro
+	assert.Equal(t, http.StatusPaymentRequired, payOrder(t, router, hold.ID, "4000000000000002").Code)
+
+	first := paidOrder(t, router, travel.ID, 1)
+	second := paidOrder(t, router, travel.ID, 2)
+	if assert.Len(t, first.Invoices, 1) && assert.Len(t, second.Invoices, 1) {
+		assert.Equal(t, invoiceNumber("FA", 1), first.Invoices[0].Number)
+		assert.Equal(t, invoiceNumber("FA", 2), second.Invoices[0].Number)
+		assert.Equal(t, models.KindInvoice, second.Invoices[0].Kind)
+		assert.Equal(t, eur(72000), second.Invoices[0].Gross)
+		assert.Equal(t, eur(12000), second.Invoices[0].Tax)
+		assert.Equal(t, eur(60000), second.Invoices[0].Net)
+	}
+
+	resp := getDocument(router, fmt.Sprintf("/orders/%d/invoice", second.ID))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "application/pdf", resp.Header().Get("Content-Type"))
+	assert.Contains(t, resp.Header().Get("Content-Disposition"), invoiceNumber("FA", 2)+".pdf")
+	assert.True(t, bytes.HasPrefix(resp.Body.Bytes(), []byte("%PDF-")))
+	assert.Contains(t, resp.Body.String(), "FACTURE")
+	assert.Contains(t, resp.Body.String(), "720.00 EUR")
+	assert.Contains(t, resp.Body.String(), "%%EOF")
+
+	// La facture d'un autre client n'est pas accessible
+	assert.Equal(t, http.StatusNotFound, getDocument(orderItemsRouter(2), fmt.Sprintf("/orders/%d/invoice", second.ID)).Code)
+}
+
+func TestCreditNotesOnRefundAndCancel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Rome", Price: eur(10000), Stock: 5, Active: true}
+	db.Create(&travel)
+	router := orderItemsRouter(1)
+	order := paidOrder(t, router, travel.ID, 1)
+	invoice := order.Invoices[0]
+	assert.Equal(t, eur(1667), invoice.Tax)
+
+	// Remboursement partiel : TVA au prorata
+	resp := adminRefund(order.ID, models.RefundInput{Amount: 3333, Reason: "Geste commercial"})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	refunded := decodeJSON[models.Order](t, resp)
+	if assert.Len(t, refunded.Invoices, 2) {
+		note := refunded.Invoices[1]
+		assert.Equal(t, invoiceNumber("AV", 1), note.Number)
+		assert.Equal(t, models.KindCreditNote, note.Kind)
+		assert.Equal(t, invoice.ID, *note.CreditedInvoiceID)
+		assert.Equal(t, eur(3333), note.Gross)
+		assert.Equal(t, eur(556), note.Tax)
+		assert.Equal(t, "Geste commercial", note.Reason)
+	}
+
+	// L'annulation rembourse le solde : les avoirs reprennent toute la TVA facturée
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/orders/%d/cancel", order.ID), nil)
+	cancelResp := httptest.NewRecorder()
+	router.ServeHTTP(cancelResp, req)
+	assert.Equal(t, http.StatusOK, cancelResp.Code, cancelResp.Body.String())
+
+	var notes []models.Invoice
+	db.Where("order_id = ? AND kind = ?", order.ID, models.KindCreditNote).Order("id").Find(&notes)
+	if assert.Len(t, notes, 2) {
+		assert.Equal(t, invoiceNumber("AV", 2), notes[1].Number)
+		assert.Equal(t, eur(6667), notes[1].Gross)
+		assert.Equal(t, invoice.Tax.Amount, notes[0].Tax.Amount+notes[1].Tax.Amount)
+		assert.Equal(t, invoice.Net.Amount, notes[0].Net.Amount+notes[1].Net.Amount)
+	}
+
+	pdfResp := getDocument(router, fmt.Sprintf("/orders/%d/credit-notes/%s", order.ID, invoiceNumber("AV", 2)))
+	assert.Equal(t, http.StatusOK, pdfResp.Code)
+	assert.Contains(t, pdfResp.Body.String(), "AVOIR")
+	assert.Contains(t, pdfResp.Body.String(), invoice.Number)
+	assert.Equal(t, http.StatusNotFound, getDocument(router, fmt.Sprintf("/orders/%d/credit-notes/AV-1999-000001", order.ID)).Code)
+}
+
+// Un remboursement validé entre le chargement de la commande et la mise à jour
+// de son solde compte pour décider si l'avoir solde la commande.
+func TestFinalCreditNoteAfterConcurrentRefund(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Rome", Price: eur(10000), Stock: 5, Active: true}
+	db.Create(&travel)
+	order := paidOrder(t, orderItemsRouter(1), travel.ID, 1)
+	invoice := order.Invoices[0]
+
+	// L'autre remboursement passe juste avant la mise à jour conditionnelle du solde
+	concurrent := false
+	_ = db.Callback().Update().Before("gorm:update").Register("test:concurrent_refund", func(tx *gorm.DB) {
+		if concurrent || tx.Statement.Table != "orders" {
+			return
+		}
+		concurrent = true
+		other := tx.Session(&gorm.Session{NewDB: true})
+		other.Exec("UPDATE orders SET refunded_minor = refunded_minor + 3333 WHERE id = ?", order.ID)
+		other.Create(&models.Invoice{Number: "AV-concurrent", Kind: models.KindCreditNote, OrderID: order.ID,
+			CreditedInvoiceID: &invoice.ID, Gross: eur(3333), Tax: eur(1000), Net: eur(2333), IssuedAt: time.Now()})
+	})
+
+	resp := adminRefund(order.ID, models.RefundInput{Amount: 6667, Reason: "Solde"})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.True(t, concurrent)
+
+	refunded := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, models.StatusRefunded, refunded.Statut)
+
+	var credited int64
+	db.Model(&models.Invoice{}).Where("order_id = ? AND kind = ?", order.ID, models.KindCreditNote).
+		Select("SUM(tax_minor)").Scan(&credited)
+	assert.Equal(t, invoice.Tax.Amount, credited)
+}
+
+func TestAdminTaxRates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	SetupSQLiteDB(t)
+
+	router := gin.New()
+	router.GET("/admin/tax-rates", controllers.AdminListTaxRates)
+	router.PUT("/admin/tax-rates/:category", controllers.AdminSetTaxRate)
+	setTaxRate := func(category string, input interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(input)
+		req := httptest.NewRequest("PUT", "/admin/tax-rates/"+category, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	rate := 550
+	assert.Equal(t, http.StatusOK, setTaxRate(models.TaxIntermediate, models.TaxRateInput{Rate: &rate}).Code)
+	rate = 600
+	assert.Equal(t, http.StatusOK, setTaxRate(models.TaxIntermediate, models.TaxRateInput{Rate: &rate}).Code)
+	assert.Equal(t, http.StatusNotFound, setTaxRate("luxe", models.TaxRateInput{Rate: &rate}).Code)
+	assert.Equal(t, http.StatusBadRequest, setTaxRate(models.TaxReduced, map[string]int{}).Code)
+	zero := 0
+	assert.Equal(t, http.StatusOK, setTaxRate(models.TaxReduced, models.TaxRateInput{Rate: &zero}).Code)
+
+	rates := decodeJSON[[]models.TaxRate](t, getDocument(router, "/admin/tax-rates"))
+	byCategory := make(map[string]int)
+	for _, r := range rates {
+		byCategory[r.Category] = r.Rate
+	}
+	assert.Len(t, rates, 5)
+	assert.Equal(t, 2000, byCategory[models.TaxStandard])
+	assert.Equal(t, 600, byCategory[models.TaxIntermediate])
+	assert.Equal(t, 0, byCategory[models.TaxReduced])
+}
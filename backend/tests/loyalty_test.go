@@ -0,0 +1,257 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"h3-travel/controllers"
+	"h3-travel/models"
+	"h3-travel/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func loyaltyRequest(userID uint, method, path string, input interface{}) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", userID) })
+	router.GET("/me/loyalty", controllers.GetLoyalty)
+	router.GET("/admin/loyalty", controllers.AdminGetLoyaltyProgram)
+	router.PUT("/admin/loyalty", controllers.AdminSetLoyaltyProgram)
+
+	return jsonRequest(router, method, path, input)
+}
+
+func loyaltyOf(t *testing.T, userID uint) models.LoyaltySummary {
+	return decodeOK[models.LoyaltySummary](t, loyaltyRequest(userID, "GET", "/me/loyalty", nil))
+}
+
+func orderWithPoints(userID uint, points int, items ...models.OrderItemInput) *httptest.ResponseRecorder {
+	return jsonRequest(orderItemsRouter(userID), "POST", "/orders", models.CreateOrderInput{Items: items, LoyaltyPoints: points})
+}
+
+func TestLoyaltyPointsEarnedOnPaymentAndReversed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Rome", Price: eur(30000), Stock: 5, Active: true}
+	db.Create(&travel)
+	router := orderItemsRouter(1)
+
+	// Rien n'est gagné avant le paiement
+	hold := createHold(t, router, travel.ID, 1)
+	assert.Zero(t, loyaltyOf(t, 1).Points)
+	assert.Equal(t, http.StatusOK, payOrder(t, router, hold.ID, "4242424242424242").Code)
+
+	summary := loyaltyOf(t, 1)
+	assert.Equal(t, 300, summary.Points)
+	assert.Equal(t, eur(300), summary.Value)
+	assert.Equal(t, "Bronze", summary.Tier)
+	assert.Equal(t, eur(30000), summary.YearlySpend)
+	assert.Equal(t, "Argent", summary.NextTier)
+	if assert.NotNil(t, summary.NextTierGap) {
+		assert.Equal(t, eur(70000), *summary.NextTierGap)
+	}
+	if assert.Len(t, summary.Transactions, 1) {
+		assert.Equal(t, models.LoyaltyEarn, summary.Transactions[0].Kind)
+		assert.Equal(t, hold.ID, *summary.Transactions[0].OrderID)
+		assert.NotNil(t, summary.Transactions[0].ExpiresAt)
+	}
+
+	// Annulée, la commande ne rapporte plus rien et ne compte plus pour le niveau
+	assert.Equal(t, http.StatusOK, cancelOrderRequest(1, hold.ID, nil).Code)
+	summary = loyaltyOf(t, 1)
+	assert.Zero(t, summary.Points)
+	assert.Equal(t, eur(0), summary.YearlySpend)
+	if assert.Len(t, summary.Transactions, 2) {
+		assert.Equal(t, models.LoyaltyReverse, summary.Transactions[0].Kind)
+		assert.Equal(t, -300, summary.Transactions[0].Points)
+	}
+
+	// Un remboursement total reprend aussi les points
+	paid := paidOrder(t, router, travel.ID, 2)
+	assert.Equal(t, 600, loyaltyOf(t, 1).Points)
+	resp := adminRefund(paid.ID, models.RefundInput{})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Zero(t, loyaltyOf(t, 1).Points)
+
+	// Les points d'un autre utilisateur sont distincts
+	assert.Zero(t, loyaltyOf(t, 2).Points)
+	assert.Empty(t, loyaltyOf(t, 2).Transactions)
+}
+
+func TestLoyaltyPointsSpentAsDiscount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	big := models.Travel{Title: "Tokyo", Price: eur(100000), Stock: 5, Active: true}
+	rome := models.Travel{Title: "Rome", Price: eur(3000), Stock: 5, Active: true}
+	nice := models.Travel{Title: "Nice", Price: eur(1000), Stock: 5, Active: true}
+	db.Create(&big)
+	db.Create(&rome)
+	db.Create(&nice)
+	router := orderItemsRouter(1)
+	paidOrder(t, router, big.ID, 1)
+	assert.Equal(t, 1000, loyaltyOf(t, 1).Points)
+
+	// Solde insuffisant : rien n'est réservé
+	resp := orderWithPoints(1, 1001, models.OrderItemInput{TravelID: nice.ID, Quantity: 1})
+	assert.Equal(t, http.StatusBadRequest, resp.Code, resp.Body.String())
+	assert.Equal(t, 5, stockOf(db, nice.ID))
+
+	// La remise est plafonnée à 50 % du total : 500 points sur 10 €
+	resp = orderWithPoints(1, 1000, models.OrderItemInput{TravelID: nice.ID, Quantity: 1})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	capped := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, 500, capped.LoyaltyPoints)
+	assert.Equal(t, eur(500), capped.LoyaltyDiscount)
+	assert.Equal(t, eur(500), capped.Total)
+	assert.Equal(t, 500, loyaltyOf(t, 1).Points)
+
+	// Répartie au prorata des lignes
+	resp = orderWithPoints(1, 500,
+		models.OrderItemInput{TravelID: rome.ID, Quantity: 1},
+		models.OrderItemInput{TravelID: nice.ID, Quantity: 1})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	split := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, eur(3500), split.Total)
+	assert.Equal(t, eur(0), split.Discount)
+	if assert.Len(t, split.Items, 2) {
+		assert.Equal(t, eur(375), split.Items[0].Discount)
+		assert.Equal(t, eur(125), split.Items[1].Discount)
+	}
+	summary := loyaltyOf(t, 1)
+	assert.Zero(t, summary.Points)
+	assert.Equal(t, models.LoyaltyBurn, summary.Transactions[0].Kind)
+
+	// Une réservation annulée ou expirée rend ses points
+	assert.Equal(t, http.StatusOK, cancelOrderRequest(1, capped.ID, nil).Code)
+	assert.Equal(t, 500, loyaltyOf(t, 1).Points)
+	expireNow(db, split.ID)
+	_, err := services.ExpireHolds(context.Background(), db, time.Now(), time.Hour)
+	assert.NoError(t, err)
+	summary = loyaltyOf(t, 1)
+	assert.Equal(t, 1000, summary.Points)
+	assert.Equal(t, models.LoyaltyRestore, summary.Transactions[0].Kind)
+
+	// La remise figure sur la facture ; les points se gagnent sur le montant payé,
+	// avec le bonus du niveau Argent atteint par la première commande (25 € : 31 points)
+	resp = orderWithPoints(1, 500, models.OrderItemInput{TravelID: rome.ID, Quantity: 1})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	order := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, http.StatusOK, payOrder(t, router, order.ID, "4242424242424242").Code)
+	assert.Equal(t, 500+31, loyaltyOf(t, 1).Points)
+	invoice := getDocument(router, fmt.Sprintf("/orders/%d/invoice", order.ID))
+	assert.Equal(t, http.StatusOK, invoice.Code)
+	assert.Contains(t, invoice.Body.String(), "500 points fid")
+}
+
+func TestLoyaltyProgramTiers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	resp := loyaltyRequest(99, "GET", "/admin/loyalty", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	program := decodeJSON[models.LoyaltyProgram](t, resp)
+	assert.Equal(t, 1, program.EarnRate)
+	assert.Len(t, program.Tiers, 3)
+
+	assert.Equal(t, http.StatusBadRequest, loyaltyRequest(99, "PUT", "/admin/loyalty", models.LoyaltyProgramInput{EarnRate: 2}).Code)
+
+	resp = loyaltyRequest(99, "PUT", "/admin/loyalty", models.LoyaltyProgramInput{
+		EarnRate:       2,
+		PointValue:     1,
+		MaxBurnPercent: 20,
+		ExpiryMonths:   12,
+		Tiers: []models.LoyaltyTierInput{
+			{Name: "Gold", MinYearlySpend: 50000, BonusPercent: 50},
+			{Name: "Standard", MinYearlySpend: 0},
+		},
+	})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	resp = loyaltyRequest(99, "GET", "/admin/loyalty", nil)
+	program = decodeJSON[models.LoyaltyProgram](t, resp)
+	assert.Equal(t, 2, program.EarnRate)
+	if assert.Len(t, program.Tiers, 2) {
+		assert.Equal(t, "Standard", program.Tiers[0].Name)
+	}
+
+	travel := models.Travel{Title: "Rome", Price: eur(30000), Stock: 5, Active: true}
+	db.Create(&travel)
+	router := orderItemsRouter(1)
+
+	// 600 € : 1 200 points au niveau Standard, puis 50 % de bonus au niveau Gold
+	paidOrder(t, router, travel.ID, 2)
+	summary := loyaltyOf(t, 1)
+	assert.Equal(t, 1200, summary.Points)
+	assert.Equal(t, "Gold", summary.Tier)
+	assert.Empty(t, summary.NextTier)
+	assert.Nil(t, summary.NextTierGap)
+
+	paidOrder(t, router, travel.ID, 1)
+	assert.Equal(t, 1200+900, loyaltyOf(t, 1).Points)
+}
+
+func TestExpireLoyaltyPoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Rome", Price: eur(30000), Stock: 5, Active: true}
+	nice := models.Travel{Title: "Nice", Price: eur(1000), Stock: 5, Active: true}
+	db.Create(&travel)
+	db.Create(&nice)
+	router := orderItemsRouter(1)
+
+	first := paidOrder(t, router, travel.ID, 1)
+	paidOrder(t, router, travel.ID, 1)
+	assert.Equal(t, http.StatusOK, orderWithPoints(1, 100, models.OrderItemInput{TravelID: nice.ID, Quantity: 1}).Code)
+	assert.Equal(t, 500, loyaltyOf(t, 1).Points)
+
+	// Les 300 points de la première commande expirent ; les 100 déjà dépensés sont pris sur ceux-là
+	db.Exec("UPDATE loyalty_transactions SET expires_at = ? WHERE order_id = ? AND kind = ?",
+		time.Now().Add(-time.Hour), first.ID, models.LoyaltyEarn)
+
+	expired, err := services.ExpireLoyaltyPoints(context.Background(), db, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	summary := loyaltyOf(t, 1)
+	assert.Equal(t, 300, summary.Points)
+	if assert.NotEmpty(t, summary.Transactions) {
+		assert.Equal(t, models.LoyaltyExpire, summary.Transactions[0].Kind)
+		assert.Equal(t, -200, summary.Transactions[0].Points)
+	}
+
+	// Un second passage n'expire rien de plus
+	expired, err = services.ExpireLoyaltyPoints(context.Background(), db, time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, expired)
+	assert.Equal(t, 300, loyaltyOf(t, 1).Points)
+
+	// Le registre est en ajout seul
+	assert.ErrorIs(t, db.Model(&summary.Transactions[0]).Update("points", 0).Error, models.ErrLedgerAppendOnly)
+
+	// La reprise des points d'une commande annulée ne consomme pas ceux des autres commandes
+	other := orderItemsRouter(2)
+	kept := paidOrder(t, other, travel.ID, 1)
+	cancelled := paidOrder(t, other, travel.ID, 1)
+	assert.Equal(t, http.StatusOK, cancelOrderRequest(2, cancelled.ID, nil).Code)
+	assert.Equal(t, 300, loyaltyOf(t, 2).Points)
+
+	db.Exec("UPDATE loyalty_transactions SET expires_at = ? WHERE order_id = ? AND kind = ?",
+		time.Now().Add(-time.Hour), kept.ID, models.LoyaltyEarn)
+	_, err = services.ExpireLoyaltyPoints(context.Background(), db, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, loyaltyOf(t, 2).Points)
+
+	// À leur tour échus, les points repris n'expirent pas une seconde fois
+	db.Exec("UPDATE loyalty_transactions SET expires_at = ? WHERE order_id = ?",
+		time.Now().Add(-time.Hour), cancelled.ID)
+	expired, err = services.ExpireLoyaltyPoints(context.Background(), db, time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, expired)
+	assert.Equal(t, 0, loyaltyOf(t, 2).Points)
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"testing"
+
+	"h3-travel/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoneyArithmetic(t *testing.T) {
+	price := money.New(29999, "EUR")
+
+	assert.Equal(t, money.New(89997, "EUR"), price.Mul(3))
+	assert.Equal(t, money.New(30999, "EUR"), price.Add(money.New(1000, "EUR")))
+	assert.Equal(t, money.New(29999, "EUR"), price.Sub(money.Money{}))
+	assert.Equal(t, money.New(15000, "EUR"), price.Percent(50)) // 149.995 arrondi au centime supérieur
+	assert.Equal(t, money.New(-15000, "EUR"), money.New(-29999, "EUR").Percent(50))
+	assert.Equal(t, money.New(1000, "EUR"), price.Min(money.New(1000, "EUR")))
+
+	assert.Panics(t, func() { price.Add(money.New(100, "USD")) })
+
+	sum, err := price.CheckedAdd(money.New(1, "EUR"))
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(30000, "EUR"), sum)
+	_, err = price.CheckedSub(money.New(100, "USD"))
+	assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+	_, err = price.CheckedMin(money.New(100, "USD"))
+	assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+}
+
+func TestMoneyTaxAndProrate(t *testing.T) {
+	assert.Equal(t, money.New(2000, "EUR"), money.New(12000, "EUR").IncludedTax(2000))
+	assert.Equal(t, money.New(1667, "EUR"), money.New(10000, "EUR").IncludedTax(2000)) // HT 83.33
+	assert.Equal(t, money.New(521, "EUR"), money.New(10000, "EUR").IncludedTax(550))
+	assert.Equal(t, money.New(0, "EUR"), money.New(10000, "EUR").IncludedTax(0))
+
+	assert.Equal(t, money.New(556, "EUR"), money.New(1667, "EUR").Prorate(3333, 10000))
+	assert.Equal(t, money.New(0, "EUR"), money.New(1667, "EUR").Prorate(1, 0))
+}
+
+func TestMoneyStringAndConvert(t *testing.T) {
+	assert.Equal(t, "299.99 EUR", money.New(29999, "EUR").String())
+	assert.Equal(t, "-0.05 EUR", money.New(-5, "EUR").String())
+	assert.Equal(t, "1500 JPY", money.New(1500, "JPY").String())
+
+	assert.Equal(t, money.New(10850, "USD"), money.New(10000, "EUR").Convert("USD", 1.085))
+	assert.Equal(t, money.New(16250, "JPY"), money.New(10000, "EUR").Convert("JPY", 162.5))
+	assert.Equal(t, money.New(6154, "EUR"), money.New(10000, "JPY").Convert("EUR", 1/162.5))
+}
+
+func TestNormalizeCurrency(t *testing.T) {
+	code, err := money.NormalizeCurrency(" usd ")
+	assert.NoError(t, err)
+	assert.Equal(t, "USD", code)
+
+	for _, invalid := range []string{"", "EU", "EURO", "E1R"} {
+		_, err := money.NormalizeCurrency(invalid)
+		assert.ErrorIs(t, err, money.ErrInvalidCurrency, invalid)
+	}
+}
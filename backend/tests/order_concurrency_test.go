@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"h3-travel/controllers"
+	"h3-travel/models"
+	"h3-travel/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Lance de nombreuses commandes simultanées sur un travel de N places :
+// exactement N doivent aboutir et le stock doit finir à 0.
+func TestCreateOrderConcurrentNoOversell(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	const seats = 5
+	const buyers = 40
+
+	travel := models.Travel{Title: "Week-end à Lisbonne", Price: money.New(19900, "EUR"), Stock: seats, Active: true}
+	assert.NoError(t, db.Create(&travel).Error)
+
+	router := gin.New()
+	router.POST("/orders", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		controllers.CreateOrder(c)
+	})
+
+	body, _ := json.Marshal(models.CreateOrderInput{
+		Items: []models.OrderItemInput{{TravelID: travel.ID, Quantity: 1}},
+	})
+
+	var wg sync.WaitGroup
+	codes := make(chan int, buyers)
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "/orders", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+			codes <- resp.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	success, refused := 0, 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			success++
+		case http.StatusBadRequest:
+			refused++
+		}
+	}
+	assert.Equal(t, seats, success)
+	assert.Equal(t, buyers-seats, refused)
+
+	var count int64
+	db.Model(&models.OrderItem{}).Where("travel_id = ?", travel.ID).Count(&count)
+	assert.Equal(t, int64(seats), count)
+
+	var reloaded models.Travel
+	db.First(&reloaded, travel.ID)
+	assert.Equal(t, 0, reloaded.Stock)
+}
@@ -25,27 +25,64 @@ func TestCreateOrderWithMockDB(t *testing.T) {
 	travelID := uint(2)
 	now := time.Now()
 
+	mock.ExpectBegin()
+	// Règles de tarification : aucune
+	mock.ExpectQuery(`SELECT \* FROM "pricing_rules" WHERE \(active = \$1 AND \(travel_id IS NULL OR travel_id IN \(\$2\)\)\)`).
+		WithArgs(true, travelID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	// Taux de TVA : ceux par défaut
+	mock.ExpectQuery(`SELECT \* FROM "tax_rates"`).
+		WillReturnRows(sqlmock.NewRows([]string{"category", "rate"}))
+
+	// Décrément conditionnel du stock
+	mock.ExpectExec(`UPDATE "travels" SET "stock"=stock - \$1 WHERE \(id = \$2 AND stock >= \$3 AND active = \$4\)`).
+		WithArgs(2, travelID, 2, true).
+		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectQuery(`SELECT \* FROM "travels" WHERE "travels"\."id" = \$1 AND "travels"\."deleted_at" IS NULL ORDER BY "travels"\."id" LIMIT \$2`).
 		WithArgs(int64(travelID), 1).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "stock", "active", "created_at", "updated_at"}).
-			AddRow(travelID, "Test Trip", 10, true, now, now))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "price_minor", "price_currency", "stock", "active", "created_at", "updated_at"}).
+			AddRow(travelID, "Test Trip", 10000, "EUR", 8, true, now, now))
+	// Sans départ daté, le travel se réserve sur son stock global
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "departures" WHERE travel_id = \$1`).
+		WithArgs(travelID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
 
-	mock.ExpectBegin()
 	mock.ExpectQuery(`INSERT INTO "orders" .* RETURNING "id"`).
 		WithArgs(
-			sqlmock.AnyArg(), // created_at
-			sqlmock.AnyArg(), // updated_at
-			nil,              // deleted_at
-			userID,           // user_id
-			travelID,         // travel_id
-			"paid",           // statut
+			sqlmock.AnyArg(),  // created_at
+			sqlmock.AnyArg(),  // updated_at
+			nil,               // deleted_at
+			userID,            // user_id
+			"pending_payment", // statut
+			int64(20000),      // total_minor
+			"EUR",             // total_currency
+			"",                // promo_code
+			int64(0),          // discount_minor
+			"EUR",             // discount_currency
+			int64(3333),       // tax_minor : TVA à 20 % comprise dans 200 €
+			"EUR",             // tax_currency
+			sqlmock.AnyArg(),  // expires_at
+			"",                // payment_provider
+			"",                // payment_transaction_id
+			"",                // card_brand
+			"",                // card_last4
+			int64(0),          // paid_minor
+			"EUR",             // paid_currency
+			int64(0),          // refunded_minor
+			"EUR",             // refunded_currency
+			int64(0),          // wallet_minor
+			"EUR",             // wallet_currency
+			0,                 // loyalty_points
+			int64(0),          // loyalty_discount_minor
+			"EUR",             // loyalty_discount_currency
 		).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
-	mock.ExpectCommit()
-
-	mock.ExpectBegin()
-	mock.ExpectExec(`UPDATE "travels"`).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`INSERT INTO "order_items" .* RETURNING "id"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`INSERT INTO "order_histories" .* RETURNING "id"`).
+		WithArgs(uint(1), "", "pending_payment", "user:1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectCommit()
 
 	router := gin.Default()
@@ -55,8 +92,7 @@ func TestCreateOrderWithMockDB(t *testing.T) {
 	})
 
 	input := models.CreateOrderInput{
-		TravelID: travelID,
-		Card:     "4242424242424242",
+		Items: []models.OrderItemInput{{TravelID: travelID, Quantity: 2}},
 	}
 	body, _ := json.Marshal(input)
 	req := httptest.NewRequest("POST", "/orders", bytes.NewBuffer(body))
@@ -65,12 +101,16 @@ func TestCreateOrderWithMockDB(t *testing.T) {
 	router.ServeHTTP(resp, req)
 
 	assert.Equal(t, http.StatusOK, resp.Code)
-	var result models.Order
-	_ = json.Unmarshal(resp.Body.Bytes(), &result)
+	result := decodeJSON[models.Order](t, resp)
 	assert.Equal(t, uint(1), result.ID)
 	assert.Equal(t, userID, result.UserID)
-	assert.Equal(t, travelID, result.TravelID)
-	assert.Equal(t, "paid", result.Statut)
+	assert.Equal(t, models.StatusPendingPayment, result.Statut)
+	assert.NotNil(t, result.ExpiresAt)
+	assert.Equal(t, eur(20000), result.Total)
+	if assert.Len(t, result.Items, 1) {
+		assert.Equal(t, travelID, result.Items[0].TravelID)
+		assert.Equal(t, 2, result.Items[0].Quantity)
+	}
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -90,9 +130,23 @@ func TestGetUserOrdersWithMockDB(t *testing.T) {
 	// Mock SELECT orders
 	mock.ExpectQuery(`SELECT \* FROM "orders" WHERE user_id = \$1`).
 		WithArgs(userID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "travel_id", "statut", "created_at", "updated_at"}).
-			AddRow(1, userID, travelID, "paid", now, now).
-			AddRow(2, userID, travelID, "paid", now, now))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "statut", "created_at", "updated_at"}).
+			AddRow(1, userID, "paid", now, now).
+			AddRow(2, userID, "paid", now, now))
+
+	// Mock SELECT invoices (Preload, dans l'ordre alphabétique des associations)
+	mock.ExpectQuery(`SELECT \* FROM "invoices" WHERE "invoices"\."order_id" IN \(\$1,\$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "number", "kind"}))
+
+	// Mock SELECT order_items (Preload)
+	mock.ExpectQuery(`SELECT \* FROM "order_items" WHERE "order_items"\."order_id" IN \(\$1,\$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "travel_id", "quantity"}).
+			AddRow(1, 1, travelID, 1).
+			AddRow(2, 2, travelID, 3))
+
+	// Mock SELECT refunds (Preload)
+	mock.ExpectQuery(`SELECT \* FROM "refunds" WHERE "refunds"\."order_id" IN \(\$1,\$2\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "amount_minor", "amount_currency"}))
 
 	router := gin.Default()
 	router.GET("/orders/user", func(c *gin.Context) {
@@ -106,11 +160,12 @@ func TestGetUserOrdersWithMockDB(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, resp.Code)
 
-	var orders []models.Order
-	_ = json.Unmarshal(resp.Body.Bytes(), &orders)
+	orders := decodeJSON[[]models.Order](t, resp)
 	assert.Len(t, orders, 2)
 	assert.Equal(t, userID, orders[0].UserID)
 	assert.Equal(t, userID, orders[1].UserID)
+	assert.Len(t, orders[1].Items, 1)
+	assert.Equal(t, 3, orders[1].Items[0].Quantity)
 }
 
 // ----------------------
@@ -125,27 +180,55 @@ func TestCancelOrderWithMockDB(t *testing.T) {
 	orderID := uint(1)
 	userID := uint(1)
 	travelID := uint(2)
+	otherTravelID := uint(3)
 	now := time.Now()
 
+	mock.ExpectBegin()
 	mock.ExpectQuery(`SELECT \* FROM "orders" WHERE \(id = \$1 AND user_id = \$2\) AND "orders"\."deleted_at" IS NULL ORDER BY "orders"\."id" LIMIT \$3`).
-		WithArgs("1", userID, 1).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "travel_id", "statut", "created_at", "updated_at", "deleted_at"}).
-			AddRow(orderID, userID, travelID, "paid", now, now, nil))
+		WithArgs(orderID, userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "statut", "created_at", "updated_at", "deleted_at"}).
+			AddRow(orderID, userID, "paid", now, now, nil))
+	mock.ExpectQuery(`SELECT \* FROM "order_items" WHERE "order_items"\."order_id" = \$1`).
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "travel_id", "quantity"}).
+			AddRow(1, orderID, travelID, 2).
+			AddRow(2, orderID, otherTravelID, 1))
+
+	// Transition conditionnelle paid -> cancelled puis historique
+	mock.ExpectExec(`UPDATE "orders" SET "statut"=\$1,"updated_at"=\$2 WHERE \(id = \$3 AND statut = \$4\)`).
+		WithArgs("cancelled", sqlmock.AnyArg(), orderID, "paid").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "order_histories" .* RETURNING "id"`).
+		WithArgs(orderID, "paid", "cancelled", "user:1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
-	mock.ExpectBegin()
-	mock.ExpectExec(`UPDATE "orders" SET`).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	// Aucun point de fidélité gagné ni dépensé avec cette commande
+	mock.ExpectQuery(`SELECT \* FROM "loyalty_transactions" WHERE order_id = \$1`).
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	// Chaque ligne est remise en stock
+	mock.ExpectExec(`UPDATE "travels" SET "stock"=stock \+ \$1 WHERE id = \$2`).
+		WithArgs(2, travelID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "travels" SET "stock"=stock \+ \$1 WHERE id = \$2`).
+		WithArgs(1, otherTravelID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// Aucune offre de liste d'attente liée à cette commande
+	mock.ExpectExec(`UPDATE "waitlist_entries" SET "status"=\$1,"updated_at"=\$2 WHERE order_id = \$3 AND status = \$4`).
+		WithArgs("declined", sqlmock.AnyArg(), orderID, "offered").
+		WillReturnResult(sqlmock.NewResult(0, 0))
 	mock.ExpectCommit()
 
-	mock.ExpectQuery(`SELECT \* FROM "travels" WHERE "travels"\."id" = \$1 AND "travels"\."deleted_at" IS NULL ORDER BY "travels"\."id" LIMIT \$2`).
-		WithArgs(int64(travelID), 1).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "stock", "active", "created_at", "updated_at", "deleted_at"}).
-			AddRow(travelID, "Test Trip", 9, true, now, now, nil))
-
-	mock.ExpectBegin()
-	mock.ExpectExec(`UPDATE "travels"`).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectCommit()
+	// Les places libérées sont proposées à la liste d'attente, vide ici
+	for _, id := range []uint{travelID, otherTravelID} {
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT \* FROM "waitlist_entries" WHERE travel_id = \$1 AND status = \$2`).
+			WithArgs(id, "waiting", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+	}
 
 	router := gin.Default()
 	router.PUT("/orders/:id/cancel", func(c *gin.Context) {
@@ -159,9 +242,8 @@ func TestCancelOrderWithMockDB(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, resp.Code)
 
-	var result models.Order
-	_ = json.Unmarshal(resp.Body.Bytes(), &result)
-	assert.Equal(t, "cancelled", result.Statut)
+	result := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, models.StatusCancelled, result.Statut)
 	assert.Equal(t, orderID, result.ID)
 	assert.Equal(t, userID, result.UserID)
 
@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"h3-travel/config"
+	"h3-travel/models"
+	"h3-travel/money"
+	"h3-travel/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func createHold(t *testing.T, router *gin.Engine, travelID uint, quantity int) models.Order {
+	return decodeOK[models.Order](t, jsonRequest(router, "POST", "/orders", models.CreateOrderInput{
+		Items: []models.OrderItemInput{{TravelID: travelID, Quantity: quantity}},
+	}))
+}
+
+func tokenizeCard(router *gin.Engine, number string) *httptest.ResponseRecorder {
+	return jsonRequest(router, "POST", "/cards/tokenize", models.TokenizeCardInput{
+		Card:     number,
+		ExpMonth: 12,
+		ExpYear:  time.Now().Year() + 2,
+		CVV:      "123",
+	})
+}
+
+// payOrder tokenise la carte puis paie la commande avec le token obtenu
+func payOrder(t *testing.T, router *gin.Engine, orderID uint, number string) *httptest.ResponseRecorder {
+	tokenized := tokenizeCard(router, number)
+	if tokenized.Code != http.StatusOK {
+		return tokenized
+	}
+	token := decodeJSON[models.CardToken](t, tokenized)
+
+	return payWithToken(router, orderID, token.Token)
+}
+
+func payWithToken(router *gin.Engine, orderID uint, token string) *httptest.ResponseRecorder {
+	return jsonRequest(router, "POST", fmt.Sprintf("/orders/%d/pay", orderID), models.ConfirmPaymentInput{CardToken: token})
+}
+
+func expireNow(db *gorm.DB, orderID uint) {
+	db.Model(&models.Order{}).Where("id = ?", orderID).Update("expires_at", time.Now().Add(-time.Minute))
+}
+
+func TestConfirmPaymentPaysHold(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Rome", Price: money.New(30000, "EUR"), Stock: 3, Active: true}
+	db.Create(&travel)
+	router := orderItemsRouter(1)
+
+	order := createHold(t, router, travel.ID, 2)
+	assert.Equal(t, models.StatusPendingPayment, order.Statut)
+	assert.Equal(t, 1, stockOf(db, travel.ID))
+
+	resp := payOrder(t, router, order.ID, "1234")
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	resp = payOrder(t, router, order.ID, "4242424242424242")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	paid := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, models.StatusPaid, paid.Statut)
+
+	// Une commande payée n'est plus concernée par l'expiration
+	expireNow(db, order.ID)
+	expired, err := services.ExpireHolds(context.Background(), db, time.Now(), time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, expired)
+	assert.Equal(t, 1, stockOf(db, travel.ID))
+}
+
+func TestExpiredHoldIsReleased(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Ski", Price: money.New(50000, "EUR"), Stock: 2, Active: true}
+	db.Create(&travel)
+	router := orderItemsRouter(1)
+
+	order := createHold(t, router, travel.ID, 2)
+	assert.Equal(t, 0, stockOf(db, travel.ID))
+
+	expireNow(db, order.ID)
+
+	// Paiement refusé une fois le délai dépassé
+	resp := payOrder(t, router, order.ID, "4242424242424242")
+	assert.Equal(t, http.StatusGone, resp.Code)
+
+	expired, err := services.ExpireHolds(context.Background(), db, time.Now(), time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	assert.Equal(t, 2, stockOf(db, travel.ID))
+
+	// Un second passage (autre instance par exemple) ne restocke pas deux fois
+	expired, err = services.ExpireHolds(context.Background(), db, time.Now(), time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, expired)
+	assert.Equal(t, 2, stockOf(db, travel.ID))
+
+	var reloaded models.Order
+	db.First(&reloaded, order.ID)
+	assert.Equal(t, models.StatusExpired, reloaded.Statut)
+}
+
+func TestSchedulerStopsOnContextCancel(t *testing.T) {
+	runs := make(chan struct{}, 1)
+
+	scheduler := services.NewScheduler()
+	scheduler.Every("test", 5*time.Millisecond, func(ctx context.Context) error {
+		select {
+		case runs <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduler.Start(ctx)
+
+	select {
+	case <-runs:
+	case <-time.After(time.Second):
+		t.Fatal("le job n'a pas été exécuté")
+	}
+
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		scheduler.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("le scheduler ne s'est pas arrêté")
+	}
+}
+
+// La capture a lieu avant la transaction : si celle-ci échoue, le client est
+// remboursé au lieu d'une annulation d'autorisation déjà capturée.
+func TestConfirmPaymentRefundsCaptureWhenCommitFails(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+	spy := newSpyProvider()
+	config.Payment = spy
+
+	travel := models.Travel{Title: "Rome", Price: money.New(30000, "EUR"), Stock: 3, Active: true}
+	db.Create(&travel)
+	router := orderItemsRouter(1)
+	order := createHold(t, router, travel.ID, 1)
+
+	// Annulée pendant l'appel au prestataire : la transition vers payé échoue
+	spy.onCapture = func() {
+		db.Model(&models.Order{}).Where("id = ?", order.ID).Update("statut", models.StatusCancelled)
+	}
+	resp := payOrder(t, router, order.ID, "4242424242424242")
+	assert.Equal(t, http.StatusConflict, resp.Code, resp.Body.String())
+	assert.Equal(t, []string{"authorize", "capture", "refund"}, spy.Calls())
+
+	var stored models.Order
+	db.First(&stored, order.ID)
+	assert.Equal(t, models.StatusCancelled, stored.Statut)
+	var payments int64
+	db.Model(&models.Payment{}).Count(&payments)
+	assert.Equal(t, int64(0), payments)
+}
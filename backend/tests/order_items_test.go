@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"h3-travel/controllers"
+	"h3-travel/models"
+	"h3-travel/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func orderItemsRouter(userID uint) *gin.Engine {
+	router := gin.New()
+	router.POST("/orders", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		controllers.CreateOrder(c)
+	})
+	router.POST("/cards/tokenize", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		controllers.TokenizeCard(c)
+	})
+	router.POST("/orders/:id/pay", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		controllers.ConfirmPayment(c)
+	})
+	router.PUT("/orders/:id/cancel", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		controllers.CancelOrder(c)
+	})
+	router.GET("/orders/:id/invoice", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		controllers.GetInvoice(c)
+	})
+	router.GET("/orders/:id/credit-notes/:number", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		controllers.GetCreditNote(c)
+	})
+	router.GET("/orders/:id/passengers", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		controllers.GetPassengers(c)
+	})
+	router.PUT("/orders/:id/passengers", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		controllers.UpdatePassengers(c)
+	})
+	return router
+}
+
+func eur(cents int64) money.Money {
+	return money.New(cents, "EUR")
+}
+
+func stockOf(db *gorm.DB, travelID uint) int {
+	var travel models.Travel
+	db.First(&travel, travelID)
+	return travel.Stock
+}
+
+// Une ligne indisponible fait échouer toute la commande sans toucher au stock des autres lignes.
+func TestCreateOrderMultiLineAllOrNothing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	rome := models.Travel{Title: "Rome", Price: money.New(30000, "EUR"), Stock: 4, Active: true}
+	ski := models.Travel{Title: "Ski", Price: money.New(50000, "EUR"), Stock: 1, Active: true}
+	db.Create(&rome)
+	db.Create(&ski)
+
+	body, _ := json.Marshal(models.CreateOrderInput{
+		Items: []models.OrderItemInput{
+			{TravelID: rome.ID, Quantity: 3},
+			{TravelID: ski.ID, Quantity: 2},
+		},
+	})
+	req := httptest.NewRequest("POST", "/orders", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	orderItemsRouter(1).ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	assert.Equal(t, 4, stockOf(db, rome.ID))
+	assert.Equal(t, 1, stockOf(db, ski.ID))
+
+	var count int64
+	db.Model(&models.Order{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+// Une commande multi-lignes calcule son total et l'annulation restocke chaque ligne.
+func TestCreateAndCancelMultiLineOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	rome := models.Travel{Title: "Rome", Price: money.New(30000, "EUR"), Stock: 4, Active: true}
+	ski := models.Travel{Title: "Ski", Price: money.New(50000, "EUR"), Stock: 2, Active: true}
+	db.Create(&rome)
+	db.Create(&ski)
+
+	router := orderItemsRouter(1)
+
+	body, _ := json.Marshal(models.CreateOrderInput{
+		Items: []models.OrderItemInput{
+			{TravelID: rome.ID, Quantity: 3},
+			{TravelID: ski.ID, Quantity: 2},
+		},
+	})
+	req := httptest.NewRequest("POST", "/orders", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	order := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, eur(190000), order.Total)
+	assert.Len(t, order.Items, 2)
+
+	assert.Equal(t, 1, stockOf(db, rome.ID))
+	assert.Equal(t, 0, stockOf(db, ski.ID))
+
+	req = httptest.NewRequest("PUT", fmt.Sprintf("/orders/%d/cancel", order.ID), nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, 4, stockOf(db, rome.ID))
+	assert.Equal(t, 2, stockOf(db, ski.ID))
+}
+
+// Une commande déjà annulée ne peut pas l'être de nouveau : erreur de transition
+// homogène et un seul restockage.
+func TestCancelOrderTwiceIsInvalidTransition(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	rome := models.Travel{Title: "Rome", Price: money.New(30000, "EUR"), Stock: 4, Active: true}
+	db.Create(&rome)
+
+	router := orderItemsRouter(1)
+
+	body, _ := json.Marshal(models.CreateOrderInput{
+		Items: []models.OrderItemInput{{TravelID: rome.ID, Quantity: 2}},
+	})
+	req := httptest.NewRequest("POST", "/orders", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	order := decodeJSON[models.Order](t, resp)
+
+	for _, expected := range []int{http.StatusOK, http.StatusConflict} {
+		req = httptest.NewRequest("PUT", fmt.Sprintf("/orders/%d/cancel", order.ID), nil)
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, expected, resp.Code)
+	}
+
+	result := decodeJSON[map[string]string](t, resp)
+	assert.Equal(t, "cancelled", result["from"])
+	assert.Equal(t, "cancelled", result["to"])
+	assert.Equal(t, 4, stockOf(db, rome.ID))
+
+	var history []models.OrderHistory
+	db.Where("order_id = ?", order.ID).Order("id").Find(&history)
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, models.StatusPendingPayment, history[0].ToStatus)
+		assert.Equal(t, models.StatusPendingPayment, history[1].FromStatus)
+		assert.Equal(t, models.StatusCancelled, history[1].ToStatus)
+		assert.Equal(t, "user:1", history[1].Actor)
+	}
+}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"h3-travel/controllers"
+	"h3-travel/models"
+	"h3-travel/money"
+	"h3-travel/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func userOrders(t *testing.T, userID uint) []models.Order {
+	router := gin.New()
+	router.GET("/orders/user", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		controllers.GetUserOrders(c)
+	})
+
+	req := httptest.NewRequest("GET", "/orders/user", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	return decodeJSON[[]models.Order](t, resp)
+}
+
+func TestOrderKeepsPurchaseSnapshotAfterTravelChanges(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Rome", Price: money.New(30000, "EUR"), Stock: 5, Active: true}
+	db.Create(&travel)
+	order := paidOrder(t, orderItemsRouter(1), travel.ID, 2)
+	assert.Equal(t, money.DefaultCurrency, order.Total.Currency)
+
+	// L'admin modifie puis supprime le travel après l'achat
+	db.Model(&travel).Updates(models.Travel{Title: "Rome (nouveau programme)", Price: money.New(45000, "EUR")})
+	db.Delete(&travel)
+
+	orders := userOrders(t, 1)
+	if assert.Len(t, orders, 1) && assert.Len(t, orders[0].Items, 1) {
+		item := orders[0].Items[0]
+		assert.Equal(t, "Rome", item.Title)
+		assert.Equal(t, eur(30000), item.UnitPrice)
+		assert.Equal(t, eur(0), item.Discount)
+		assert.Equal(t, fmt.Sprintf("/api/v1/travels/%d", travel.ID), item.TravelURL)
+		assert.Equal(t, eur(60000), orders[0].Total)
+	}
+}
+
+func TestBackfillOrderSnapshots(t *testing.T) {
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Oslo", Price: money.New(20000, "EUR"), Stock: 5, Active: true}
+	db.Create(&travel)
+	order := models.Order{UserID: 1, Statut: models.StatusPaid, Total: eur(20000),
+		Items: []models.OrderItem{{TravelID: travel.ID, Quantity: 1, UnitPrice: eur(20000)}}}
+	db.Create(&order)
+	db.Delete(&travel)
+
+	// Colonne déjà présente : la reprise n'est pas relancée au démarrage
+	assert.False(t, services.OrderSnapshotsMissing(db))
+	assert.NoError(t, db.Migrator().DropColumn(&models.OrderItem{}, "title"))
+	assert.True(t, services.OrderSnapshotsMissing(db))
+	assert.NoError(t, db.AutoMigrate(&models.OrderItem{}))
+
+	assert.NoError(t, services.BackfillOrderSnapshots(db))
+
+	var item models.OrderItem
+	db.First(&item, order.Items[0].ID)
+	assert.Equal(t, "Oslo", item.Title)
+}
@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"h3-travel/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderStatusTransitions(t *testing.T) {
+	cases := []struct {
+		from, to models.OrderStatus
+		allowed  bool
+	}{
+		{models.StatusPendingPayment, models.StatusPaid, true},
+		{models.StatusPendingPayment, models.StatusExpired, true},
+		{models.StatusPaid, models.StatusConfirmed, true},
+		{models.StatusPaid, models.StatusCancelled, true},
+		{models.StatusPaid, models.StatusCompleted, true},
+		{models.StatusConfirmed, models.StatusCompleted, true},
+		{models.StatusPaid, models.StatusPendingPayment, false},
+		{models.StatusCancelled, models.StatusPaid, false},
+		{models.StatusExpired, models.StatusPaid, false},
+		{models.StatusCompleted, models.StatusCancelled, false},
+	}
+
+	for _, tc := range cases {
+		assert.Equal(t, tc.allowed, tc.from.CanTransitionTo(tc.to), "%s -> %s", tc.from, tc.to)
+	}
+
+	var err error = &models.TransitionError{From: models.StatusCancelled, To: models.StatusPaid}
+	assert.True(t, errors.Is(err, models.ErrInvalidTransition))
+}
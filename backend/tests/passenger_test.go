@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"h3-travel/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func passenger(firstName string, expiry time.Time) models.PassengerDetails {
+	return models.PassengerDetails{
+		FirstName:      firstName,
+		LastName:       "Martin",
+		DateOfBirth:    "1990-05-17",
+		Nationality:    "fr",
+		DocumentNumber: " 19ab12345 ",
+		DocumentExpiry: expiry.Format("2006-01-02"),
+	}
+}
+
+func passengersRequest(userID uint, method string, orderID uint, input interface{}) *httptest.ResponseRecorder {
+	return jsonRequest(orderItemsRouter(userID), method, fmt.Sprintf("/orders/%d/passengers", orderID), input)
+}
+
+func TestCreateOrderWithPassengers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	departure := time.Now().AddDate(0, 2, 0)
+	travel := models.Travel{Title: "Montréal", Price: eur(90000), Stock: 5, Active: true, DepartureDate: &departure}
+	db.Create(&travel)
+	valid := departure.AddDate(1, 0, 0)
+
+	// Un voyageur pour deux places
+	resp := orderWithPromo(1, "", models.OrderItemInput{TravelID: travel.ID, Quantity: 2,
+		Passengers: []models.PassengerDetails{passenger("Alice", valid)}})
+	assert.Equal(t, http.StatusBadRequest, resp.Code, resp.Body.String())
+
+	// Passeport expiré avant le départ
+	resp = orderWithPromo(1, "", models.OrderItemInput{TravelID: travel.ID, Quantity: 2,
+		Passengers: []models.PassengerDetails{passenger("Alice", valid), passenger("Bob", departure.AddDate(0, 0, -1))}})
+	assert.Equal(t, http.StatusBadRequest, resp.Code, resp.Body.String())
+	assert.Contains(t, resp.Body.String(), "expire avant le voyage")
+
+	// Date invalide rejetée dès la validation de la saisie
+	invalid := passenger("Alice", valid)
+	invalid.DateOfBirth = "17/05/1990"
+	resp = orderWithPromo(1, "", models.OrderItemInput{TravelID: travel.ID, Quantity: 1, Passengers: []models.PassengerDetails{invalid}})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// Aucune place n'a été réservée par les tentatives refusées
+	assert.Equal(t, 5, stockOf(db, travel.ID))
+
+	resp = orderWithPromo(1, "", models.OrderItemInput{TravelID: travel.ID, Quantity: 2,
+		Passengers: []models.PassengerDetails{passenger("Alice", valid), passenger("Bob", departure)}})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	order := decodeJSON[models.Order](t, resp)
+	if assert.Len(t, order.Passengers, 2) {
+		assert.Equal(t, "19AB12345", order.Passengers[0].DocumentNumber)
+		assert.Equal(t, "FR", order.Passengers[0].Nationality)
+	}
+
+	// Les données sont chiffrées en base
+	var stored []models.Passenger
+	db.Where("order_id = ?", order.ID).Find(&stored)
+	if assert.Len(t, stored, 2) {
+		assert.NotContains(t, string(stored[0].Sealed), "Alice")
+		assert.NotContains(t, string(stored[0].Sealed), "19AB12345")
+		assert.Empty(t, stored[0].FirstName)
+	}
+
+	resp = passengersRequest(1, "GET", order.ID, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	passengers := decodeJSON[[]models.Passenger](t, resp)
+	if assert.Len(t, passengers, 2) {
+		assert.Equal(t, "Alice", passengers[0].FirstName)
+		assert.Equal(t, "Bob", passengers[1].FirstName)
+		assert.Equal(t, travel.ID, passengers[1].TravelID)
+	}
+	assert.NotContains(t, resp.Body.String(), "sealed")
+
+	// La commande d'un autre utilisateur n'est pas visible
+	assert.Equal(t, http.StatusNotFound, passengersRequest(2, "GET", order.ID, nil).Code)
+
+	// Des données recopiées depuis une autre commande ne se déchiffrent pas
+	resp = orderWithPromo(1, "", models.OrderItemInput{TravelID: travel.ID, Quantity: 1,
+		Passengers: []models.PassengerDetails{passenger("Chloé", valid)}})
+	other := decodeJSON[models.Order](t, resp)
+	var copied models.Passenger
+	db.Where("order_id = ?", other.ID).First(&copied)
+	db.Model(&models.Passenger{}).Where("id = ?", stored[0].ID).Update("sealed", copied.Sealed)
+	assert.Equal(t, http.StatusInternalServerError, passengersRequest(1, "GET", order.ID, nil).Code)
+}
+
+func TestUpdatePassengersUntilCutoff(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	departure := time.Now().AddDate(0, 1, 0)
+	soon := time.Now().Add(24 * time.Hour)
+	travel := models.Travel{Title: "Dublin", Price: eur(40000), Stock: 5, Active: true, DepartureDate: &departure}
+	imminent := models.Travel{Title: "Bruxelles", Price: eur(20000), Stock: 5, Active: true, DepartureDate: &soon}
+	db.Create(&travel)
+	db.Create(&imminent)
+	valid := departure.AddDate(2, 0, 0)
+
+	// Commande passée sans voyageurs : ils sont saisis ensuite
+	resp := orderWithPromo(1, "",
+		models.OrderItemInput{TravelID: travel.ID, Quantity: 1},
+		models.OrderItemInput{TravelID: imminent.ID, Quantity: 1})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	order := decodeJSON[models.Order](t, resp)
+	assert.Empty(t, order.Passengers)
+
+	resp = passengersRequest(1, "GET", order.ID, nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.JSONEq(t, "[]", resp.Body.String())
+
+	update := func(userID, travelID uint, details ...models.PassengerDetails) *httptest.ResponseRecorder {
+		return passengersRequest(userID, "PUT", order.ID, models.UpdatePassengersInput{
+			Items: []models.PassengerListInput{{TravelID: travelID, Passengers: details}},
+		})
+	}
+
+	resp = update(1, travel.ID, passenger("Alice", valid))
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	resp = update(1, travel.ID, passenger("Claire", valid))
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	passengers := decodeJSON[[]models.Passenger](t, resp)
+	if assert.Len(t, passengers, 1) {
+		assert.Equal(t, "Claire", passengers[0].FirstName)
+	}
+
+	// Travel absent de la commande, nombre de voyageurs incorrect, autre utilisateur
+	assert.Equal(t, http.StatusBadRequest, update(1, 999, passenger("Alice", valid)).Code)
+	assert.Equal(t, http.StatusBadRequest, update(1, travel.ID, passenger("Alice", valid), passenger("Bob", valid)).Code)
+	assert.Equal(t, http.StatusNotFound, update(2, travel.ID, passenger("Alice", valid)).Code)
+
+	// Départ dans moins de 48 h : plus modifiable
+	resp = update(1, imminent.ID, passenger("Bob", valid))
+	assert.Equal(t, http.StatusForbidden, resp.Code, resp.Body.String())
+
+	// Commande annulée : plus modifiable
+	db.Model(&models.Order{}).Where("id = ?", order.ID).Update("statut", models.StatusCancelled)
+	assert.Equal(t, http.StatusForbidden, update(1, travel.ID, passenger("Alice", valid)).Code)
+
+	var count int64
+	db.Model(&models.Passenger{}).Where("order_id = ?", order.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"h3-travel/payment"
+	"sync"
+)
+
+var errSpyProvider = errors.New("prestataire indisponible")
+
+// spyProvider enregistre les appels faits au prestataire mock ; les hooks
+// permettent de provoquer une panne ou une écriture concurrente.
+type spyProvider struct {
+	*payment.MockProvider
+	mu         sync.Mutex
+	calls      []string
+	onCapture  func()
+	failRefund func(call int) bool // call : numéro du remboursement, à partir de 1
+	refunds    int
+}
+
+func newSpyProvider() *spyProvider {
+	return &spyProvider{MockProvider: payment.NewMockProvider()}
+}
+
+func (p *spyProvider) record(call string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, call)
+}
+
+func (p *spyProvider) Calls() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.calls...)
+}
+
+func (p *spyProvider) Authorize(ctx context.Context, req payment.Request) (string, error) {
+	p.record("authorize")
+	return p.MockProvider.Authorize(ctx, req)
+}
+
+func (p *spyProvider) Capture(ctx context.Context, transactionID string, amount int64) error {
+	p.record("capture")
+	if p.onCapture != nil {
+		p.onCapture()
+	}
+	return p.MockProvider.Capture(ctx, transactionID, amount)
+}
+
+func (p *spyProvider) Void(ctx context.Context, transactionID string) error {
+	p.record("void")
+	return p.MockProvider.Void(ctx, transactionID)
+}
+
+func (p *spyProvider) Refund(ctx context.Context, transactionID string, amount int64) (string, error) {
+	p.record("refund")
+	p.mu.Lock()
+	p.refunds++
+	call := p.refunds
+	p.mu.Unlock()
+	if p.failRefund != nil && p.failRefund(call) {
+		return "", errSpyProvider
+	}
+	return p.MockProvider.Refund(ctx, transactionID, amount)
+}
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+
+	"h3-travel/models"
+	"h3-travel/money"
+	"h3-travel/payment"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaymentProviderRegistry(t *testing.T) {
+	provider, err := payment.New("")
+	assert.NoError(t, err)
+	assert.Equal(t, "mock", provider.Name())
+
+	_, err = payment.New("inconnu")
+	assert.Error(t, err)
+}
+
+func TestConfirmPaymentMockMagicCards(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Rome", Price: money.New(30000, "EUR"), Stock: 3, Active: true}
+	db.Create(&travel)
+	router := orderItemsRouter(1)
+
+	order := createHold(t, router, travel.ID, 1)
+
+	cases := []struct {
+		card string
+		code int
+	}{
+		{payment.CardDeclined, http.StatusPaymentRequired},
+		{payment.CardInsufficientFunds, http.StatusPaymentRequired},
+		{payment.CardTimeout, http.StatusGatewayTimeout},
+	}
+	for _, tc := range cases {
+		resp := payOrder(t, router, order.ID, tc.card)
+		assert.Equal(t, tc.code, resp.Code, tc.card)
+	}
+
+	// La réservation reste en attente et peut être payée avec une autre carte
+	var reloaded models.Order
+	db.First(&reloaded, order.ID)
+	assert.Equal(t, models.StatusPendingPayment, reloaded.Statut)
+	assert.Empty(t, reloaded.PaymentTransactionID)
+
+	resp := payOrder(t, router, order.ID, "4242424242424242")
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	paid := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, models.StatusPaid, paid.Statut)
+	assert.Equal(t, "mock", paid.PaymentProvider)
+	assert.NotEmpty(t, paid.PaymentTransactionID)
+}
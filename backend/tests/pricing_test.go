@@ -0,0 +1,271 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"h3-travel/controllers"
+	"h3-travel/models"
+	"h3-travel/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func pricingRequest(method, path string, input interface{}) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/admin/pricing-rules", controllers.AdminListPricingRules)
+	router.POST("/admin/pricing-rules", controllers.AdminCreatePricingRule)
+	router.POST("/admin/pricing-rules/dry-run", controllers.AdminPricingDryRun)
+	router.GET("/admin/pricing-rules/:id", controllers.AdminGetPricingRule)
+	router.PUT("/admin/pricing-rules/:id", controllers.AdminUpdatePricingRule)
+	router.DELETE("/admin/pricing-rules/:id", controllers.AdminDeletePricingRule)
+
+	return jsonRequest(router, method, path, input)
+}
+
+func createPricingRule(t *testing.T, input models.PricingRuleInput) models.PricingRule {
+	return decodeOK[models.PricingRule](t, pricingRequest("POST", "/admin/pricing-rules", input))
+}
+
+func dryRun(t *testing.T, input models.PricingDryRunInput) models.PriceQuote {
+	return decodeOK[models.PriceQuote](t, pricingRequest("POST", "/admin/pricing-rules/dry-run", input))
+}
+
+func TestQuotePriceRules(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	departure := now.AddDate(0, 0, 45)
+	travel := models.Travel{Price: eur(100000), Stock: 10, Capacity: 100, DepartureDate: &departure}
+	travel.ID = 7
+	other := uint(8)
+	own := travel.ID
+
+	rules := []models.PricingRule{
+		{Name: "J-30", Kind: models.PricingEarlyBird, Days: 30, Percent: -5},
+		{Name: "J-60", Kind: models.PricingEarlyBird, Days: 60, Percent: -15},
+		{Name: "J-40", Kind: models.PricingEarlyBird, Days: 40, Percent: -10},
+		{Name: "Dernière minute", Kind: models.PricingLastMinute, Days: 7, Percent: -20},
+		{Name: "Moins de 20 %", Kind: models.PricingOccupancy, StockPercent: 20, Percent: 10},
+		{Name: "Moins de 20 % (ce travel)", Kind: models.PricingOccupancy, StockPercent: 20, Percent: 25, TravelID: &own},
+		{Name: "Autre travel", Kind: models.PricingOccupancy, StockPercent: 50, Percent: 50, TravelID: &other},
+	}
+	for i := range rules {
+		rules[i].ID = uint(i + 1)
+	}
+
+	// 45 jours avant le départ, 10 places sur 100 : J-40 et la règle propre au travel
+	quote := services.QuotePrice(travel, rules, now)
+	assert.Equal(t, 45, *quote.DaysBeforeDeparture)
+	assert.Equal(t, eur(100000), quote.BasePrice)
+	assert.Equal(t, eur(115000), quote.Price)
+	if assert.Len(t, quote.Rules, 2) {
+		assert.Equal(t, "J-40", quote.Rules[0].Name)
+		assert.Equal(t, eur(-10000), quote.Rules[0].Adjustment)
+		assert.Equal(t, "Moins de 20 % (ce travel)", quote.Rules[1].Name)
+		assert.Equal(t, eur(25000), quote.Rules[1].Adjustment)
+	}
+
+	// À 5 jours du départ avec du stock : seule la dernière minute s'applique
+	travel.Stock = 50
+	quote = services.QuotePrice(travel, rules, departure.AddDate(0, 0, -5))
+	assert.Equal(t, eur(80000), quote.Price)
+	if assert.Len(t, quote.Rules, 1) {
+		assert.Equal(t, models.PricingLastMinute, quote.Rules[0].Kind)
+	}
+
+	// Départ passé ou inconnu, sans capacité : aucune règle
+	travel.DepartureDate = nil
+	travel.Capacity = 0
+	quote = services.QuotePrice(travel, rules, now)
+	assert.Nil(t, quote.DaysBeforeDeparture)
+	assert.Empty(t, quote.Rules)
+	assert.Equal(t, eur(100000), quote.Price)
+}
+
+func TestAdminPricingRuleCRUDAndDryRun(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	departure := time.Now().AddDate(0, 0, 90)
+	travel := models.Travel{Title: "Lisbonne", Price: eur(50000), Stock: 10, Capacity: 10, Active: true, DepartureDate: &departure}
+	db.Create(&travel)
+
+	assert.Equal(t, http.StatusBadRequest, pricingRequest("POST", "/admin/pricing-rules",
+		models.PricingRuleInput{Name: "Sans seuil", Kind: models.PricingOccupancy, Percent: 10}).Code)
+	assert.Equal(t, http.StatusBadRequest, pricingRequest("POST", "/admin/pricing-rules",
+		models.PricingRuleInput{Name: "Trop", Kind: models.PricingLastMinute, Days: 3, Percent: -150}).Code)
+	missing := uint(999)
+	assert.Equal(t, http.StatusNotFound, pricingRequest("POST", "/admin/pricing-rules",
+		models.PricingRuleInput{Name: "Inconnu", Kind: models.PricingEarlyBird, Days: 30, Percent: -5, TravelID: &missing}).Code)
+
+	early := createPricingRule(t, models.PricingRuleInput{Name: "Early bird", Kind: models.PricingEarlyBird, Days: 60, Percent: -10})
+	assert.True(t, early.Active)
+	inactive := false
+	filling := createPricingRule(t, models.PricingRuleInput{Name: "Remplissage", Kind: models.PricingOccupancy,
+		StockPercent: 30, Percent: 20, TravelID: &travel.ID, Active: &inactive})
+	assert.False(t, filling.Active)
+
+	// La règle inactive ne compte pas
+	quote := dryRun(t, models.PricingDryRunInput{TravelID: travel.ID, Stock: intPtr(2)})
+	assert.Equal(t, eur(45000), quote.Price)
+	assert.Len(t, quote.Rules, 1)
+
+	active := true
+	resp := pricingRequest("PUT", fmt.Sprintf("/admin/pricing-rules/%d", filling.ID), models.PricingRuleInput{Name: "Remplissage",
+		Kind: models.PricingOccupancy, StockPercent: 30, Percent: 20, TravelID: &travel.ID, Active: &active})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	// Stock simulé sous 30 % : les deux règles se cumulent
+	quote = dryRun(t, models.PricingDryRunInput{TravelID: travel.ID, Stock: intPtr(2)})
+	assert.Equal(t, eur(55000), quote.Price)
+	assert.Equal(t, 2, quote.Stock)
+	assert.Len(t, quote.Rules, 2)
+
+	// Date simulée à 10 jours du départ, stock actuel : aucune règle
+	at := departure.AddDate(0, 0, -10)
+	quote = dryRun(t, models.PricingDryRunInput{TravelID: travel.ID, At: &at})
+	assert.Equal(t, eur(50000), quote.Price)
+	assert.Empty(t, quote.Rules)
+
+	// Rien n'a été modifié par les simulations
+	assert.Equal(t, 10, stockOf(db, travel.ID))
+
+	assert.Equal(t, http.StatusNotFound, pricingRequest("POST", "/admin/pricing-rules/dry-run",
+		models.PricingDryRunInput{TravelID: 999}).Code)
+
+	rules := decodeJSON[[]models.PricingRule](t, pricingRequest("GET", "/admin/pricing-rules", nil))
+	assert.Len(t, rules, 2)
+
+	assert.Equal(t, http.StatusOK, pricingRequest("DELETE", fmt.Sprintf("/admin/pricing-rules/%d", early.ID), nil).Code)
+	assert.Equal(t, http.StatusNotFound, pricingRequest("GET", fmt.Sprintf("/admin/pricing-rules/%d", early.ID), nil).Code)
+}
+
+func TestDynamicPriceOnTravelAndCheckout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	departure := time.Now().AddDate(0, 0, 90)
+	travel := models.Travel{Title: "Reykjavik", Price: eur(100000), Stock: 4, Capacity: 10, Active: true, DepartureDate: &departure}
+	db.Create(&travel)
+
+	createPricingRule(t, models.PricingRuleInput{Name: "Early bird", Kind: models.PricingEarlyBird, Days: 60, Percent: -10})
+	createPricingRule(t, models.PricingRuleInput{Name: "Dernières places", Kind: models.PricingOccupancy, StockPercent: 30, Percent: 30})
+
+	// 4 places sur 10 : seule la remise early bird s'applique
+	shown := decodeJSON[models.Travel](t, getTravels(fmt.Sprintf("/travels/%d", travel.ID)))
+	assert.Equal(t, eur(90000), shown.Price)
+	if assert.NotNil(t, shown.BasePrice) {
+		assert.Equal(t, eur(100000), *shown.BasePrice)
+	}
+	assert.Len(t, shown.PricingRules, 1)
+
+	// La commande est passée au prix affiché, calculé sur le stock d'avant l'achat
+	resp := orderWithPromo(1, "", models.OrderItemInput{TravelID: travel.ID, Quantity: 2})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	first := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, eur(180000), first.Total)
+	assert.Equal(t, eur(90000), first.Items[0].UnitPrice)
+
+	// 2 places sur 10 : la majoration de remplissage s'ajoute
+	resp = orderWithPromo(2, "", models.OrderItemInput{TravelID: travel.ID, Quantity: 1})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	second := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, eur(120000), second.Items[0].UnitPrice)
+
+	listed := decodeJSON[models.TravelPage](t, getTravels("/travels"))
+	if assert.Len(t, listed.Data, 1) {
+		assert.Equal(t, eur(120000), listed.Data[0].Price)
+	}
+
+	// Le prix de base enregistré n'est pas modifié
+	var stored models.Travel
+	db.First(&stored, travel.ID)
+	assert.Equal(t, eur(100000), stored.Price)
+}
+
+func TestBackfillTravelCapacity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Oslo", Price: eur(40000), Stock: 6, Active: true}
+	db.Create(&travel)
+	resp := orderWithPromo(1, "", models.OrderItemInput{TravelID: travel.ID, Quantity: 3})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = orderWithPromo(2, "", models.OrderItemInput{TravelID: travel.ID, Quantity: 1})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	cancelled := decodeJSON[models.Order](t, resp)
+	db.Model(&models.Order{}).Where("id = ?", cancelled.ID).Update("statut", models.StatusCancelled)
+	db.Model(&models.Travel{}).Where("id = ?", travel.ID).Update("capacity", 0)
+
+	assert.NoError(t, services.BackfillTravelCapacity(db))
+
+	var reloaded models.Travel
+	db.First(&reloaded, travel.ID)
+	// 2 places restantes + 3 vendues (la commande annulée ne compte pas)
+	assert.Equal(t, 5, reloaded.Capacity)
+}
+
+// La capacité suit les places ajoutées ou retirées, ou est fixée explicitement.
+func TestUpdateTravelCapacity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Oslo", Price: eur(40000), Stock: 6, Capacity: 6, Active: true}
+	db.Create(&travel)
+	resp := orderWithPromo(1, "", models.OrderItemInput{TravelID: travel.ID, Quantity: 2})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	path := fmt.Sprintf("/travels/%d", travel.ID)
+
+	capacity := func() int {
+		var reloaded models.Travel
+		db.First(&reloaded, travel.ID)
+		return reloaded.Capacity
+	}
+
+	// 4 places restantes portées à 10 : 2 vendues + 10
+	assert.Equal(t, http.StatusOK, travelRequest("PUT", path, models.Travel{Stock: 10}).Code)
+	assert.Equal(t, 12, capacity())
+	assert.Equal(t, http.StatusOK, travelRequest("PUT", path, models.Travel{Stock: 3}).Code)
+	assert.Equal(t, 5, capacity())
+
+	assert.Equal(t, http.StatusBadRequest, travelRequest("PUT", path, models.Travel{Stock: 8, Capacity: 7}).Code)
+	assert.Equal(t, http.StatusOK, travelRequest("PUT", path, models.Travel{Capacity: 20}).Code)
+	assert.Equal(t, 20, capacity())
+	assert.Equal(t, 3, stockOf(db, travel.ID))
+}
+
+// Une commande passée entre la lecture du travel et l'écriture du stock garde sa place
+func TestUpdateTravelKeepsConcurrentOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Oslo", Price: eur(40000), Stock: 6, Capacity: 6, Active: true}
+	db.Create(&travel)
+
+	ordered := false
+	_ = db.Callback().Update().Before("gorm:update").Register("test:concurrent_order", func(tx *gorm.DB) {
+		if ordered || tx.Statement.Table != "travels" {
+			return
+		}
+		ordered = true
+		tx.Session(&gorm.Session{NewDB: true}).Exec("UPDATE travels SET stock = stock - 2 WHERE id = ?", travel.ID)
+	})
+	t.Cleanup(func() { _ = db.Callback().Update().Remove("test:concurrent_order") })
+
+	resp := travelRequest("PUT", fmt.Sprintf("/travels/%d", travel.ID), models.Travel{Stock: 10})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.True(t, ordered)
+
+	var updated models.Travel
+	db.First(&updated, travel.ID)
+	assert.Equal(t, 8, updated.Stock)
+	assert.Equal(t, 10, updated.Capacity)
+}
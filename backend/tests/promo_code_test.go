@@ -0,0 +1,240 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"h3-travel/config"
+	"h3-travel/controllers"
+	"h3-travel/models"
+	"h3-travel/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func promoRequest(method, path string, input interface{}) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/admin/promo-codes", controllers.AdminListPromoCodes)
+	router.POST("/admin/promo-codes", controllers.AdminCreatePromoCode)
+	router.GET("/admin/promo-codes/:id", controllers.AdminGetPromoCode)
+	router.PUT("/admin/promo-codes/:id", controllers.AdminUpdatePromoCode)
+	router.DELETE("/admin/promo-codes/:id", controllers.AdminDeletePromoCode)
+
+	return jsonRequest(router, method, path, input)
+}
+
+func createPromo(t *testing.T, input models.PromoCodeInput) models.PromoCode {
+	return decodeOK[models.PromoCode](t, promoRequest("POST", "/admin/promo-codes", input))
+}
+
+func orderWithPromo(userID uint, code string, items ...models.OrderItemInput) *httptest.ResponseRecorder {
+	return jsonRequest(orderItemsRouter(userID), "POST", "/orders", models.CreateOrderInput{Items: items, PromoCode: code})
+}
+
+func TestAdminPromoCodeCRUD(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Rome", Price: eur(30000), Stock: 5, Active: true}
+	db.Create(&travel)
+
+	inactive := false
+	promo := createPromo(t, models.PromoCodeInput{Code: " ete10 ", Kind: models.PromoPercent, Percent: 10,
+		TravelIDs: []uint{travel.ID, travel.ID}, Active: &inactive})
+	assert.Equal(t, "ETE10", promo.Code)
+	assert.Equal(t, []uint{travel.ID}, promo.TravelIDs)
+	assert.False(t, promo.Active)
+
+	assert.Equal(t, http.StatusConflict, promoRequest("POST", "/admin/promo-codes",
+		models.PromoCodeInput{Code: "Ete10", Kind: models.PromoPercent, Percent: 5}).Code)
+	assert.Equal(t, http.StatusBadRequest, promoRequest("POST", "/admin/promo-codes",
+		models.PromoCodeInput{Code: "FIXE", Kind: models.PromoFixed}).Code)
+	assert.Equal(t, http.StatusBadRequest, promoRequest("POST", "/admin/promo-codes",
+		models.PromoCodeInput{Code: "PCT", Kind: models.PromoPercent, Percent: 150}).Code)
+	assert.Equal(t, http.StatusBadRequest, promoRequest("POST", "/admin/promo-codes",
+		models.PromoCodeInput{Code: "NOWHERE", Kind: models.PromoPercent, Percent: 5, TravelIDs: []uint{999}}).Code)
+
+	// Le compteur d'utilisations survit à une mise à jour
+	db.Model(&models.PromoCode{}).Where("id = ?", promo.ID).Update("used_count", 4)
+	amount := eur(5000)
+	resp := promoRequest("PUT", fmt.Sprintf("/admin/promo-codes/%d", promo.ID),
+		models.PromoCodeInput{Code: "ETE10", Kind: models.PromoFixed, Amount: &amount, MaxUses: 10})
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = promoRequest("GET", fmt.Sprintf("/admin/promo-codes/%d", promo.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	promo = decodeJSON[models.PromoCode](t, resp)
+	assert.Equal(t, models.PromoFixed, promo.Kind)
+	assert.Equal(t, eur(5000), promo.Amount)
+	assert.Equal(t, 4, promo.UsedCount)
+	assert.True(t, promo.Active)
+	assert.Empty(t, promo.TravelIDs)
+
+	resp = promoRequest("GET", "/admin/promo-codes", nil)
+	promos := decodeJSON[[]models.PromoCode](t, resp)
+	assert.Len(t, promos, 1)
+
+	assert.Equal(t, http.StatusOK, promoRequest("DELETE", fmt.Sprintf("/admin/promo-codes/%d", promo.ID), nil).Code)
+	assert.Equal(t, http.StatusNotFound, promoRequest("GET", fmt.Sprintf("/admin/promo-codes/%d", promo.ID), nil).Code)
+	assert.Equal(t, http.StatusNotFound, promoRequest("DELETE", fmt.Sprintf("/admin/promo-codes/%d", promo.ID), nil).Code)
+}
+
+func TestCheckoutAppliesPromoCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	rome := models.Travel{Title: "Rome", Price: eur(30000), Stock: 10, Active: true}
+	ski := models.Travel{Title: "Ski", Price: eur(50000), Stock: 10, Active: true}
+	db.Create(&rome)
+	db.Create(&ski)
+
+	createPromo(t, models.PromoCodeInput{Code: "ROME20", Kind: models.PromoPercent, Percent: 20, TravelIDs: []uint{rome.ID}})
+	minOrder := eur(100000)
+	amount := eur(10000)
+	createPromo(t, models.PromoCodeInput{Code: "GROS100", Kind: models.PromoFixed, Amount: &amount, MinOrder: &minOrder})
+	past := time.Now().Add(-time.Hour)
+	createPromo(t, models.PromoCodeInput{Code: "FINI", Kind: models.PromoPercent, Percent: 50, ValidUntil: &past})
+
+	// Pourcentage limité aux lignes Rome
+	resp := orderWithPromo(1, "rome20", models.OrderItemInput{TravelID: rome.ID, Quantity: 2}, models.OrderItemInput{TravelID: ski.ID, Quantity: 1})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	order := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, "ROME20", order.PromoCode)
+	assert.Equal(t, eur(12000), order.Discount)
+	assert.Equal(t, eur(98000), order.Total)
+	if assert.Len(t, order.Items, 2) {
+		assert.Equal(t, eur(12000), order.Items[0].Discount)
+		assert.Equal(t, eur(0), order.Items[1].Discount)
+	}
+
+	// Le montant payé est le total remisé
+	resp = payOrder(t, orderItemsRouter(1), order.ID, "4242424242424242")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	order = decodeJSON[models.Order](t, resp)
+	assert.Equal(t, eur(98000), order.PaidAmount)
+
+	assert.Equal(t, http.StatusBadRequest, orderWithPromo(1, "ROME20", models.OrderItemInput{TravelID: ski.ID, Quantity: 1}).Code)
+	assert.Equal(t, http.StatusBadRequest, orderWithPromo(1, "GROS100", models.OrderItemInput{TravelID: rome.ID, Quantity: 1}).Code)
+	assert.Equal(t, http.StatusBadRequest, orderWithPromo(1, "FINI", models.OrderItemInput{TravelID: rome.ID, Quantity: 1}).Code)
+	assert.Equal(t, http.StatusBadRequest, orderWithPromo(1, "INCONNU", models.OrderItemInput{TravelID: rome.ID, Quantity: 1}).Code)
+	assert.Equal(t, 8, stockOf(db, rome.ID))
+
+	// Montant fixe réparti au prorata : 100 € sur 300 € + 1000 €
+	resp = orderWithPromo(1, "GROS100", models.OrderItemInput{TravelID: rome.ID, Quantity: 1}, models.OrderItemInput{TravelID: ski.ID, Quantity: 2})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	order = decodeJSON[models.Order](t, resp)
+	assert.Equal(t, eur(10000), order.Discount)
+	assert.Equal(t, eur(120000), order.Total)
+	if assert.Len(t, order.Items, 2) {
+		assert.Equal(t, eur(2307), order.Items[0].Discount)
+		assert.Equal(t, eur(7693), order.Items[1].Discount)
+	}
+}
+
+// Une commande gratuite est payée sur-le-champ, sans autorisation de 0 auprès du prestataire
+func TestFreeOrderIsPaidAtOnce(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	rome := models.Travel{Title: "Rome", Price: eur(30000), Stock: 10, Active: true}
+	db.Create(&rome)
+	createPromo(t, models.PromoCodeInput{Code: "OFFERT", Kind: models.PromoPercent, Percent: 100})
+
+	resp := orderWithPromo(1, "OFFERT", models.OrderItemInput{TravelID: rome.ID, Quantity: 1})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	order := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, eur(0), order.Total)
+	assert.Equal(t, models.StatusPaid, order.Statut)
+
+	var invoices, payments int64
+	db.Model(&models.Invoice{}).Where("order_id = ?", order.ID).Count(&invoices)
+	db.Model(&models.Payment{}).Where("order_id = ?", order.ID).Count(&payments)
+	assert.Equal(t, int64(1), invoices)
+	assert.Equal(t, int64(0), payments)
+
+	// Réservation restée à payer pour 0 : confirmée sans carte ni prestataire
+	resp = orderWithPromo(1, "", models.OrderItemInput{TravelID: rome.ID, Quantity: 1})
+	order = decodeJSON[models.Order](t, resp)
+	db.Model(&models.Order{}).Where("id = ?", order.ID).Update("total_minor", 0)
+	order, err := services.ConfirmPayment(context.Background(), db, config.Payment, config.VaultKey, 1, order.ID, "tok_inconnu")
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusPaid, order.Statut)
+	assert.Empty(t, order.Payments)
+}
+
+func TestPromoUsageLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Rome", Price: eur(30000), Stock: 50, Active: true}
+	db.Create(&travel)
+	line := models.OrderItemInput{TravelID: travel.ID, Quantity: 1}
+
+	promo := createPromo(t, models.PromoCodeInput{Code: "UNEFOIS", Kind: models.PromoPercent, Percent: 10, MaxUsesPerUser: 1})
+
+	first := orderWithPromo(1, "UNEFOIS", line)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, http.StatusConflict, orderWithPromo(1, "UNEFOIS", line).Code)
+	assert.Equal(t, http.StatusOK, orderWithPromo(2, "UNEFOIS", line).Code)
+
+	// Une réservation expirée rend son utilisation
+	order := decodeJSON[models.Order](t, first)
+	expireNow(db, order.ID)
+	_, err := services.ExpireHolds(context.Background(), db, time.Now(), time.Hour)
+	assert.NoError(t, err)
+
+	promo, _ = services.GetPromoCode(db, promo.ID)
+	assert.Equal(t, 1, promo.UsedCount)
+	assert.Equal(t, http.StatusOK, orderWithPromo(1, "UNEFOIS", line).Code)
+}
+
+// Lance de nombreuses commandes simultanées avec un code limité à N utilisations :
+// exactement N doivent obtenir la remise.
+func TestPromoCodeConcurrentRedemptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	const maxUses = 3
+	const buyers = 20
+
+	travel := models.Travel{Title: "Rome", Price: eur(30000), Stock: buyers, Active: true}
+	db.Create(&travel)
+	promo := createPromo(t, models.PromoCodeInput{Code: "FLASH", Kind: models.PromoPercent, Percent: 30, MaxUses: maxUses})
+
+	var wg sync.WaitGroup
+	codes := make(chan int, buyers)
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func(userID uint) {
+			defer wg.Done()
+			codes <- orderWithPromo(userID, "FLASH", models.OrderItemInput{TravelID: travel.ID, Quantity: 1}).Code
+		}(uint(i + 1))
+	}
+	wg.Wait()
+	close(codes)
+
+	success, exhausted := 0, 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			success++
+		case http.StatusConflict:
+			exhausted++
+		}
+	}
+	assert.Equal(t, maxUses, success)
+	assert.Equal(t, buyers-maxUses, exhausted)
+
+	var redemptions int64
+	db.Model(&models.PromoRedemption{}).Where("promo_code_id = ?", promo.ID).Count(&redemptions)
+	assert.Equal(t, int64(maxUses), redemptions)
+	promo, _ = services.GetPromoCode(db, promo.ID)
+	assert.Equal(t, maxUses, promo.UsedCount)
+	assert.Equal(t, buyers-maxUses, stockOf(db, travel.ID))
+}
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"h3-travel/config"
+	"h3-travel/controllers"
+	"h3-travel/models"
+	"h3-travel/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func adminRefund(orderID uint, input models.RefundInput) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.POST("/admin/orders/:id/refund", func(c *gin.Context) {
+		c.Set("user_id", uint(99))
+		controllers.AdminRefundOrder(c)
+	})
+
+	return jsonRequest(router, "POST", fmt.Sprintf("/admin/orders/%d/refund", orderID), input)
+}
+
+func paidOrder(t *testing.T, router *gin.Engine, travelID uint, quantity int) models.Order {
+	order := createHold(t, router, travelID, quantity)
+	return decodeOK[models.Order](t, payOrder(t, router, order.ID, "4242424242424242"))
+}
+
+func TestAdminPartialAndFullRefund(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Rome", Price: money.New(30000, "EUR"), Stock: 3, Active: true}
+	db.Create(&travel)
+	order := paidOrder(t, orderItemsRouter(1), travel.ID, 2)
+	assert.Equal(t, eur(60000), order.PaidAmount)
+	assert.Equal(t, eur(60000), order.RefundableAmount)
+	assert.Len(t, order.Payments, 1)
+
+	resp := adminRefund(order.ID, models.RefundInput{Amount: 10000, Reason: "Geste commercial"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	refunded := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, models.StatusPaid, refunded.Statut)
+	assert.Equal(t, eur(50000), refunded.RefundableAmount)
+	if assert.Len(t, refunded.Refunds, 1) {
+		assert.Equal(t, "admin:99", refunded.Refunds[0].Actor)
+		assert.NotEmpty(t, refunded.Refunds[0].ProviderRefundID)
+	}
+
+	// Au-delà du solde remboursable
+	resp = adminRefund(order.ID, models.RefundInput{Amount: 50001})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// Sans montant : tout le solde, la commande passe à "refunded"
+	resp = adminRefund(order.ID, models.RefundInput{})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	refunded = decodeJSON[models.Order](t, resp)
+	assert.Equal(t, models.StatusRefunded, refunded.Statut)
+	assert.Equal(t, eur(0), refunded.RefundableAmount)
+	assert.Len(t, refunded.Refunds, 2)
+
+	resp = adminRefund(order.ID, models.RefundInput{Amount: 100})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestCancelPaidOrderCreatesRefund(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Ski", Price: money.New(45000, "EUR"), Stock: 2, Active: true}
+	db.Create(&travel)
+	router := orderItemsRouter(1)
+	order := paidOrder(t, router, travel.ID, 1)
+
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/orders/%d/cancel", order.ID), nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	cancelled := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, models.StatusCancelled, cancelled.Statut)
+	assert.Equal(t, eur(0), cancelled.RefundableAmount)
+	if assert.Len(t, cancelled.Refunds, 1) {
+		assert.Equal(t, eur(45000), cancelled.Refunds[0].Amount)
+		assert.Equal(t, "user:1", cancelled.Refunds[0].Actor)
+	}
+
+	var total int64
+	db.Model(&models.Refund{}).Where("order_id = ?", order.ID).Select("SUM(amount_minor)").Scan(&total)
+	assert.Equal(t, int64(45000), total)
+}
+
+// Un remboursement refusé par le prestataire n'annule pas ce qui a déjà été
+// remboursé : il reste dû (failed) et se relance.
+func TestRefundFailureIsRecordedAndRetried(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+	spy := newSpyProvider()
+	config.Payment = spy
+
+	travel := models.Travel{Title: "Rome", Price: money.New(30000, "EUR"), Stock: 3, Active: true}
+	db.Create(&travel)
+	order := paidOrder(t, orderItemsRouter(1), travel.ID, 2)
+
+	spy.failRefund = func(call int) bool { return call == 1 }
+	resp := adminRefund(order.ID, models.RefundInput{Amount: 10000})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	refunded := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, eur(50000), refunded.RefundableAmount)
+	if assert.Len(t, refunded.Refunds, 1) {
+		assert.Equal(t, models.RefundFailed, refunded.Refunds[0].Status)
+		assert.Empty(t, refunded.Refunds[0].ProviderRefundID)
+	}
+	assert.Len(t, refunded.Invoices, 2)
+
+	router := gin.New()
+	router.POST("/admin/orders/:id/refunds/retry", controllers.AdminRetryRefunds)
+	req := httptest.NewRequest("POST", fmt.Sprintf("/admin/orders/%d/refunds/retry", order.ID), nil)
+	retry := httptest.NewRecorder()
+	router.ServeHTTP(retry, req)
+	assert.Equal(t, http.StatusOK, retry.Code)
+	refunded = decodeJSON[models.Order](t, retry)
+	if assert.Len(t, refunded.Refunds, 1) {
+		assert.Equal(t, models.RefundSucceeded, refunded.Refunds[0].Status)
+		assert.NotEmpty(t, refunded.Refunds[0].ProviderRefundID)
+	}
+	assert.Equal(t, []string{"authorize", "capture", "refund", "refund"}, spy.Calls())
+}
+
+// Le prestataire n'est appelé qu'après la validation : une annulation qui
+// échoue en base ne rembourse rien sur la carte.
+func TestCancellationCallsProviderAfterCommit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+	spy := newSpyProvider()
+	config.Payment = spy
+
+	travel := models.Travel{Title: "Rome", Price: money.New(30000, "EUR"), Stock: 3, Active: true}
+	db.Create(&travel)
+	order := paidOrder(t, orderItemsRouter(1), travel.ID, 1)
+
+	// Le registre des remboursements est verrouillé en écriture : la transaction échoue
+	db.Exec("CREATE TRIGGER refunds_locked BEFORE INSERT ON refunds BEGIN SELECT RAISE(ABORT, 'verrouillé'); END")
+	assert.Equal(t, http.StatusInternalServerError, cancelOrderRequest(1, order.ID, nil).Code)
+	assert.Equal(t, []string{"authorize", "capture"}, spy.Calls())
+
+	var stored models.Order
+	db.First(&stored, order.ID)
+	assert.Equal(t, models.StatusPaid, stored.Statut)
+	assert.Equal(t, eur(0), stored.RefundedAmount)
+}
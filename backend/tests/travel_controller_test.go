@@ -2,9 +2,12 @@ package tests
 
 import (
 	"bytes"
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"h3-travel/controllers"
 	"h3-travel/models"
+	"h3-travel/money"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -14,6 +17,15 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// expectNoTravelDetails : préchargement des attributs de type, vides ici
+func expectNoTravelDetails(mock sqlmock.Sqlmock, ids ...driver.Value) {
+	for _, table := range []string{"package_components", "flight_details", "hotel_details"} {
+		mock.ExpectQuery(`SELECT \* FROM "` + table + `"`).
+			WithArgs(ids...).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+}
+
 // --- CREATE TRAVEL ---
 func TestCreateTravelWithMockDB(t *testing.T) {
 	mock, cleanup := SetupMockDB(t)
@@ -25,13 +37,24 @@ func TestCreateTravelWithMockDB(t *testing.T) {
 			sqlmock.AnyArg(), // created_at
 			sqlmock.AnyArg(), // updated_at
 			sqlmock.AnyArg(), // deleted_at
+			"hotel",          // type
 			"Découverte de Paris",
 			"Visitez les monuments emblématiques de Paris en 3 jours.",
-			299.99,
+			int64(29999), // price_minor
+			"EUR",        // price_currency
 			10,
+			10,         // capacity : le stock initial par défaut
+			"standard", // tax_category
 			true,
+			nil, // departure_date
 		).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(`INSERT INTO "hotel_details"`).
+		WithArgs(1, "12 rue de Rivoli", "Paris", "FR", 3, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "hotel_room_types"`).
+		WithArgs(1, "Double", 2, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectCommit()
 
 	gin.SetMode(gin.TestMode)
@@ -39,11 +62,20 @@ func TestCreateTravelWithMockDB(t *testing.T) {
 	router.POST("/travels", controllers.CreateTravel)
 
 	payload := models.Travel{
+		Type:        models.TravelHotel,
 		Title:       "Découverte de Paris",
 		Description: "Visitez les monuments emblématiques de Paris en 3 jours.",
-		Price:       299.99,
+		Price:       money.New(29999, "EUR"),
 		Stock:       10,
 		Active:      true,
+		Hotel: &models.HotelDetails{
+			Address:   "12 rue de Rivoli",
+			City:      "Paris",
+			Country:   "fr",
+			Stars:     3,
+			Nights:    3,
+			RoomTypes: []models.HotelRoomType{{Name: "Double", Capacity: 2}},
+		},
 	}
 	body, _ := json.Marshal(payload)
 
@@ -61,11 +93,19 @@ func TestGetTravelsWithMockDB(t *testing.T) {
 	mock, cleanup := SetupMockDB(t)
 	defer cleanup()
 
-	rows := sqlmock.NewRows([]string{"id", "title", "description", "price", "stock", "active"}).
-		AddRow(1, "Découverte de Paris", "Visitez les monuments", 299.99, 10, true).
-		AddRow(2, "Safari en Afrique", "Safari inoubliable", 1499.50, 5, true)
+	rows := sqlmock.NewRows([]string{"id", "title", "description", "price_minor", "price_currency", "stock", "active"}).
+		AddRow(1, "Découverte de Paris", "Visitez les monuments", 29999, "EUR", 10, true).
+		AddRow(2, "Safari en Afrique", "Safari inoubliable", 149950, "EUR", 5, true)
 
-	mock.ExpectQuery(`SELECT \* FROM "travels"`).WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "travels" WHERE active = \$1 AND "travels"\."deleted_at" IS NULL`).
+		WithArgs(true).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(`SELECT \* FROM "travels" WHERE active = \$1 AND "travels"\."deleted_at" IS NULL ORDER BY created_at,id LIMIT \$2`).
+		WithArgs(true, 20).
+		WillReturnRows(rows)
+	expectNoTravelDetails(mock, 1, 2)
+	mock.ExpectQuery(`SELECT \* FROM "pricing_rules"`).WithArgs(true, 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
 
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
@@ -78,9 +118,59 @@ func TestGetTravelsWithMockDB(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, resp.Code)
 
-	var travels []models.Travel
-	_ = json.Unmarshal(resp.Body.Bytes(), &travels)
-	assert.Len(t, travels, 2)
+	page := decodeJSON[models.TravelPage](t, resp)
+	assert.Len(t, page.Data, 2)
+	assert.Equal(t, models.Pagination{Page: 1, PageSize: 20, Total: 2, TotalPages: 1}, page.Pagination)
+	assert.Equal(t, "2", resp.Header().Get("X-Total-Count"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// --- SEARCH TRAVELS ---
+func TestSearchTravelsWithMockDB(t *testing.T) {
+	mock, cleanup := SetupMockDB(t)
+	defer cleanup()
+
+	tsquery := `\(websearch_to_tsquery\('h3_french', \$\d+\) \|\| websearch_to_tsquery\('h3_english', \$\d+\)\)`
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "travels" WHERE search_vector @@ `+tsquery+` AND active = \$3`).
+		WithArgs("plage", "plage", true).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	headline := func(column string) string {
+		return `replace\(replace\(replace\(replace\(replace\(replace\(CASE WHEN to_tsvector\('h3_french', translate\(` + column + `, .*\)\) @@ websearch_to_tsquery\('h3_french', \$\d+\) ` +
+			`THEN ts_headline\('h3_french', .*\) ELSE ts_headline\('h3_english', .*\) END, '&', '&amp;'\).*, '</mark>'\)`
+	}
+	mock.ExpectQuery(`SELECT travels\.\*, ts_rank\(search_vector, `+tsquery+`\) AS search_rank, `+
+		headline("title")+` AS highlight, `+headline("description")+` AS snippet `+
+		`FROM "travels" WHERE search_vector @@ `+tsquery+` AND active = \$11 AND "travels"\."deleted_at" IS NULL `+
+		`ORDER BY search_rank DESC,id LIMIT \$12`).
+		WithArgs("plage", "plage", "plage", "plage", "plage", "plage", "plage", "plage", "plage", "plage", true, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "description", "price_minor", "price_currency", "stock", "active", "search_rank", "highlight", "snippet"}).
+			AddRow(1, "Plages de Crète", "Farniente sur les plages de sable fin", 89000, "EUR", 4, true, 0.6,
+				"<mark>Plages</mark> de Crète", "Farniente sur les <mark>plages</mark> de sable fin"))
+	expectNoTravelDetails(mock, 1)
+	mock.ExpectQuery(`SELECT \* FROM "pricing_rules"`).WithArgs(true, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.GET("/travels", controllers.GetTravels)
+
+	req := httptest.NewRequest("GET", "/travels?q=plage", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	page := decodeJSON[models.TravelPage](t, resp)
+	if assert.Len(t, page.Data, 1) {
+		assert.Equal(t, "<mark>Plages</mark> de Crète", page.Data[0].Highlight)
+		assert.Equal(t, "Farniente sur les <mark>plages</mark> de sable fin", page.Data[0].Snippet)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Sans recherche, pas de tri par pertinence
+	req = httptest.NewRequest("GET", "/travels?sort=relevance", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
 }
 
 // --- GET ONE TRAVEL ---
@@ -88,12 +178,19 @@ func TestGetTravelWithMockDB(t *testing.T) {
 	mock, cleanup := SetupMockDB(t)
 	defer cleanup()
 
-	row := sqlmock.NewRows([]string{"id", "title", "description", "price", "stock", "active"}).
-		AddRow(1, "Découverte de Paris", "Visitez les monuments", 299.99, 10, true)
+	row := sqlmock.NewRows([]string{"id", "title", "description", "price_minor", "price_currency", "stock", "active"}).
+		AddRow(1, "Découverte de Paris", "Visitez les monuments", 29999, "EUR", 10, true)
 
 	mock.ExpectQuery(`SELECT \* FROM "travels" WHERE "travels"\."id" = \$1 AND "travels"\."deleted_at" IS NULL ORDER BY "travels"\."id" LIMIT \$2`).
 		WithArgs(int64(1), sqlmock.AnyArg()).
 		WillReturnRows(row)
+	mock.ExpectQuery(`SELECT \* FROM "cancellation_tiers" WHERE "cancellation_tiers"\."travel_id" = \$1`).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "travel_id", "min_days_before", "refund_percent"}).
+			AddRow(1, 1, 30, 100))
+	expectNoTravelDetails(mock, 1)
+	mock.ExpectQuery(`SELECT \* FROM "pricing_rules"`).WithArgs(true, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
 
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
@@ -106,9 +203,9 @@ func TestGetTravelWithMockDB(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, resp.Code)
 
-	var travel models.Travel
-	_ = json.Unmarshal(resp.Body.Bytes(), &travel)
+	travel := decodeJSON[models.Travel](t, resp)
 	assert.Equal(t, "Découverte de Paris", travel.Title)
+	assert.Len(t, travel.CancellationTiers, 1)
 }
 
 // --- UPDATE TRAVEL ---
@@ -116,15 +213,16 @@ func TestUpdateTravelWithMockDB(t *testing.T) {
 	mock, cleanup := SetupMockDB(t)
 	defer cleanup()
 
-	row := sqlmock.NewRows([]string{"id", "title", "description", "price", "stock", "active"}).
-		AddRow(1, "Découverte de Paris", "Visitez les monuments", 299.99, 10, true)
+	row := sqlmock.NewRows([]string{"id", "title", "description", "price_minor", "price_currency", "stock", "active"}).
+		AddRow(1, "Découverte de Paris", "Visitez les monuments", 29999, "EUR", 10, true)
+	mock.ExpectBegin()
 	mock.ExpectQuery(`SELECT \* FROM "travels" WHERE "travels"\."id" = \$1 AND "travels"\."deleted_at" IS NULL ORDER BY "travels"\."id" LIMIT \$2`).
 		WithArgs(int64(1), sqlmock.AnyArg()).
 		WillReturnRows(row)
-
 	mock.ExpectExec(`UPDATE "travels"`).
-		WithArgs(sqlmock.AnyArg(), "Paris by Night", sqlmock.AnyArg(), 10, true, 1).
+		WithArgs(sqlmock.AnyArg(), "Paris by Night", 1).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
@@ -141,7 +239,31 @@ func TestUpdateTravelWithMockDB(t *testing.T) {
 
 	router.ServeHTTP(resp, req)
 
-	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateTravelWriteFailure(t *testing.T) {
+	mock, cleanup := SetupMockDB(t)
+	defer cleanup()
+
+	row := sqlmock.NewRows([]string{"id", "title", "description", "price_minor", "price_currency", "stock", "active"}).
+		AddRow(1, "Découverte de Paris", "Visitez les monuments", 29999, "EUR", 10, true)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "travels"`).
+		WithArgs(int64(1), sqlmock.AnyArg()).
+		WillReturnRows(row)
+	mock.ExpectExec(`UPDATE "travels"`).
+		WillReturnError(errors.New("connexion perdue"))
+	mock.ExpectRollback()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.PUT("/travels/:id", controllers.UpdateTravel)
+
+	resp := jsonRequest(router, "PUT", "/travels/1", models.Travel{Title: "Paris by Night"})
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 // --- DELETE TRAVEL ---
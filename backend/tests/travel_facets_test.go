@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"h3-travel/config"
+	"h3-travel/controllers"
+	"h3-travel/models"
+	"h3-travel/money"
+	"h3-travel/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func travelFacets(t *testing.T, role, query string) models.TravelFacets {
+	router := gin.New()
+	router.GET("/travels/facets", func(c *gin.Context) {
+		if role != "" {
+			c.Set("role", role)
+		}
+		controllers.GetTravelFacets(c)
+	})
+
+	return decodeOK[models.TravelFacets](t, jsonRequest(router, "GET", "/travels/facets"+query, nil))
+}
+
+func hotelIn(title, country string, price money.Money, stock int) models.Travel {
+	hotel := hotelTravel()
+	hotel.Title, hotel.Price, hotel.Stock = title, price, stock
+	hotel.Hotel.Country = country
+	return hotel
+}
+
+// facetCatalog : un vol, des hôtels en Italie, au Portugal (complet), au Japon
+// (prix en yens) et en France (inactif), et un séjour packagé à Rome.
+func facetCatalog(t *testing.T, db *gorm.DB) {
+	services.ClearTravelFacets()
+	flight := createTravel(t, flightTravel(time.Now().Add(30*24*time.Hour)))
+	rome := createTravel(t, hotelIn("Hôtel Colosseo", "it", eur(45000), 20))
+	createTravel(t, hotelIn("Pousada Alfama", "pt", eur(90000), 0))
+	createTravel(t, hotelIn("Ryokan Gion", "jp", money.New(150000, "JPY"), 5))
+	paris := createTravel(t, hotelIn("Hôtel du Marais", "fr", eur(30000), 8))
+	createTravel(t, models.Travel{
+		Type:       models.TravelPackage,
+		Title:      "Week-end à Rome",
+		Price:      eur(55000),
+		Stock:      10,
+		Components: []models.PackageComponent{{TravelID: rome.ID}, {TravelID: flight.ID}},
+	})
+	db.Model(&models.Travel{}).Where("id = ?", paris.ID).Update("active", false)
+}
+
+func counts(facets []models.FacetCount) map[string]int64 {
+	m := map[string]int64{}
+	for _, f := range facets {
+		m[f.Value] = f.Count
+	}
+	return m
+}
+
+func priceCounts(facets []models.PriceFacet) []int64 {
+	var c []int64
+	for _, f := range facets {
+		c = append(c, f.Count)
+	}
+	return c
+}
+
+func TestTravelFacets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+	facetCatalog(t, db)
+
+	facets := travelFacets(t, "", "")
+	assert.Equal(t, int64(5), facets.Total)
+	assert.Equal(t, map[string]int64{"flight": 1, "hotel": 3, "package": 1}, counts(facets.Types))
+	// Tranches en euros : le ryokan, en yens, n'y figure pas
+	assert.Equal(t, []int64{2, 2, 0, 0, 0}, priceCounts(facets.Prices))
+	if assert.Len(t, facets.Prices, 5) {
+		assert.Equal(t, eur(50000), facets.Prices[1].Min)
+		assert.Equal(t, eur(100000), *facets.Prices[1].Max)
+		assert.Nil(t, facets.Prices[4].Max)
+	}
+	assert.Equal(t, []models.FacetCount{{Value: "IT", Count: 2}, {Value: "JP", Count: 1}, {Value: "PT", Count: 1}}, facets.Countries)
+	assert.Equal(t, map[string]int64{"available": 4, "sold_out": 1}, counts(facets.Availability))
+
+	// Chaque facette ignore son propre critère
+	facets = travelFacets(t, "", "?type=hotel&in_stock=true")
+	assert.Equal(t, int64(2), facets.Total)
+	assert.Equal(t, map[string]int64{"flight": 1, "hotel": 2, "package": 1}, counts(facets.Types))
+	assert.Equal(t, []int64{1, 0, 0, 0, 0}, priceCounts(facets.Prices))
+	assert.Equal(t, map[string]int64{"IT": 1, "JP": 1}, counts(facets.Countries))
+	assert.Equal(t, map[string]int64{"available": 2, "sold_out": 1}, counts(facets.Availability))
+
+	facets = travelFacets(t, "", "?country=it&max_price=50000")
+	assert.Equal(t, int64(1), facets.Total)
+	assert.Equal(t, map[string]int64{"flight": 0, "hotel": 1, "package": 0}, counts(facets.Types))
+	assert.Equal(t, []int64{1, 1, 0, 0, 0}, priceCounts(facets.Prices))
+	assert.Equal(t, []models.FacetCount{{Value: "IT", Count: 1}}, facets.Countries)
+
+	// La liste applique les mêmes critères
+	assert.Equal(t, []string{"Hôtel Colosseo", "Week-end à Rome"}, listedTitles(t, "", "?country=IT"))
+	assert.Equal(t, []string{"Ryokan Gion"}, listedTitles(t, "", "?type=hotel&price_currency=JPY&min_price=0"))
+
+	// Tranches dans la devise demandée (le yen n'a pas d'unité mineure),
+	// travels inactifs pour les admins seulement
+	facets = travelFacets(t, "", "?price_currency=jpy")
+	assert.Equal(t, money.New(500, "JPY"), *facets.Prices[0].Max)
+	assert.Equal(t, []int64{0, 0, 0, 0, 1}, priceCounts(facets.Prices))
+	assert.Equal(t, int64(1), travelFacets(t, "admin", "?active=false").Total)
+	assert.Equal(t, int64(6), travelFacets(t, "admin", "").Total)
+}
+
+func TestTravelFacetsCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+	facetCatalog(t, db)
+
+	now := time.Now()
+	facets, err := services.GetTravelFacets(db, models.TravelFilter{}, time.Minute, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), facets.Total)
+
+	db.Model(&models.Travel{}).Where("title = ?", "Pousada Alfama").Update("active", false)
+	facets, _ = services.GetTravelFacets(db, models.TravelFilter{}, time.Minute, now.Add(30*time.Second))
+	assert.Equal(t, int64(5), facets.Total)
+	facets, _ = services.GetTravelFacets(db, models.TravelFilter{}, time.Minute, now.Add(2*time.Minute))
+	assert.Equal(t, int64(4), facets.Total)
+
+	// Une modification du catalogue par un admin les recalcule aussitôt
+	assert.Equal(t, int64(4), travelFacets(t, "", "").Total)
+	createTravel(t, hotelIn("Hôtel Trastevere", "it", eur(38000), 6))
+	assert.Equal(t, int64(5), travelFacets(t, "", "").Total)
+
+	// Comme une commande qui épuise le stock ou une annulation qui le rend
+	var ryokan models.Travel
+	db.Where("title = ?", "Ryokan Gion").First(&ryokan)
+	order, err := services.CreateOrder(db, config.VaultKey, 1, models.CreateOrderInput{
+		Items: []models.OrderItemInput{{TravelID: ryokan.ID, Quantity: 5}},
+	}, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"available": 4, "sold_out": 1}, counts(travelFacets(t, "", "").Availability))
+	_, _, err = services.CancelOrder(context.Background(), db, config.Payment, 1, order.ID, false, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"available": 5, "sold_out": 0}, counts(travelFacets(t, "", "").Availability))
+}
+
+// Le cache plein, l'entrée la plus ancienne laisse sa place
+func TestTravelFacetsCacheSize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+	facetCatalog(t, db)
+
+	now := time.Now()
+	facets, _ := services.GetTravelFacets(db, models.TravelFilter{}, time.Minute, now)
+	assert.Equal(t, int64(5), facets.Total)
+
+	db.Model(&models.Travel{}).Where("title = ?", "Pousada Alfama").Update("active", false)
+	for i := int64(0); i < 256; i++ {
+		min := i
+		services.GetTravelFacets(db, models.TravelFilter{MinPrice: &min}, time.Minute, now.Add(time.Second))
+	}
+	facets, _ = services.GetTravelFacets(db, models.TravelFilter{}, time.Minute, now.Add(2*time.Second))
+	assert.Equal(t, int64(4), facets.Total)
+}
+
+// Un travel daté est disponible s'il lui reste un départ à venir non complet,
+// quel que soit le stock du travel lui-même.
+func TestAvailabilityUsesDepartures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+	services.ClearTravelFacets()
+
+	rome := models.Travel{Title: "Rome", Price: eur(30000), Stock: 5, Active: true}
+	lisbon := models.Travel{Title: "Lisbonne", Price: eur(40000), Stock: 10, Active: true}
+	porto := models.Travel{Title: "Porto", Price: eur(35000), Stock: 0, Active: true}
+	for _, travel := range []*models.Travel{&rome, &lisbon, &porto} {
+		db.Create(travel)
+	}
+	now := time.Now()
+	db.Create(&models.Departure{TravelID: lisbon.ID, StartDate: now.Add(-24 * time.Hour), EndDate: now, Capacity: 4, Stock: 4})
+	db.Create(&models.Departure{TravelID: lisbon.ID, StartDate: now.Add(240 * time.Hour), EndDate: now.Add(288 * time.Hour), Capacity: 4, Stock: 0})
+	db.Create(&models.Departure{TravelID: porto.ID, StartDate: now.Add(240 * time.Hour), EndDate: now.Add(288 * time.Hour), Capacity: 4, Stock: 4})
+
+	assert.Equal(t, []string{"Rome", "Porto"}, listedTitles(t, "", "?in_stock=true"))
+	facets := travelFacets(t, "", "")
+	assert.Equal(t, map[string]int64{"available": 2, "sold_out": 1}, counts(facets.Availability))
+}
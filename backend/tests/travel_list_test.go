@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"h3-travel/controllers"
+	"h3-travel/models"
+	"h3-travel/money"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func listTravels(role, query string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/travels", func(c *gin.Context) {
+		if role != "" {
+			c.Set("role", role)
+		}
+		controllers.GetTravels(c)
+	})
+
+	return jsonRequest(router, "GET", "/travels"+query, nil)
+}
+
+func listedTitles(t *testing.T, role, query string) []string {
+	page := decodeOK[models.TravelPage](t, listTravels(role, query))
+	titles := []string{}
+	for _, travel := range page.Data {
+		titles = append(titles, travel.Title)
+	}
+	return titles
+}
+
+func TestListTravelsFiltersAndSorts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	lastYear := time.Now().AddDate(-1, 0, 0)
+	for _, travel := range []models.Travel{
+		{Title: "Rome", Price: eur(30000), Stock: 5, Active: true},
+		{Title: "Lisbonne", Price: eur(45000), Stock: 0, Active: true},
+		{Title: "Tokyo", Price: money.New(150000, "JPY"), Stock: 3, Active: true},
+		{Title: "Oslo", Price: eur(60000), Stock: 2, Active: true},
+		{Title: "Brouillon", Price: eur(10000), Stock: 8, Active: true},
+	} {
+		db.Create(&travel)
+	}
+	db.Model(&models.Travel{}).Where("title = ?", "Brouillon").Update("active", false)
+	db.Model(&models.Travel{}).Where("title = ?", "Rome").Update("created_at", lastYear)
+
+	// Par défaut : travels actifs, par date de création
+	assert.Equal(t, []string{"Rome", "Lisbonne", "Tokyo", "Oslo"}, listedTitles(t, "", ""))
+	assert.Equal(t, []string{"Oslo", "Lisbonne", "Rome"}, listedTitles(t, "", "?min_price=30000&sort=-price"))
+	assert.Equal(t, []string{"Rome", "Lisbonne"}, listedTitles(t, "", "?max_price=50000"))
+	assert.Equal(t, []string{"Tokyo"}, listedTitles(t, "", "?min_price=1000&price_currency=jpy"))
+	assert.Equal(t, []string{"Rome", "Tokyo", "Oslo"}, listedTitles(t, "", "?in_stock=true"))
+	assert.Equal(t, []string{"Lisbonne", "Oslo", "Rome", "Tokyo"}, listedTitles(t, "", "?sort=title"))
+	assert.Equal(t, []string{"Rome"}, listedTitles(t, "", "?created_to="+lastYear.Format("2006-01-02")))
+	assert.Equal(t, []string{"Lisbonne", "Tokyo", "Oslo"}, listedTitles(t, "", "?created_from="+time.Now().Format("2006-01-02")))
+
+	assert.Equal(t, http.StatusBadRequest, listTravels("", "?sort=description").Code)
+	assert.Equal(t, http.StatusBadRequest, listTravels("", "?page_size=500").Code)
+	assert.Equal(t, http.StatusBadRequest, listTravels("", "?min_price=1&price_currency=euro").Code)
+
+	// Les travels inactifs ne sont visibles que des admins
+	assert.Equal(t, http.StatusForbidden, listTravels("", "?active=false").Code)
+	assert.Equal(t, http.StatusForbidden, listTravels("user", "?active=all").Code)
+	assert.Equal(t, []string{"Brouillon"}, listedTitles(t, "admin", "?active=false"))
+	assert.Len(t, listedTitles(t, "admin", ""), 5)
+	assert.Len(t, listedTitles(t, "admin", "?active=true"), 4)
+}
+
+func TestListTravelsPagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	for _, title := range []string{"A", "B", "C", "D", "E"} {
+		db.Create(&models.Travel{Title: title, Price: eur(10000), Stock: 1, Active: true})
+	}
+
+	resp := listTravels("", "?sort=title&page=2&page_size=2")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	page := decodeJSON[models.TravelPage](t, resp)
+	if assert.Len(t, page.Data, 2) {
+		assert.Equal(t, "C", page.Data[0].Title)
+	}
+	assert.Equal(t, models.Pagination{Page: 2, PageSize: 2, Total: 5, TotalPages: 3}, page.Pagination)
+	assert.Equal(t, "5", resp.Header().Get("X-Total-Count"))
+	assert.Equal(t, `</travels?page=1&page_size=2&sort=title>; rel="first", `+
+		`</travels?page=1&page_size=2&sort=title>; rel="prev", `+
+		`</travels?page=3&page_size=2&sort=title>; rel="next", `+
+		`</travels?page=3&page_size=2&sort=title>; rel="last"`, resp.Header().Get("Link"))
+
+	// Au-delà de la dernière page : aucune donnée, mais le total reste connu
+	resp = listTravels("", "?page=9&page_size=2")
+	page = decodeJSON[models.TravelPage](t, resp)
+	assert.Empty(t, page.Data)
+	assert.Equal(t, int64(5), page.Pagination.Total)
+	assert.NotContains(t, resp.Header().Get("Link"), `rel="next"`)
+	assert.Contains(t, resp.Header().Get("Link"), `</travels?page=3&page_size=2>; rel="prev"`)
+}
@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"h3-travel/controllers"
+	"h3-travel/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func travelRequest(method, path string, input interface{}) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.POST("/travels", controllers.CreateTravel)
+	router.GET("/travels", controllers.GetTravels)
+	router.GET("/travels/:id", controllers.GetTravel)
+	router.PUT("/travels/:id", controllers.UpdateTravel)
+
+	return jsonRequest(router, method, path, input)
+}
+
+func createTravel(t *testing.T, input models.Travel) models.Travel {
+	return decodeOK[models.Travel](t, travelRequest("POST", "/travels", input))
+}
+
+func flightTravel(departure time.Time) models.Travel {
+	return models.Travel{
+		Type:  models.TravelFlight,
+		Title: "Paris - Rome",
+		Price: eur(12000),
+		Stock: 150,
+		Flight: &models.FlightDetails{
+			Origin:       "cdg",
+			Destination:  "FCO",
+			Carrier:      "Air France",
+			FlightNumber: "af1404",
+			DepartureAt:  departure,
+			ArrivalAt:    departure.Add(2 * time.Hour),
+		},
+	}
+}
+
+func hotelTravel() models.Travel {
+	return models.Travel{
+		Type:  models.TravelHotel,
+		Title: "Hôtel Colosseo",
+		Price: eur(45000),
+		Stock: 20,
+		Hotel: &models.HotelDetails{
+			Address: "Via Labicana 1",
+			City:    "Rome",
+			Country: "it",
+			Stars:   4,
+			Nights:  3,
+			RoomTypes: []models.HotelRoomType{
+				{Name: "Double", Capacity: 2},
+				{Name: "Suite", Capacity: 4, Description: "Vue sur le Colisée"},
+			},
+		},
+	}
+}
+
+func TestCreateTypedTravels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	SetupSQLiteDB(t)
+
+	departure := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	flight := createTravel(t, flightTravel(departure))
+	assert.Equal(t, models.TravelFlight, flight.Type)
+	if assert.NotNil(t, flight.Flight) {
+		assert.Equal(t, "CDG", flight.Flight.Origin)
+		assert.Equal(t, "AF1404", flight.Flight.FlightNumber)
+	}
+	// La date de départ, qui sert à la politique d'annulation, vient du vol
+	if assert.NotNil(t, flight.DepartureDate) {
+		assert.True(t, departure.Equal(*flight.DepartureDate))
+	}
+
+	hotel := createTravel(t, hotelTravel())
+	assert.Nil(t, hotel.DepartureDate)
+
+	pkg := createTravel(t, models.Travel{
+		Type:       models.TravelPackage,
+		Title:      "Week-end à Rome",
+		Price:      eur(55000),
+		Stock:      10,
+		Components: []models.PackageComponent{{TravelID: hotel.ID}, {TravelID: flight.ID}},
+	})
+	assert.NotNil(t, pkg.DepartureDate)
+
+	resp := travelRequest("GET", fmt.Sprintf("/travels/%d", pkg.ID), nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	shown := decodeJSON[models.Travel](t, resp)
+	assert.Nil(t, shown.Flight)
+	assert.Nil(t, shown.Hotel)
+	if assert.Len(t, shown.Components, 2) && assert.NotNil(t, shown.Components[0].Travel) {
+		assert.Equal(t, hotel.ID, shown.Components[0].TravelID)
+		if assert.NotNil(t, shown.Components[0].Travel.Hotel) {
+			assert.Len(t, shown.Components[0].Travel.Hotel.RoomTypes, 2)
+		}
+		assert.NotNil(t, shown.Components[1].Travel.Flight)
+	}
+
+	resp = travelRequest("GET", "/travels", nil)
+	listed := decodeJSON[models.TravelPage](t, resp)
+	if assert.Len(t, listed.Data, 3) {
+		assert.Equal(t, "FCO", listed.Data[0].Flight.Destination)
+		assert.Equal(t, "IT", listed.Data[1].Hotel.Country)
+		assert.Len(t, listed.Data[2].Components, 2)
+	}
+}
+
+func TestTypedTravelValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	SetupSQLiteDB(t)
+
+	departure := time.Now().Add(30 * 24 * time.Hour)
+	flight := createTravel(t, flightTravel(departure))
+	hotel := createTravel(t, hotelTravel())
+
+	untyped := flightTravel(departure)
+	untyped.Type = ""
+	noFlight := flightTravel(departure)
+	noFlight.Flight = nil
+	sameAirport := flightTravel(departure)
+	sameAirport.Flight.Destination = "CDG"
+	backwards := flightTravel(departure)
+	backwards.Flight.ArrivalAt = departure.Add(-time.Hour)
+	mixed := hotelTravel()
+	mixed.Flight = flightTravel(departure).Flight
+	noRooms := hotelTravel()
+	noRooms.Hotel.RoomTypes = nil
+	stars := hotelTravel()
+	stars.Hotel.Stars = 6
+	flightOnly := models.Travel{Type: models.TravelPackage, Title: "Vol seul", Price: eur(1000),
+		Components: []models.PackageComponent{{TravelID: flight.ID}}}
+	nested := models.Travel{Type: models.TravelPackage, Title: "Séjours", Price: eur(1000),
+		Components: []models.PackageComponent{{TravelID: flight.ID}, {TravelID: hotel.ID}}}
+	missing := models.Travel{Type: models.TravelPackage, Title: "Inconnu", Price: eur(1000),
+		Components: []models.PackageComponent{{TravelID: flight.ID}, {TravelID: 999}}}
+
+	for name, input := range map[string]models.Travel{
+		"sans type": untyped, "vol sans attributs": noFlight, "même aéroport": sameAirport,
+		"arrivée avant départ": backwards, "hôtel avec vol": mixed, "sans chambre": noRooms,
+		"6 étoiles": stars, "séjour sans hôtel": flightOnly, "composant inconnu": missing,
+	} {
+		resp := travelRequest("POST", "/travels", input)
+		assert.Equal(t, http.StatusBadRequest, resp.Code, name)
+	}
+
+	// Un séjour ne peut pas en contenir un autre
+	pkg := createTravel(t, nested)
+	nested.Components = append(nested.Components, models.PackageComponent{TravelID: pkg.ID})
+	assert.Equal(t, http.StatusBadRequest, travelRequest("POST", "/travels", nested).Code)
+}
+
+func TestUpdateTypedTravel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	hotel := createTravel(t, hotelTravel())
+	path := fmt.Sprintf("/travels/%d", hotel.ID)
+
+	assert.Equal(t, http.StatusBadRequest, travelRequest("PUT", path, models.Travel{Type: models.TravelFlight}).Code)
+
+	// Sans attributs, ceux du type sont conservés
+	assert.Equal(t, http.StatusOK, travelRequest("PUT", path, models.Travel{Title: "Hôtel du Colisée"}).Code)
+	shown := decodeJSON[models.Travel](t, travelRequest("GET", path, nil))
+	assert.Equal(t, "Hôtel du Colisée", shown.Title)
+	if assert.NotNil(t, shown.Hotel) {
+		assert.Len(t, shown.Hotel.RoomTypes, 2)
+	}
+
+	// Fournis, ils sont remplacés
+	update := hotelTravel().Hotel
+	update.Stars = 5
+	update.RoomTypes = []models.HotelRoomType{{Name: "Familiale", Capacity: 5}}
+	resp := travelRequest("PUT", path, models.Travel{Hotel: update})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	shown = decodeJSON[models.Travel](t, travelRequest("GET", path, nil))
+	if assert.NotNil(t, shown.Hotel) && assert.Len(t, shown.Hotel.RoomTypes, 1) {
+		assert.Equal(t, 5, shown.Hotel.Stars)
+		assert.Equal(t, "Familiale", shown.Hotel.RoomTypes[0].Name)
+	}
+	var rooms int64
+	db.Model(&models.HotelRoomType{}).Count(&rooms)
+	assert.Equal(t, int64(1), rooms)
+
+	resp = travelRequest("PUT", path, models.Travel{Flight: flightTravel(time.Now()).Flight})
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"h3-travel/controllers"
+	"h3-travel/models"
+	"h3-travel/money"
+	"h3-travel/notify"
+	"h3-travel/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type recordingNotifier struct {
+	mu    sync.Mutex
+	users []uint
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, userID uint, subject, message string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.users = append(n.users, userID)
+	return nil
+}
+
+func recordNotifications(t *testing.T) *recordingNotifier {
+	recorder := &recordingNotifier{}
+	previous := notify.Default
+	notify.Default = recorder
+	t.Cleanup(func() { notify.Default = previous })
+	return recorder
+}
+
+func waitlistRequest(method string, userID, travelID uint) *httptest.ResponseRecorder {
+	router := gin.New()
+	handler := controllers.JoinWaitlist
+	if method == "DELETE" {
+		handler = controllers.LeaveWaitlist
+	}
+	router.Handle(method, "/travels/:id/waitlist", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		handler(c)
+	})
+
+	return jsonRequest(router, method, fmt.Sprintf("/travels/%d/waitlist", travelID), nil)
+}
+
+func waitlistEntryOf(db *gorm.DB, userID, travelID uint) models.WaitlistEntry {
+	var entry models.WaitlistEntry
+	db.Where("user_id = ? AND travel_id = ?", userID, travelID).Order("id DESC").First(&entry)
+	return entry
+}
+
+func TestJoinWaitlistOnlyWhenSoldOut(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Oslo", Price: money.New(10000, "EUR"), Stock: 1, Active: true}
+	db.Create(&travel)
+
+	assert.Equal(t, http.StatusConflict, waitlistRequest("POST", 2, travel.ID).Code)
+
+	createHold(t, orderItemsRouter(1), travel.ID, 1)
+
+	resp := waitlistRequest("POST", 2, travel.ID)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	entry := decodeJSON[models.WaitlistEntry](t, resp)
+	assert.Equal(t, 1, entry.Position)
+
+	assert.Equal(t, http.StatusConflict, waitlistRequest("POST", 2, travel.ID).Code)
+
+	resp = waitlistRequest("POST", 3, travel.ID)
+	entry = decodeJSON[models.WaitlistEntry](t, resp)
+	assert.Equal(t, 2, entry.Position)
+
+	assert.Equal(t, http.StatusOK, waitlistRequest("DELETE", 2, travel.ID).Code)
+	assert.Equal(t, http.StatusNotFound, waitlistRequest("DELETE", 2, travel.ID).Code)
+	assert.Equal(t, http.StatusNotFound, waitlistRequest("POST", 2, 999).Code)
+
+	// Un travel daté se réserve par départ : sa liste d'attente ne serait jamais servie
+	dated := models.Travel{Title: "Week-end à Lisbonne", Price: money.New(40000, "EUR"), Stock: 0, Active: true}
+	db.Create(&dated)
+	start := time.Now().Add(240 * time.Hour)
+	db.Create(&models.Departure{TravelID: dated.ID, StartDate: start, EndDate: start.Add(48 * time.Hour), Capacity: 4, Stock: 0})
+	assert.Equal(t, http.StatusBadRequest, waitlistRequest("POST", 2, dated.ID).Code)
+}
+
+func TestWaitlistPromotionOnCancelExpiryAndPayment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+	notifications := recordNotifications(t)
+
+	travel := models.Travel{Title: "Lisbonne", Price: money.New(25000, "EUR"), Stock: 1, Active: true}
+	db.Create(&travel)
+
+	first := paidOrder(t, orderItemsRouter(1), travel.ID, 1)
+	assert.Equal(t, http.StatusOK, waitlistRequest("POST", 2, travel.ID).Code)
+	assert.Equal(t, http.StatusOK, waitlistRequest("POST", 3, travel.ID).Code)
+
+	// L'annulation libère la place : elle est réservée pour le premier inscrit
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/orders/%d/cancel", first.ID), nil)
+	resp := httptest.NewRecorder()
+	orderItemsRouter(1).ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	offered := waitlistEntryOf(db, 2, travel.ID)
+	assert.Equal(t, models.WaitlistOffered, offered.Status)
+	assert.NotNil(t, offered.OrderID)
+	assert.Equal(t, 0, stockOf(db, travel.ID))
+	assert.Equal(t, []uint{2}, notifications.users)
+
+	// Offre non payée à temps : la place passe au suivant
+	expireNow(db, *offered.OrderID)
+	expired, err := services.ExpireHolds(context.Background(), db, time.Now(), time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, expired)
+
+	assert.Equal(t, models.WaitlistExpired, waitlistEntryOf(db, 2, travel.ID).Status)
+	next := waitlistEntryOf(db, 3, travel.ID)
+	assert.Equal(t, models.WaitlistOffered, next.Status)
+	assert.Equal(t, []uint{2, 3}, notifications.users)
+
+	// Le paiement de la réservation proposée clôt l'inscription
+	resp = payOrder(t, orderItemsRouter(3), *next.OrderID, "4242424242424242")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, models.WaitlistClaimed, waitlistEntryOf(db, 3, travel.ID).Status)
+	assert.Equal(t, 0, stockOf(db, travel.ID))
+}
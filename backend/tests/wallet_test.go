@@ -0,0 +1,229 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"h3-travel/controllers"
+	"h3-travel/models"
+	"h3-travel/money"
+	"h3-travel/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func walletRequest(userID uint, method, path string, input interface{}) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", userID) })
+	router.GET("/wallet", controllers.GetWallet)
+	router.POST("/wallet/redeem", controllers.RedeemGiftCard)
+	router.GET("/admin/gift-cards", controllers.AdminListGiftCards)
+	router.POST("/admin/gift-cards", controllers.AdminIssueGiftCard)
+
+	return jsonRequest(router, method, path, input)
+}
+
+func issueGiftCard(t *testing.T, input models.GiftCardInput) models.GiftCard {
+	return decodeOK[models.GiftCard](t, walletRequest(99, "POST", "/admin/gift-cards", input))
+}
+
+// fundWallet crédite le porte-monnaie de l'utilisateur avec une carte cadeau
+func fundWallet(t *testing.T, userID uint, amount money.Money) {
+	card := issueGiftCard(t, models.GiftCardInput{Amount: amount})
+	resp := walletRequest(userID, "POST", "/wallet/redeem", models.RedeemGiftCardInput{Code: card.Code})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+}
+
+func walletOf(t *testing.T, userID uint) models.WalletSummary {
+	return decodeOK[models.WalletSummary](t, walletRequest(userID, "GET", "/wallet", nil))
+}
+
+func orderWithWallet(userID uint, travelID uint, quantity int, walletAmount int64) *httptest.ResponseRecorder {
+	return jsonRequest(orderItemsRouter(userID), "POST", "/orders", models.CreateOrderInput{
+		Items:        []models.OrderItemInput{{TravelID: travelID, Quantity: quantity}},
+		WalletAmount: walletAmount,
+	})
+}
+
+func cancelOrderRequest(userID, orderID uint, input *models.CancelOrderInput) *httptest.ResponseRecorder {
+	path := fmt.Sprintf("/orders/%d/cancel", orderID)
+	if input == nil {
+		// sans corps, pas même "null"
+		return jsonRequest(orderItemsRouter(userID), "PUT", path, nil)
+	}
+	return jsonRequest(orderItemsRouter(userID), "PUT", path, input)
+}
+
+func TestGiftCardIssueAndRedeem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	assert.Equal(t, http.StatusBadRequest, walletRequest(99, "POST", "/admin/gift-cards", models.GiftCardInput{}).Code)
+
+	card := issueGiftCard(t, models.GiftCardInput{Amount: eur(5000), Note: "Vente boutique"})
+	assert.Regexp(t, `^[A-Z2-9]{4}-[A-Z2-9]{4}-[A-Z2-9]{4}-[A-Z2-9]{4}$`, card.Code)
+	assert.Equal(t, card.Code[15:], card.Last4)
+
+	// Le code n'est pas conservé en clair
+	var stored models.GiftCard
+	db.First(&stored, card.ID)
+	assert.NotEqual(t, card.Code, stored.CodeHash)
+	resp := walletRequest(99, "GET", "/admin/gift-cards", nil)
+	assert.NotContains(t, resp.Body.String(), card.Code)
+
+	assert.Equal(t, http.StatusNotFound, walletRequest(1, "POST", "/wallet/redeem", models.RedeemGiftCardInput{Code: "AAAA-BBBB-CCCC-DDDD"}).Code)
+
+	// Saisie tolérante : minuscules, sans tirets
+	code := card.Code[:4] + " " + card.Code[5:9] + card.Code[10:14] + card.Code[15:]
+	resp = walletRequest(1, "POST", "/wallet/redeem", models.RedeemGiftCardInput{Code: code})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	entry := decodeJSON[models.WalletTransaction](t, resp)
+	assert.Equal(t, models.WalletGiftCard, entry.Kind)
+	assert.Equal(t, eur(5000), entry.BalanceAfter)
+
+	assert.Equal(t, http.StatusConflict, walletRequest(2, "POST", "/wallet/redeem", models.RedeemGiftCardInput{Code: card.Code}).Code)
+
+	expiresAt := time.Now().Add(time.Hour)
+	expiring := issueGiftCard(t, models.GiftCardInput{Amount: eur(1000), ExpiresAt: &expiresAt})
+	db.Model(&models.GiftCard{}).Where("id = ?", expiring.ID).Update("expires_at", time.Now().Add(-time.Minute))
+	assert.Equal(t, http.StatusGone, walletRequest(1, "POST", "/wallet/redeem", models.RedeemGiftCardInput{Code: expiring.Code}).Code)
+
+	fundWallet(t, 1, eur(2500))
+	summary := walletOf(t, 1)
+	assert.Equal(t, []money.Money{eur(7500)}, summary.Balances)
+	if assert.Len(t, summary.Transactions, 2) {
+		assert.Equal(t, eur(7500), summary.Transactions[0].BalanceAfter)
+	}
+	assert.Empty(t, walletOf(t, 2).Balances)
+
+	// Le registre est en ajout seul
+	assert.ErrorIs(t, db.Model(&summary.Transactions[0]).Update("reason", "x").Error, models.ErrLedgerAppendOnly)
+	assert.ErrorIs(t, db.Delete(&summary.Transactions[0]).Error, models.ErrLedgerAppendOnly)
+}
+
+func TestOrderPaidPartlyFromWallet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Rome", Price: eur(30000), Stock: 5, Active: true}
+	db.Create(&travel)
+	fundWallet(t, 1, eur(20000))
+
+	// Solde insuffisant : rien n'est réservé ni prélevé
+	resp := orderWithWallet(1, travel.ID, 2, 25000)
+	assert.Equal(t, http.StatusPaymentRequired, resp.Code, resp.Body.String())
+	assert.Equal(t, 5, stockOf(db, travel.ID))
+	assert.Equal(t, []money.Money{eur(20000)}, walletOf(t, 1).Balances)
+
+	resp = orderWithWallet(1, travel.ID, 2, 20000)
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	order := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, models.StatusPendingPayment, order.Statut)
+	assert.Equal(t, eur(20000), order.WalletAmount)
+	assert.Equal(t, []money.Money{eur(0)}, walletOf(t, 1).Balances)
+
+	// La carte ne paie que le reste
+	router := orderItemsRouter(1)
+	resp = payOrder(t, router, order.ID, "4242424242424242")
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	paid := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, eur(60000), paid.PaidAmount)
+	if assert.Len(t, paid.Payments, 2) {
+		assert.Equal(t, eur(40000), paid.Payments[0].Amount)
+		assert.Equal(t, models.WalletProvider, paid.Payments[1].Provider)
+		assert.Equal(t, eur(20000), paid.Payments[1].Amount)
+	}
+	if assert.Len(t, paid.Invoices, 1) {
+		assert.Equal(t, eur(60000), paid.Invoices[0].Gross)
+	}
+
+	// L'annulation rembourse la carte, et la part du porte-monnaie y revient
+	resp = cancelOrderRequest(1, order.ID, nil)
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	cancelled := decodeJSON[models.CancelOrderResponse](t, resp)
+	if assert.Len(t, cancelled.Order.Refunds, 2) {
+		assert.False(t, cancelled.Order.Refunds[0].ToWallet)
+		assert.Equal(t, eur(40000), cancelled.Order.Refunds[0].Amount)
+		assert.True(t, cancelled.Order.Refunds[1].ToWallet)
+	}
+	assert.Equal(t, []money.Money{eur(20000)}, walletOf(t, 1).Balances)
+}
+
+func TestOrderPaidEntirelyFromWallet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Lisbonne", Price: eur(30000), Stock: 5, Active: true}
+	db.Create(&travel)
+	fundWallet(t, 1, eur(50000))
+
+	// Montant plafonné au total : la commande est payée sans carte
+	resp := orderWithWallet(1, travel.ID, 1, 99999)
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	order := decodeJSON[models.Order](t, resp)
+	assert.Equal(t, models.StatusPaid, order.Statut)
+	assert.Equal(t, eur(30000), order.PaidAmount)
+	assert.Equal(t, models.WalletProvider, order.PaymentProvider)
+	assert.Len(t, order.Invoices, 1)
+	assert.Equal(t, []money.Money{eur(20000)}, walletOf(t, 1).Balances)
+
+	var payments []models.Payment
+	db.Where("order_id = ?", order.ID).Find(&payments)
+	assert.Len(t, payments, 1)
+
+	resp = adminRefund(order.ID, models.RefundInput{Amount: 5000, Reason: "Geste commercial"})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, []money.Money{eur(25000)}, walletOf(t, 1).Balances)
+}
+
+func TestWalletReleasedWhenHoldExpires(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Oslo", Price: eur(40000), Stock: 2, Active: true}
+	db.Create(&travel)
+	fundWallet(t, 1, eur(10000))
+
+	resp := orderWithWallet(1, travel.ID, 1, 10000)
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	order := decodeJSON[models.Order](t, resp)
+	assert.Empty(t, walletOf(t, 1).Balances[0].Amount)
+
+	expireNow(db, order.ID)
+	expired, err := services.ExpireHolds(context.Background(), db, time.Now(), time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, expired)
+
+	summary := walletOf(t, 1)
+	assert.Equal(t, []money.Money{eur(10000)}, summary.Balances)
+	assert.Equal(t, models.WalletRelease, summary.Transactions[0].Kind)
+}
+
+func TestCancelRefundAsStoreCredit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupSQLiteDB(t)
+
+	travel := models.Travel{Title: "Athènes", Price: eur(35000), Stock: 3, Active: true}
+	db.Create(&travel)
+	order := paidOrder(t, orderItemsRouter(1), travel.ID, 1)
+
+	resp := cancelOrderRequest(1, order.ID, &models.CancelOrderInput{ToWallet: true})
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	cancelled := decodeJSON[models.CancelOrderResponse](t, resp)
+	if assert.Len(t, cancelled.Order.Refunds, 1) {
+		assert.True(t, cancelled.Order.Refunds[0].ToWallet)
+		assert.Contains(t, cancelled.Order.Refunds[0].ProviderRefundID, "wtx_")
+	}
+
+	summary := walletOf(t, 1)
+	assert.Equal(t, []money.Money{eur(35000)}, summary.Balances)
+	if assert.Len(t, summary.Transactions, 1) && assert.NotNil(t, summary.Transactions[0].OrderID) {
+		assert.Equal(t, models.WalletRefund, summary.Transactions[0].Kind)
+		assert.Equal(t, order.ID, *summary.Transactions[0].OrderID)
+	}
+}
@@ -4,6 +4,9 @@ func ValidateCardNumber(number string) bool {
 	sum := 0
 	alt := false
 	n := len(number)
+	if n < 2 {
+		return false
+	}
 
 	for i := n - 1; i >= 0; i-- {
 		c := number[i]
@@ -0,0 +1,78 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidCiphertext = errors.New("donnée chiffrée invalide")
+
+// ParseKey décode une clé AES-256 encodée en base64 (32 octets).
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("clé non base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("la clé doit faire 32 octets, %d reçus", len(key))
+	}
+	return key, nil
+}
+
+// Seal chiffre avec AES-GCM ; le nonce aléatoire est placé en tête du résultat.
+// additionalData, authentifiée mais pas chiffrée, lie la donnée à son contexte
+// (nil si aucun) : Open échoue si elle n'est pas redonnée à l'identique.
+func Seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Open déchiffre une donnée produite par Seal avec les mêmes additionalData et
+// vérifie son intégrité.
+func Open(key, sealed, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plaintext, nil
+}
+
+// NewToken génère un identifiant opaque et aléatoire, par exemple "tok_9f86d081884c7d65".
+func NewToken(prefix string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}